@@ -19,24 +19,38 @@ import (
 	"github.com/awnumar/memguard"
 	"github.com/integrii/flaggy"
 	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/admin"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/approval"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/ca"
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/client"
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/item"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/login"
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/watch"
 	"github.com/vemilyus/borg-collective/credentials/internal/logging"
 	"path/filepath"
+	"time"
 )
 
 var (
 	version = "unknown"
 
 	configDir = ""
+	timeout   time.Duration
 
 	configureCmd = config.NewCmd()
-	loginCmd     = config.NewLoginCmd()
+	loginCmd     = login.NewCmd()
+	logoutCmd    = login.NewLogoutCmd()
 	storeCmd     = store.NewCmd()
 	clientCmd    = client.NewCmd()
 	itemCmd      = item.NewCmd()
+	caCmd        = ca.NewCmd()
+	adminCmd     = admin.NewCmd()
+	approvalCmd  = approval.NewCmd()
+	auditCmd     = audit.NewCmd()
+	watchCmd     = watch.NewCmd()
 )
 
 func main() {
@@ -50,16 +64,30 @@ func main() {
 	state := createState()
 	defer state.Config().Destroy()
 
+	state.SetTimeoutOverride(timeout)
+
 	if configureCmd.Used {
 		configureCmd.Run(state)
 	} else if loginCmd.Used {
 		loginCmd.Run(state)
+	} else if logoutCmd.Used {
+		logoutCmd.Run(state)
 	} else if storeCmd.Used {
 		storeCmd.Run(state)
 	} else if itemCmd.Used {
 		itemCmd.Run(state)
 	} else if clientCmd.Used {
 		clientCmd.Run(state)
+	} else if caCmd.Used {
+		caCmd.Run(state)
+	} else if adminCmd.Used {
+		adminCmd.Run(state)
+	} else if approvalCmd.Used {
+		approvalCmd.Run(state)
+	} else if auditCmd.Used {
+		auditCmd.Run(state)
+	} else if watchCmd.Used {
+		watchCmd.Run(state)
 	} else {
 		flaggy.ShowHelpAndExit("")
 	}
@@ -73,6 +101,7 @@ func parseArgs() {
 	flaggy.SetVersion(version)
 
 	flaggy.String(&configDir, "", "config-dir", "the location where the configuration file is stored")
+	flaggy.Duration(&timeout, "", "timeout", "the deadline of each call to the store (e.g. 10s), overrides the configured timeout")
 
 	flaggy.Parse()
 }
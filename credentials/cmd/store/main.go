@@ -16,31 +16,70 @@
 package main
 
 import (
+	"crypto/tls"
+	"fmt"
 	"github.com/awnumar/memguard"
+	"github.com/coreos/go-systemd/v22/activation"
+	"github.com/coreos/go-systemd/v22/daemon"
 	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/vemilyus/borg-collective/credentials/internal/logging"
 	"github.com/vemilyus/borg-collective/credentials/internal/store"
 	"github.com/vemilyus/borg-collective/credentials/internal/store/server"
 	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
 	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultLogFileMaxSize    = 100
+	defaultLogFileMaxBackups = 5
 )
 
 var (
 	version = "unknown"
 
-	prod       bool
-	configPath string
+	prod           bool
+	configPath     string
+	checkConfigCmd *flaggy.Subcommand
+	openApiCmd     *flaggy.Subcommand
 )
 
 func main() {
-	memguard.CatchInterrupt()
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+
 	defer memguard.Purge()
 
 	parseArgs()
+
+	if checkConfigCmd.Used {
+		logging.InitSimpleLogging()
+		checkConfig(configPath)
+		return
+	}
+
+	if openApiCmd.Used {
+		logging.InitSimpleLogging()
+		printOpenApi()
+		return
+	}
+
 	logging.InitLogging(prod)
 
 	config := loadConfig(configPath)
+	applyLogLevel(config)
+
+	closeLogs := configureLogging(config)
+	defer closeLogs()
 
 	vaultInstance, err := vault.NewVault(&vault.Options{
 		Backend: vault.NewLocalStorageBackend(config.StoragePath),
@@ -48,31 +87,220 @@ func main() {
 	})
 
 	if err != nil {
-		log.Fatal().Err(err).Send()
+		fatal(err, "")
 	}
 
-	state := service.NewState(
+	state, err := service.NewState(
 		config,
 		vaultInstance,
 		version,
 		prod,
 	)
 
-	srv, err := server.NewServer(state)
 	if err != nil {
-		log.Fatal().Err(err).Send()
+		fatal(err, "")
+	}
+
+	// listeners passed by systemd socket activation, if any
+	activated, err := activation.Listeners()
+	if err != nil {
+		fatal(err, "")
+	}
+
+	srv, err := server.NewServer(state, activated)
+	if err != nil {
+		fatal(err, "")
 	}
 
 	if prod {
 		log.Info().Msg("Running in production mode")
 	}
 
-	log.Info().Msgf("Listening on %s", config.ListenAddress)
+	for _, address := range srv.Addresses() {
+		log.Info().Msgf("Listening on %s", address)
+	}
+
+	if address := srv.MetricsAddress(); address != nil {
+		log.Info().Msgf("Serving metrics on %s", address)
+	}
+
+	if address := srv.GatewayAddress(); address != nil {
+		log.Info().Msgf("Serving HTTP/JSON gateway on %s", address)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve()
+	}()
+
+	notify(daemon.SdNotifyReady)
+	go runWatchdog()
+
+	state.StartReplication()
+
+serving:
+	for {
+		select {
+		case err = <-errs:
+			log.Error().Err(err).Msg("Failed to serve")
+			break serving
+		case sig := <-signals:
+			log.Info().Msgf("Received %s, shutting down", sig)
+			break serving
+		case <-reloads:
+			reload(state)
+		}
+	}
+
+	go func() {
+		sig := <-signals
+		log.Warn().Msgf("Received %s again, exiting immediately", sig)
+		memguard.SafeExit(1)
+	}()
+
+	shutdown(state, srv, shutdownTimeout(state.Config()))
+
+	if err != nil {
+		memguard.SafeExit(1)
+	}
+}
+
+// reload applies the settings of the configuration file which can be changed
+// at runtime, TLS certificates are reloaded separately
+func reload(state *service.State) {
+	notify(daemon.SdNotifyReloading)
+	defer notify(daemon.SdNotifyReady)
+
+	log.Info().Msgf("Reloading configuration from %s", configPath)
+
+	config, err := store.LoadConfig(configPath)
+	if err == nil {
+		err = state.Reload(config)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to reload configuration, keeping the current one")
+		return
+	}
+
+	applyLogLevel(config)
+
+	log.Info().Msg("Reloaded configuration")
+}
+
+// configureLogging switches to the configured log outputs, the returned
+// function closes them
+func configureLogging(config *store.Config) func() {
+	if config.Logging == nil {
+		return func() {}
+	}
+
+	options := logging.Options{
+		Format:   config.Logging.Format,
+		Stdout:   config.Logging.Stdout == nil || *config.Logging.Stdout,
+		Journald: config.Logging.Journald,
+		NoColor:  prod,
+	}
+
+	if file := config.Logging.File; file != nil {
+		maxSize := defaultLogFileMaxSize
+		if file.MaxSize != nil {
+			maxSize = *file.MaxSize
+		}
+
+		maxBackups := defaultLogFileMaxBackups
+		if file.MaxBackups != nil {
+			maxBackups = *file.MaxBackups
+		}
+
+		options.File = &logging.FileOptions{
+			Path:       file.Path,
+			MaxSize:    int64(maxSize) << 20,
+			MaxBackups: maxBackups,
+		}
+	}
+
+	closeLogs, err := logging.ConfigureLogging(options)
+	if err != nil {
+		fatal(err, "Failed to configure logging")
+	}
+
+	return closeLogs
+}
+
+func applyLogLevel(config *store.Config) {
+	if config.LogLevel == "" {
+		logging.SetDefaultLevel(prod)
+		return
+	}
+
+	// the level was validated when loading the configuration
+	level, _ := logging.ParseLevel(config.LogLevel)
+	zerolog.SetGlobalLevel(level)
+}
+
+// shutdown stops accepting calls and drains the running ones before locking
+// the vault, so they don't fail halfway through
+func shutdown(state *service.State, srv *server.Server, timeout time.Duration) {
+	notify(daemon.SdNotifyStopping)
+
+	// watches would keep the graceful stop waiting
+	state.StopWatches()
+
+	if !srv.Shutdown(timeout) {
+		log.Warn().Msgf("Cancelled calls still running after %s", timeout)
+	}
+
+	// a pull could write files while locking
+	state.StopReplication()
 
-	err = srv.Serve()
+	if state.Lock() {
+		log.Info().Msg("Locked vault")
+	}
+
+	log.Info().Msg("Shut down")
+}
 
+// fatal logs the error and exits, unlike log.Fatal it wipes the secrets
+// first, as deferred calls don't run
+func fatal(err error, msg string) {
+	log.Error().Err(err).Msg(msg)
+	memguard.SafeExit(1)
+}
+
+func shutdownTimeout(config *store.Config) time.Duration {
+	if config.ShutdownTimeout != nil {
+		return config.ShutdownTimeout.Duration
+	}
+
+	return server.DefaultShutdownTimeout
+}
+
+// notify sends the state to systemd, nothing happens if not running as a
+// notify service
+func notify(state string) {
+	if _, err := daemon.SdNotify(false, state); err != nil {
+		log.Warn().Err(err).Msg("Failed to notify systemd")
+	}
+}
+
+// runWatchdog keeps the systemd watchdog from firing, if it's enabled
+func runWatchdog() {
+	interval, err := daemon.SdWatchdogEnabled(false)
 	if err != nil {
-		log.Fatal().Err(err).Send()
+		log.Warn().Err(err).Msg("Failed to read systemd watchdog settings")
+		return
+	}
+
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		notify(daemon.SdNotifyWatchdog)
 	}
 }
 
@@ -81,22 +309,83 @@ func parseArgs() {
 	flaggy.SetDescription("Securely stores and provides credentials over the network")
 	flaggy.SetVersion(version)
 
+	flaggy.DefaultParser.AdditionalHelpPrepend = "\n  Serve:\n    credstore [-p] CONFIG-PATH"
+	flaggy.ShowHelpOnUnexpectedDisable()
+
 	flaggy.Bool(&prod, "p", "production", "Indicates whether to run in production mode (requires TLS config)")
-	flaggy.AddPositionalValue(&configPath, "CONFIG-PATH", 1, true, "Path to the configuration file")
+
+	checkConfigCmd = flaggy.NewSubcommand("check-config")
+	checkConfigCmd.Description = "Validates the configuration file without starting the store"
+	checkConfigCmd.AddPositionalValue(&configPath, "CONFIG-PATH", 1, true, "Path to the configuration file")
+
+	flaggy.AttachSubcommand(checkConfigCmd, 1)
+
+	openApiCmd = flaggy.NewSubcommand("openapi")
+	openApiCmd.Description = "Prints the OpenAPI description of the HTTP/JSON gateway"
+
+	flaggy.AttachSubcommand(openApiCmd, 1)
 
 	flaggy.Parse()
+
+	if !checkConfigCmd.Used && !openApiCmd.Used {
+		// the config path can't be declared as positional value alongside a
+		// subcommand
+		if len(flaggy.TrailingArguments) != 1 {
+			flaggy.ShowHelpAndExit("Expected exactly one CONFIG-PATH")
+		}
+
+		configPath = flaggy.TrailingArguments[0]
+	}
 }
 
 func loadConfig(configPath string) *store.Config {
 	config, err := store.LoadConfig(configPath)
+	if err == nil {
+		err = service.ValidateConfig(config, prod)
+	}
+
 	if err != nil {
-		log.Fatal().Err(err).Send()
+		fatal(err, "Invalid configuration")
 	}
 
 	err = store.InitStoragePath(config)
 	if err != nil {
-		log.Fatal().Err(err).Send()
+		fatal(err, "")
 	}
 
 	return config
 }
+
+// checkConfig validates the configuration file and the TLS key pair, and
+// exits with a non-zero status if there are any problems
+func checkConfig(configPath string) {
+	config, err := store.LoadConfig(configPath)
+	if err == nil {
+		err = service.ValidateConfig(config, prod)
+	}
+
+	if err == nil && config.Tls != nil {
+		if _, tlsErr := tls.LoadX509KeyPair(config.Tls.CertFile, config.Tls.KeyFile); tlsErr != nil {
+			err = fmt.Errorf("Tls: %v", tlsErr)
+		}
+	}
+
+	if err != nil {
+		for _, problem := range strings.Split(err.Error(), "\n") {
+			log.Error().Msg(problem)
+		}
+
+		os.Exit(1)
+	}
+
+	log.Info().Msgf("%s is valid", configPath)
+}
+
+func printOpenApi() {
+	document, err := server.GatewayOpenApi(version)
+	if err != nil {
+		fatal(err, "Failed to generate OpenAPI description")
+	}
+
+	_, _ = os.Stdout.Write(append(document, '\n'))
+}
@@ -0,0 +1,229 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"fmt"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"strings"
+	"time"
+)
+
+type Cmd struct {
+	*flaggy.Subcommand
+	*addCmd
+	*removeCmd
+	*listCmd
+}
+
+func NewCmd() *Cmd {
+	adminCmd := &Cmd{}
+
+	cmd := flaggy.NewSubcommand("admin")
+	cmd.Description = "Manage the named admins of a credential store"
+
+	flaggy.AttachSubcommand(cmd, 1)
+
+	adminCmd.Subcommand = cmd
+	adminCmd.addCmd = newAddCmd(cmd)
+	adminCmd.removeCmd = newRemoveCmd(cmd)
+	adminCmd.listCmd = newListCmd(cmd)
+
+	return adminCmd
+}
+
+func (cmd *Cmd) Run(state *config.State) {
+	state.Config().VerifyConnectionConfig()
+
+	if cmd.addCmd.Used {
+		cmd.addCmd.run(state)
+	} else if cmd.removeCmd.Used {
+		cmd.removeCmd.run(state)
+	} else if cmd.listCmd.Used {
+		cmd.listCmd.run(state)
+	} else {
+		flaggy.ShowHelpAndExit("")
+	}
+}
+
+type addCmd struct {
+	*flaggy.Subcommand
+	name string
+	role string
+}
+
+func newAddCmd(parent *flaggy.Subcommand) *addCmd {
+	aCmd := &addCmd{
+		role: "auditor",
+	}
+
+	cmd := flaggy.NewSubcommand("add")
+	cmd.Description = "Adds an admin with their own passphrase"
+
+	cmd.AddPositionalValue(&aCmd.name, "NAME", 1, true, "Name of the admin")
+	cmd.String(&aCmd.role, "r", "role", "Role of the admin (admin, manager, auditor)")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	aCmd.Subcommand = cmd
+
+	return aCmd
+}
+
+func (cmd *addCmd) run(state *config.State) {
+	role, ok := proto.AdminRole_value[strings.ToUpper(strings.TrimSpace(cmd.role))]
+	if !ok {
+		log.Fatal().Msgf("Unknown role: %s", cmd.role)
+	}
+
+	adminCredentials := state.Config().AdminCredentials()
+
+	passphrase, err := utils.PromptSecure(fmt.Sprintf("Enter passphrase for %s", cmd.name))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to enter passphrase")
+	}
+
+	defer passphrase.Destroy()
+
+	confirmation, err := utils.PromptSecure("Repeat passphrase")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to enter passphrase")
+	}
+
+	defer confirmation.Destroy()
+
+	if !passphrase.EqualTo(confirmation.Bytes()) {
+		log.Fatal().Msg("Passphrases don't match")
+	}
+
+	admin, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.AdminInfo, error) {
+			return c.AddAdmin(&proto.AdminCreation{
+				Credentials: adminCredentials,
+				Name:        strings.TrimSpace(cmd.name),
+				Role:        proto.AdminRole(role),
+				Passphrase:  passphrase.String(),
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to add admin")
+	}
+
+	log.Info().Msgf("Added admin %s with role %s", admin.GetName(), strings.ToLower(admin.GetRole().String()))
+}
+
+type removeCmd struct {
+	*flaggy.Subcommand
+	name string
+}
+
+func newRemoveCmd(parent *flaggy.Subcommand) *removeCmd {
+	rCmd := &removeCmd{}
+
+	cmd := flaggy.NewSubcommand("remove")
+	cmd.ShortName = "rm"
+	cmd.Description = "Removes an admin, their passphrase no longer unlocks the vault"
+
+	cmd.AddPositionalValue(&rCmd.name, "NAME", 1, true, "Name of the admin")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	rCmd.Subcommand = cmd
+
+	return rCmd
+}
+
+func (cmd *removeCmd) run(state *config.State) {
+	doRemove, err := utils.PromptConfirm(fmt.Sprintf("Confirm removal of admin %s", cmd.name), false)
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	if !doRemove {
+		log.Info().Msg("Not removing admin, user aborted")
+		return
+	}
+
+	adminCredentials := state.Config().AdminCredentials()
+
+	_, err = grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (any, error) {
+			return nil, c.RemoveAdmin(&proto.AdminRemoval{
+				Credentials: adminCredentials,
+				Name:        cmd.name,
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to remove admin")
+	}
+
+	log.Info().Msgf("Removed admin %s", cmd.name)
+}
+
+type listCmd struct {
+	*flaggy.Subcommand
+}
+
+func newListCmd(parent *flaggy.Subcommand) *listCmd {
+	lCmd := &listCmd{}
+
+	cmd := flaggy.NewSubcommand("list")
+	cmd.ShortName = "ls"
+	cmd.Description = "Lists all admins and their roles"
+
+	parent.AttachSubcommand(cmd, 1)
+
+	lCmd.Subcommand = cmd
+
+	return lCmd
+}
+
+func (cmd *listCmd) run(state *config.State) {
+	adminCredentials := state.Config().AdminCredentials()
+
+	admins, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) ([]*proto.AdminInfo, error) {
+			return c.ListAdmins(&proto.AdminSearch{Credentials: adminCredentials})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to retrieve admins")
+	}
+
+	log.Info().Msgf("Retrieved %d admins", len(admins))
+
+	for _, admin := range admins {
+		createdAt := "-"
+		if admin.GetCreatedAt() != 0 {
+			createdAt = time.UnixMilli(admin.GetCreatedAt()).Format(time.RFC3339)
+		}
+
+		fmt.Printf("%s\t%s\t%s\n", admin.GetName(), strings.ToLower(admin.GetRole().String()), createdAt)
+	}
+}
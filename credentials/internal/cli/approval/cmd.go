@@ -0,0 +1,228 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package approval
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"strings"
+	"time"
+)
+
+type Cmd struct {
+	*flaggy.Subcommand
+	*listCmd
+	*decideCmd
+	*requireCmd
+}
+
+func NewCmd() *Cmd {
+	approvalCmd := &Cmd{}
+
+	cmd := flaggy.NewSubcommand("approval")
+	cmd.Description = "Manage reads of items which require the approval of a second admin"
+
+	flaggy.AttachSubcommand(cmd, 1)
+
+	approvalCmd.Subcommand = cmd
+	approvalCmd.listCmd = newListCmd(cmd)
+	approvalCmd.decideCmd = newDecideCmd(cmd)
+	approvalCmd.requireCmd = newRequireCmd(cmd)
+
+	return approvalCmd
+}
+
+func (cmd *Cmd) Run(state *config.State) {
+	state.Config().VerifyConnectionConfig()
+
+	if cmd.listCmd.Used {
+		cmd.listCmd.run(state)
+	} else if cmd.decideCmd.approve.Used {
+		cmd.decideCmd.run(state, true)
+	} else if cmd.decideCmd.deny.Used {
+		cmd.decideCmd.run(state, false)
+	} else if cmd.requireCmd.Used {
+		cmd.requireCmd.run(state)
+	} else {
+		flaggy.ShowHelpAndExit("")
+	}
+}
+
+type listCmd struct {
+	*flaggy.Subcommand
+	all bool
+}
+
+func newListCmd(parent *flaggy.Subcommand) *listCmd {
+	lCmd := &listCmd{}
+
+	cmd := flaggy.NewSubcommand("list")
+	cmd.ShortName = "ls"
+	cmd.Description = "Lists pending approval requests"
+
+	cmd.Bool(&lCmd.all, "a", "all", "Also list recently decided requests")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	lCmd.Subcommand = cmd
+
+	return lCmd
+}
+
+func (cmd *listCmd) run(state *config.State) {
+	adminCredentials := state.Config().AdminCredentials()
+
+	requests, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) ([]*proto.ApprovalRequest, error) {
+			return c.ListApprovals(&proto.ApprovalSearch{
+				Credentials:    adminCredentials,
+				IncludeDecided: cmd.all,
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to retrieve approval requests")
+	}
+
+	log.Info().Msgf("Retrieved %d approval requests", len(requests))
+
+	for _, request := range requests {
+		fmt.Printf(
+			"%s\t%s\t%s\t%s\t%s\t%s\n",
+			request.GetId(),
+			request.GetItemDescription(),
+			request.GetRequester(),
+			strings.TrimPrefix(request.GetStatus().String(), "APPROVAL_"),
+			request.GetDecidedBy(),
+			time.UnixMilli(request.GetExpiresAt()).Format(time.RFC3339),
+		)
+	}
+}
+
+type decideCmd struct {
+	approve   *flaggy.Subcommand
+	deny      *flaggy.Subcommand
+	requestId string
+}
+
+func newDecideCmd(parent *flaggy.Subcommand) *decideCmd {
+	dCmd := &decideCmd{}
+
+	approve := flaggy.NewSubcommand("approve")
+	approve.Description = "Approves a pending request, the value can then be read once"
+	approve.AddPositionalValue(&dCmd.requestId, "REQUEST-ID", 1, true, "The ID of the approval request")
+
+	deny := flaggy.NewSubcommand("deny")
+	deny.Description = "Denies a pending request"
+	deny.AddPositionalValue(&dCmd.requestId, "REQUEST-ID", 1, true, "The ID of the approval request")
+
+	parent.AttachSubcommand(approve, 1)
+	parent.AttachSubcommand(deny, 1)
+
+	dCmd.approve = approve
+	dCmd.deny = deny
+
+	return dCmd
+}
+
+func (cmd *decideCmd) run(state *config.State, approve bool) {
+	adminCredentials := state.Config().AdminCredentials()
+
+	request, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.ApprovalRequest, error) {
+			return c.DecideApproval(&proto.ApprovalDecision{
+				Credentials: adminCredentials,
+				Id:          cmd.requestId,
+				Approve:     approve,
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to decide on approval request")
+	}
+
+	if approve {
+		log.Info().Msgf(
+			"Approved reading %s by %s until %s",
+			request.GetItemDescription(),
+			request.GetRequester(),
+			time.UnixMilli(request.GetExpiresAt()).Format(time.RFC3339),
+		)
+	} else {
+		log.Info().Msgf("Denied reading %s by %s", request.GetItemDescription(), request.GetRequester())
+	}
+}
+
+type requireCmd struct {
+	*flaggy.Subcommand
+	itemId  string
+	disable bool
+}
+
+func newRequireCmd(parent *flaggy.Subcommand) *requireCmd {
+	rCmd := &requireCmd{}
+
+	cmd := flaggy.NewSubcommand("require")
+	cmd.Description = "Requires approval by a second admin for reading an item"
+
+	cmd.AddPositionalValue(&rCmd.itemId, "ITEM-ID", 1, true, "The ID of the item")
+	cmd.Bool(&rCmd.disable, "d", "disable", "No longer require approval for the item")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	rCmd.Subcommand = cmd
+
+	return rCmd
+}
+
+func (cmd *requireCmd) run(state *config.State) {
+	itemId, err := uuid.Parse(cmd.itemId)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to parse item ID")
+	}
+
+	adminCredentials := state.Config().AdminCredentials()
+
+	item, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.Item, error) {
+			return c.SetItemApproval(&proto.ItemApprovalSetting{
+				Credentials:      adminCredentials,
+				ItemId:           itemId.String(),
+				RequiresApproval: !cmd.disable,
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to change approval setting")
+	}
+
+	if item.GetRequiresApproval() {
+		log.Info().Msgf("Reading %s now requires approval", item.GetDescription())
+	} else {
+		log.Info().Msgf("Reading %s no longer requires approval", item.GetDescription())
+	}
+}
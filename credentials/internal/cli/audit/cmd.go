@@ -0,0 +1,198 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"fmt"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"strings"
+	"time"
+)
+
+type Cmd struct {
+	*flaggy.Subcommand
+	*listCmd
+	*verifyCmd
+}
+
+func NewCmd() *Cmd {
+	auditCmd := &Cmd{}
+
+	cmd := flaggy.NewSubcommand("audit")
+	cmd.Description = "Inspect the audit log of the store"
+
+	flaggy.AttachSubcommand(cmd, 1)
+
+	auditCmd.Subcommand = cmd
+	auditCmd.listCmd = newListCmd(cmd)
+	auditCmd.verifyCmd = newVerifyCmd(cmd)
+
+	return auditCmd
+}
+
+func (cmd *Cmd) Run(state *config.State) {
+	state.Config().VerifyConnectionConfig()
+
+	if cmd.listCmd.Used {
+		cmd.listCmd.run(state)
+	} else if cmd.verifyCmd.Used {
+		cmd.verifyCmd.run(state)
+	} else {
+		flaggy.ShowHelpAndExit("")
+	}
+}
+
+type listCmd struct {
+	*flaggy.Subcommand
+	since     time.Duration
+	operation string
+	actor     string
+	itemId    string
+	failed    bool
+	limit     int
+}
+
+func newListCmd(parent *flaggy.Subcommand) *listCmd {
+	lCmd := &listCmd{
+		limit: 100,
+	}
+
+	cmd := flaggy.NewSubcommand("list")
+	cmd.ShortName = "ls"
+	cmd.Description = "Lists the most recent audit log entries"
+
+	cmd.Duration(&lCmd.since, "s", "since", "Only list entries of this recent period, e.g. 24h")
+	cmd.String(&lCmd.operation, "o", "operation", "Only list entries of this operation, e.g. ReadVaultItem")
+	cmd.String(&lCmd.actor, "a", "actor", "Only list entries of this admin or client")
+	cmd.String(&lCmd.itemId, "i", "item", "Only list entries concerning this item ID")
+	cmd.Bool(&lCmd.failed, "f", "failed", "Only list denied or failed operations")
+	cmd.Int(&lCmd.limit, "n", "limit", "The maximum number of entries to list, 0 lists all")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	lCmd.Subcommand = cmd
+
+	return lCmd
+}
+
+func (cmd *listCmd) run(state *config.State) {
+	if cmd.limit < 0 {
+		log.Fatal().Msg("Limit must not be negative")
+	}
+
+	query := &proto.AuditQuery{
+		Credentials: state.Config().AdminCredentials(),
+		Operation:   cmd.operation,
+		Actor:       cmd.actor,
+		ItemId:      cmd.itemId,
+		Limit:       uint32(cmd.limit),
+	}
+
+	if cmd.since > 0 {
+		query.Since = time.Now().Add(-cmd.since).UnixMilli()
+	}
+
+	if cmd.failed {
+		query.Outcomes = []proto.AuditOutcome{proto.AuditOutcome_AUDIT_DENIED, proto.AuditOutcome_AUDIT_FAILURE}
+	}
+
+	entries, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) ([]*proto.AuditEntry, error) {
+			return c.QueryAuditLog(query)
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to query audit log")
+	}
+
+	log.Info().Msgf("Retrieved %d audit log entries", len(entries))
+
+	for _, entry := range entries {
+		subject := strings.Join(entry.GetItemIds(), ",")
+		if entry.GetTarget() != "" {
+			subject = strings.TrimPrefix(subject+" "+entry.GetTarget(), " ")
+		}
+
+		fmt.Printf(
+			"%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.GetSeq(),
+			time.UnixMilli(entry.GetTimestamp()).Format(time.RFC3339),
+			entry.GetOperation(),
+			entry.GetActor(),
+			entry.GetSource(),
+			strings.ToLower(strings.TrimPrefix(entry.GetOutcome().String(), "AUDIT_")),
+			subject,
+			entry.GetError(),
+		)
+	}
+}
+
+type verifyCmd struct {
+	*flaggy.Subcommand
+}
+
+func newVerifyCmd(parent *flaggy.Subcommand) *verifyCmd {
+	vCmd := &verifyCmd{}
+
+	cmd := flaggy.NewSubcommand("verify")
+	cmd.Description = "Verifies that no audit log entries were edited or removed"
+
+	parent.AttachSubcommand(cmd, 1)
+
+	vCmd.Subcommand = cmd
+
+	return vCmd
+}
+
+func (cmd *verifyCmd) run(state *config.State) {
+	adminCredentials := state.Config().AdminCredentials()
+
+	verification, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.AuditVerification, error) {
+			return c.VerifyAuditLog(&proto.AuditVerificationRequest{Credentials: adminCredentials})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to verify audit log")
+	}
+
+	if !verification.GetValid() {
+		log.Fatal().Msgf("Audit log is invalid at entry %d: %s", verification.GetInvalidSeq(), verification.GetProblem())
+	}
+
+	log.Info().Msgf("Audit log is intact, verified %d entries", verification.GetEntries())
+	log.Info().Msgf("    Head: %d %s", verification.GetHeadSeq(), verification.GetHeadHash())
+
+	if verification.GetAnchoredSeq() < verification.GetHeadSeq() {
+		log.Warn().Msgf("    Anchored: %d, the later entries were logged while the vault was locked", verification.GetAnchoredSeq())
+	} else {
+		log.Info().Msgf("    Anchored: %d", verification.GetAnchoredSeq())
+	}
+
+	for _, seq := range verification.GetGaps() {
+		log.Warn().Msgf("    Gap: entry %d records an entry lost by an interrupted write", seq)
+	}
+
+	log.Info().Msg("    Compare the head with a previously recorded one to detect a rewritten log")
+}
@@ -0,0 +1,361 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package ca
+
+import (
+	"fmt"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"os"
+	"strings"
+	"time"
+)
+
+type Cmd struct {
+	*flaggy.Subcommand
+	*initCmd
+	*showCmd
+	*tokenCmd
+	*listCmd
+	*decideCmd
+	*revokeCmd
+}
+
+func NewCmd() *Cmd {
+	caCmd := &Cmd{}
+
+	cmd := flaggy.NewSubcommand("ca")
+	cmd.Description = "Manage the built-in certificate authority used for client certificates"
+
+	flaggy.AttachSubcommand(cmd, 1)
+
+	caCmd.Subcommand = cmd
+	caCmd.initCmd = newInitCmd(cmd)
+	caCmd.showCmd = newShowCmd(cmd)
+	caCmd.tokenCmd = newTokenCmd(cmd)
+	caCmd.listCmd = newListCmd(cmd)
+	caCmd.decideCmd = newDecideCmd(cmd)
+	caCmd.revokeCmd = newRevokeCmd(cmd)
+
+	return caCmd
+}
+
+func (cmd *Cmd) Run(state *config.State) {
+	state.Config().VerifyConnectionConfig()
+
+	if cmd.initCmd.Used {
+		cmd.initCmd.run(state)
+	} else if cmd.showCmd.Used {
+		cmd.showCmd.run(state)
+	} else if cmd.tokenCmd.Used {
+		cmd.tokenCmd.run(state)
+	} else if cmd.listCmd.Used {
+		cmd.listCmd.run(state)
+	} else if cmd.decideCmd.approve.Used {
+		cmd.decideCmd.run(state, true)
+	} else if cmd.decideCmd.deny.Used {
+		cmd.decideCmd.run(state, false)
+	} else if cmd.revokeCmd.Used {
+		cmd.revokeCmd.run(state)
+	} else {
+		flaggy.ShowHelpAndExit("")
+	}
+}
+
+type initCmd struct {
+	*flaggy.Subcommand
+	commonName   string
+	validityDays int
+}
+
+func newInitCmd(parent *flaggy.Subcommand) *initCmd {
+	iCmd := &initCmd{
+		commonName:   "credstore CA",
+		validityDays: 3650,
+	}
+
+	cmd := flaggy.NewSubcommand("init")
+	cmd.Description = "Creates the certificate authority (the key never leaves the vault)"
+
+	cmd.String(&iCmd.commonName, "n", "name", "Common name of the CA certificate")
+	cmd.Int(&iCmd.validityDays, "v", "validity", "Validity of the CA certificate in days")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	iCmd.Subcommand = cmd
+
+	return iCmd
+}
+
+func (cmd *initCmd) run(state *config.State) {
+	adminCredentials := state.Config().AdminCredentials()
+
+	info, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.AuthorityInfo, error) {
+			return c.InitAuthority(&proto.AuthorityCreation{
+				Credentials:  adminCredentials,
+				CommonName:   strings.TrimSpace(cmd.commonName),
+				ValidityDays: int64(cmd.validityDays),
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize certificate authority")
+	}
+
+	log.Info().Msgf("Certificate authority initialized, valid until %s", time.UnixMilli(info.GetExpiresAt()).Format(time.RFC3339))
+}
+
+type showCmd struct {
+	*flaggy.Subcommand
+}
+
+func newShowCmd(parent *flaggy.Subcommand) *showCmd {
+	sCmd := &showCmd{}
+
+	cmd := flaggy.NewSubcommand("show")
+	cmd.Description = "Prints the PEM encoded CA certificate"
+
+	parent.AttachSubcommand(cmd, 1)
+
+	sCmd.Subcommand = cmd
+
+	return sCmd
+}
+
+func (cmd *showCmd) run(state *config.State) {
+	info, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.AuthorityInfo, error) {
+			return c.GetAuthority()
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to retrieve certificate authority")
+	}
+
+	if !info.GetIsInitialized() {
+		log.Fatal().Msg("Certificate authority is not initialized")
+	}
+
+	_, _ = os.Stdout.WriteString(info.GetCertificate())
+}
+
+type tokenCmd struct {
+	*flaggy.Subcommand
+	commonName string
+	ttl        time.Duration
+}
+
+func newTokenCmd(parent *flaggy.Subcommand) *tokenCmd {
+	tCmd := &tokenCmd{
+		ttl: 24 * time.Hour,
+	}
+
+	cmd := flaggy.NewSubcommand("token")
+	cmd.Description = "Creates a one-time token which lets a host enroll without approval"
+
+	cmd.String(&tCmd.commonName, "n", "name", "Restrict the token to this common name")
+	cmd.Duration(&tCmd.ttl, "t", "ttl", "How long the token remains valid")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	tCmd.Subcommand = cmd
+
+	return tCmd
+}
+
+func (cmd *tokenCmd) run(state *config.State) {
+	adminCredentials := state.Config().AdminCredentials()
+
+	token, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.EnrollmentToken, error) {
+			return c.CreateEnrollmentToken(&proto.EnrollmentTokenCreation{
+				Credentials: adminCredentials,
+				CommonName:  strings.TrimSpace(cmd.commonName),
+				TtlSeconds:  int64(cmd.ttl.Seconds()),
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create enrollment token")
+	}
+
+	log.Info().Msgf("Enrollment token (valid until %s):", time.UnixMilli(token.GetExpiresAt()).Format(time.RFC3339))
+	fmt.Println(token.GetToken())
+}
+
+type listCmd struct {
+	*flaggy.Subcommand
+	all bool
+}
+
+func newListCmd(parent *flaggy.Subcommand) *listCmd {
+	lCmd := &listCmd{}
+
+	cmd := flaggy.NewSubcommand("list")
+	cmd.ShortName = "ls"
+	cmd.Description = "Lists pending enrollments"
+
+	cmd.Bool(&lCmd.all, "a", "all", "Also list issued, denied and revoked certificates")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	lCmd.Subcommand = cmd
+
+	return lCmd
+}
+
+func (cmd *listCmd) run(state *config.State) {
+	adminCredentials := state.Config().AdminCredentials()
+
+	enrollments, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) ([]*proto.Enrollment, error) {
+			return c.ListEnrollments(&proto.EnrollmentSearch{
+				Credentials:   adminCredentials,
+				IncludeIssued: cmd.all,
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to retrieve enrollments")
+	}
+
+	log.Info().Msgf("Retrieved %d enrollments", len(enrollments))
+
+	for _, enrollment := range enrollments {
+		fmt.Printf(
+			"%s\t%s\t%s\t%s\t%s\n",
+			enrollment.GetId(),
+			enrollment.GetCommonName(),
+			enrollment.GetStatus().String(),
+			enrollment.GetSerial(),
+			time.UnixMilli(enrollment.GetExpiresAt()).Format(time.RFC3339),
+		)
+	}
+}
+
+type decideCmd struct {
+	approve      *flaggy.Subcommand
+	deny         *flaggy.Subcommand
+	enrollmentId string
+}
+
+func newDecideCmd(parent *flaggy.Subcommand) *decideCmd {
+	dCmd := &decideCmd{}
+
+	approve := flaggy.NewSubcommand("approve")
+	approve.Description = "Approves a pending enrollment and issues its certificate"
+	approve.AddPositionalValue(&dCmd.enrollmentId, "ENROLLMENT-ID", 1, true, "The ID of the enrollment")
+
+	deny := flaggy.NewSubcommand("deny")
+	deny.Description = "Denies a pending enrollment"
+	deny.AddPositionalValue(&dCmd.enrollmentId, "ENROLLMENT-ID", 1, true, "The ID of the enrollment")
+
+	parent.AttachSubcommand(approve, 1)
+	parent.AttachSubcommand(deny, 1)
+
+	dCmd.approve = approve
+	dCmd.deny = deny
+
+	return dCmd
+}
+
+func (cmd *decideCmd) run(state *config.State, approve bool) {
+	adminCredentials := state.Config().AdminCredentials()
+
+	enrollment, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.Enrollment, error) {
+			return c.DecideEnrollment(&proto.EnrollmentDecision{
+				Credentials: adminCredentials,
+				Id:          cmd.enrollmentId,
+				Approve:     approve,
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to decide on enrollment")
+	}
+
+	if approve {
+		log.Info().Msgf("Issued certificate %s for %s", enrollment.GetSerial(), enrollment.GetCommonName())
+	} else {
+		log.Info().Msgf("Denied enrollment for %s", enrollment.GetCommonName())
+	}
+}
+
+type revokeCmd struct {
+	*flaggy.Subcommand
+	serial string
+}
+
+func newRevokeCmd(parent *flaggy.Subcommand) *revokeCmd {
+	rCmd := &revokeCmd{}
+
+	cmd := flaggy.NewSubcommand("revoke")
+	cmd.Description = "Revokes an issued client certificate"
+
+	cmd.AddPositionalValue(&rCmd.serial, "SERIAL", 1, true, "Serial number of the certificate")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	rCmd.Subcommand = cmd
+
+	return rCmd
+}
+
+func (cmd *revokeCmd) run(state *config.State) {
+	doRevoke, err := utils.PromptConfirm(fmt.Sprintf("Confirm revocation of certificate %s", cmd.serial), false)
+	if err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	if !doRevoke {
+		log.Info().Msg("Not revoking certificate, user aborted")
+		return
+	}
+
+	adminCredentials := state.Config().AdminCredentials()
+
+	enrollment, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.Enrollment, error) {
+			return c.RevokeCertificate(&proto.CertificateRevocation{
+				Credentials: adminCredentials,
+				Serial:      strings.ToLower(strings.TrimSpace(cmd.serial)),
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to revoke certificate")
+	}
+
+	log.Info().Msgf("Revoked certificate %s for %s", enrollment.GetSerial(), enrollment.GetCommonName())
+}
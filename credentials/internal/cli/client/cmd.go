@@ -16,6 +16,9 @@
 package client
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/integrii/flaggy"
@@ -24,6 +27,7 @@ import (
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
 	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"golang.org/x/crypto/ssh"
 	"slices"
 	"strings"
 )
@@ -32,19 +36,23 @@ type Cmd struct {
 	*flaggy.Subcommand
 	*createClientCredentialsCmd
 	*deleteClientCredentialsCmd
+	*enrollCmd
+	*renewCmd
 }
 
 func NewCmd() *Cmd {
 	clientCmd := &Cmd{}
 
 	cmd := flaggy.NewSubcommand("client")
-	cmd.Description = "Create and delete client credentials"
+	cmd.Description = "Create and delete client credentials, enroll client certificates"
 
 	flaggy.AttachSubcommand(cmd, 1)
 
 	clientCmd.Subcommand = cmd
 	clientCmd.createClientCredentialsCmd = newCreateClientCredentialsCmd(cmd)
 	clientCmd.deleteClientCredentialsCmd = newDeleteClientCredentialsCmd(cmd)
+	clientCmd.enrollCmd = newEnrollCmd(cmd)
+	clientCmd.renewCmd = newRenewCmd(cmd)
 
 	return clientCmd
 }
@@ -56,6 +64,10 @@ func (cmd *Cmd) Run(state *config.State) {
 		cmd.createClientCredentialsCmd.run(state)
 	} else if cmd.deleteClientCredentialsCmd.Used {
 		cmd.deleteClientCredentialsCmd.run(state)
+	} else if cmd.enrollCmd.Used {
+		cmd.enrollCmd.run(state)
+	} else if cmd.renewCmd.Used {
+		cmd.renewCmd.run(state)
 	} else {
 		flaggy.ShowHelpAndExit("")
 	}
@@ -64,6 +76,7 @@ func (cmd *Cmd) Run(state *config.State) {
 type createClientCredentialsCmd struct {
 	*flaggy.Subcommand
 	description string
+	publicKey   string
 }
 
 func newCreateClientCredentialsCmd(parent *flaggy.Subcommand) *createClientCredentialsCmd {
@@ -73,6 +86,7 @@ func newCreateClientCredentialsCmd(parent *flaggy.Subcommand) *createClientCrede
 	cmd.Description = "Creates a new set of client credentials"
 
 	cmd.String(&createCmd.description, "d", "description", "Description for the client credentials")
+	cmd.String(&createCmd.publicKey, "k", "public-key", "Register an Ed25519 public key (base64 or OpenSSH format) instead of creating a secret")
 
 	parent.AttachSubcommand(cmd, 1)
 
@@ -87,18 +101,23 @@ func (cmd *createClientCredentialsCmd) run(state *config.State) {
 		log.Fatal().Msg("No description provided")
 	}
 
-	passphrase := state.Config().Passphrase
-	if passphrase == nil {
-		passphrase = utils.AskForPassphrase()
-		defer passphrase.Destroy()
+	var publicKey []byte
+	if cmd.publicKey != "" {
+		var err error
+		if publicKey, err = parsePublicKey(cmd.publicKey); err != nil {
+			log.Fatal().Err(err).Msg("Failed to parse public key")
+		}
 	}
 
+	adminCredentials := state.Config().AdminCredentials()
+
 	credentials, err := grpcclient.Run(
 		state.Config(),
 		func(c grpcclient.GrpcClient) (*proto.ClientCredentials, error) {
 			return c.CreateClientCredentials(&proto.ClientCreation{
-				Credentials: &proto.AdminCredentials{Passphrase: passphrase.String()},
+				Credentials: adminCredentials,
 				Description: actualDescription,
+				PublicKey:   publicKey,
 			})
 		},
 	)
@@ -107,11 +126,51 @@ func (cmd *createClientCredentialsCmd) run(state *config.State) {
 		log.Fatal().Err(err).Msg("Failed to create client credentials")
 	}
 
+	if publicKey != nil {
+		log.Info().Msgf("Registered client key: %s", actualDescription)
+		log.Info().Msgf("Client ID: %s", credentials.GetId())
+		return
+	}
+
 	log.Info().Msgf("Created client credentials: %s", actualDescription)
 	log.Info().Msgf("Client ID:     %s", credentials.GetId())
 	log.Info().Msgf("Client Secret: %s", credentials.GetSecret())
 }
 
+func parsePublicKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "ssh-ed25519 ") {
+		sshKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(value))
+		if err != nil {
+			return nil, err
+		}
+
+		cryptoKey, ok := sshKey.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, errors.New("unsupported SSH key")
+		}
+
+		publicKey, ok := cryptoKey.CryptoPublicKey().(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("not an Ed25519 key")
+		}
+
+		return publicKey, nil
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("not an Ed25519 key")
+	}
+
+	return publicKey, nil
+}
+
 type deleteClientCredentialsCmd struct {
 	*flaggy.Subcommand
 	clientId string
@@ -148,17 +207,13 @@ func (cmd *deleteClientCredentialsCmd) run(state *config.State) {
 		return
 	}
 
-	passphrase := state.Config().Passphrase
-	if passphrase == nil {
-		passphrase = utils.AskForPassphrase()
-		defer passphrase.Destroy()
-	}
+	adminCredentials := state.Config().AdminCredentials()
 
 	deletedIds, err := grpcclient.Run(
 		state.Config(),
 		func(c grpcclient.GrpcClient) ([]string, error) {
 			return c.DeleteVaultItems(&proto.ItemDeletion{
-				Credentials: &proto.AdminCredentials{Passphrase: passphrase.String()},
+				Credentials: adminCredentials,
 				Id:          []string{clientId.String()},
 			})
 		},
@@ -0,0 +1,267 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	clientCertFile   = "client.crt"
+	clientKeyFile    = "client.key"
+	pendingKeySuffix = ".pending"
+)
+
+type enrollCmd struct {
+	*flaggy.Subcommand
+	commonName string
+	token      string
+}
+
+func newEnrollCmd(parent *flaggy.Subcommand) *enrollCmd {
+	eCmd := &enrollCmd{}
+
+	cmd := flaggy.NewSubcommand("enroll")
+	cmd.Description = "Requests a client certificate from the store's certificate authority"
+
+	cmd.String(&eCmd.commonName, "n", "name", "Common name of the certificate (defaults to the hostname)")
+	cmd.String(&eCmd.token, "t", "token", "One-time enrollment token, skips admin approval")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	eCmd.Subcommand = cmd
+
+	return eCmd
+}
+
+func (cmd *enrollCmd) run(state *config.State) {
+	if state.Config().PendingEnrollmentId != "" {
+		cmd.checkPending(state)
+		return
+	}
+
+	commonName := strings.TrimSpace(cmd.commonName)
+	if commonName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to determine hostname")
+		}
+
+		commonName = hostname
+	}
+
+	keyPath := filepath.Join(state.ConfigDir(), clientKeyFile+pendingKeySuffix)
+
+	csr, err := createKeyAndCsr(keyPath, commonName)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create certificate signing request")
+	}
+
+	enrollment, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.Enrollment, error) {
+			return c.EnrollClient(&proto.EnrollmentRequest{
+				Csr:   csr,
+				Token: strings.TrimSpace(cmd.token),
+			})
+		},
+	)
+
+	if err != nil {
+		_ = os.Remove(keyPath)
+		log.Fatal().Err(err).Msg("Failed to enroll")
+	}
+
+	if enrollment.GetStatus() == proto.EnrollmentStatus_APPROVED {
+		installCertificate(state, enrollment)
+		return
+	}
+
+	state.Config().PendingEnrollmentId = enrollment.GetId()
+	storeConfig(state)
+
+	log.Info().Msgf("Enrollment %s is pending approval by an admin", enrollment.GetId())
+	log.Info().Msg("Run this command again once it has been approved")
+}
+
+func (cmd *enrollCmd) checkPending(state *config.State) {
+	enrollment, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.Enrollment, error) {
+			return c.GetEnrollment(&proto.EnrollmentQuery{Id: state.Config().PendingEnrollmentId})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to retrieve pending enrollment")
+	}
+
+	switch enrollment.GetStatus() {
+	case proto.EnrollmentStatus_PENDING:
+		log.Info().Msgf("Enrollment %s is still pending approval", enrollment.GetId())
+	case proto.EnrollmentStatus_APPROVED:
+		installCertificate(state, enrollment)
+	default:
+		_ = os.Remove(filepath.Join(state.ConfigDir(), clientKeyFile+pendingKeySuffix))
+
+		state.Config().PendingEnrollmentId = ""
+		storeConfig(state)
+
+		log.Fatal().Msgf("Enrollment %s was %s", enrollment.GetId(), strings.ToLower(enrollment.GetStatus().String()))
+	}
+}
+
+type renewCmd struct {
+	*flaggy.Subcommand
+}
+
+func newRenewCmd(parent *flaggy.Subcommand) *renewCmd {
+	rCmd := &renewCmd{}
+
+	cmd := flaggy.NewSubcommand("renew")
+	cmd.Description = "Renews the client certificate before it expires"
+
+	parent.AttachSubcommand(cmd, 1)
+
+	rCmd.Subcommand = cmd
+
+	return rCmd
+}
+
+func (cmd *renewCmd) run(state *config.State) {
+	if state.Config().ClientCertFile == "" {
+		log.Fatal().Msg("No client certificate configured, enroll first")
+	}
+
+	current, err := readCertificate(state.Config().ClientCertFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to read current client certificate")
+	}
+
+	if time.Now().After(current.NotAfter) {
+		log.Fatal().Msg("Client certificate has expired, enroll again")
+	}
+
+	keyPath := filepath.Join(state.ConfigDir(), clientKeyFile+pendingKeySuffix)
+
+	csr, err := createKeyAndCsr(keyPath, current.Subject.CommonName)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create certificate signing request")
+	}
+
+	enrollment, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.Enrollment, error) {
+			return c.RenewCertificate(&proto.RenewalRequest{Csr: csr})
+		},
+	)
+
+	if err != nil {
+		_ = os.Remove(keyPath)
+		log.Fatal().Err(err).Msg("Failed to renew client certificate")
+	}
+
+	installCertificate(state, enrollment)
+}
+
+func createKeyAndCsr(keyPath string, commonName string) (string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+	if err = os.WriteFile(keyPath, keyPem, 0600); err != nil {
+		return "", err
+	}
+
+	csrDer, err := x509.CreateCertificateRequest(
+		rand.Reader,
+		&x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}},
+		key,
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDer})), nil
+}
+
+func installCertificate(state *config.State, enrollment *proto.Enrollment) {
+	certPath := filepath.Join(state.ConfigDir(), clientCertFile)
+	keyPath := filepath.Join(state.ConfigDir(), clientKeyFile)
+
+	err := os.WriteFile(certPath, []byte(enrollment.GetCertificate()), 0600)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to write client certificate")
+	}
+
+	err = os.Rename(keyPath+pendingKeySuffix, keyPath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to install client key")
+	}
+
+	state.Config().ClientCertFile = certPath
+	state.Config().ClientKeyFile = keyPath
+	state.Config().PendingEnrollmentId = ""
+	storeConfig(state)
+
+	log.Info().Msgf("Installed client certificate %s", enrollment.GetSerial())
+	log.Info().Msgf("Valid until %s", time.UnixMilli(enrollment.GetExpiresAt()).Format(time.RFC3339))
+}
+
+func readCertificate(path string) (*x509.Certificate, error) {
+	certPem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(certPem)
+	if block == nil {
+		return nil, os.ErrInvalid
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func storeConfig(state *config.State) {
+	configDir := state.ConfigDir()
+
+	err := config.Store(&configDir, *state.Config())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to store configuration")
+	}
+}
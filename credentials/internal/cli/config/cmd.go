@@ -21,6 +21,7 @@ import (
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/conn"
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
 	"strconv"
+	"strings"
 )
 
 type Cmd struct {
@@ -42,16 +43,35 @@ func NewCmd() *Cmd {
 
 func (cmd *Cmd) Run(state *State) {
 	if state.config == nil {
-		state.config = &Config{}
+		state.config = &Config{TimeoutOverride: state.timeoutOverride}
 	}
 
-	hostname, err := utils.Prompt("Enter the hostname", state.Config().StoreHost)
+	hostname, err := utils.Prompt("Enter the hostname (or unix:///path/to/socket)", state.Config().StoreHost)
 	if err != nil {
 		log.Fatal().Err(err).Send()
 	}
 
 	state.config.StoreHost = hostname
 
+	if state.config.IsUnixSocket() {
+		if !strings.HasPrefix(hostname, unixSocketPrefix+"/") {
+			log.Fatal().Msg("Unix socket path must be absolute, e.g. unix:///run/credstore.sock")
+		}
+
+		state.config.StorePort = nil
+		state.config.UseTls = false
+	} else if !cmd.configureTcp(state) {
+		return
+	}
+
+	cmd.storeConfig(state)
+}
+
+// configureTcp prompts for the port and checks whether the store uses TLS,
+// returns false if the user aborted
+func (cmd *Cmd) configureTcp(state *State) bool {
+	hostname := state.config.StoreHost
+
 	currentPort := ""
 	if state.config.StorePort != nil {
 		currentPort = strconv.Itoa(int(*state.config.StorePort))
@@ -83,7 +103,7 @@ func (cmd *Cmd) Run(state *State) {
 
 		if !doProceed {
 			log.Info().Msg("Store isn't using TLS, user aborted")
-			return
+			return false
 		}
 	}
 
@@ -95,6 +115,10 @@ func (cmd *Cmd) Run(state *State) {
 		state.config.StorePort = nil
 	}
 
+	return true
+}
+
+func (cmd *Cmd) storeConfig(state *State) {
 	isProd, err := checkIfProd(state.config)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to check whether store is in production mode")
@@ -112,85 +136,19 @@ func (cmd *Cmd) Run(state *State) {
 		}
 	}
 
-	err = Store(&state.configDir, *state.config)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to store configuration")
+	currentAdmin := state.config.AdminName
+	if currentAdmin == "" {
+		currentAdmin = "root"
 	}
-}
 
-type LoginCmd struct {
-	*flaggy.Subcommand
-	plainText      bool
-	passphraseMode bool
-}
-
-func NewLoginCmd() *LoginCmd {
-	loginCmd := &LoginCmd{
-		plainText:      false,
-		passphraseMode: false,
+	adminName, err := utils.Prompt("Enter the admin name", currentAdmin)
+	if err != nil {
+		log.Fatal().Err(err).Send()
 	}
 
-	cmd := flaggy.NewSubcommand("login")
-	cmd.Description = "Log in to a credential store"
+	state.config.AdminName = adminName
 
-	cmd.Bool(&loginCmd.plainText, "t", "plain-text", "Store credentials in plain text (except passphrase)")
-	cmd.Bool(&loginCmd.passphraseMode, "p", "passphrase", "login using passphrase")
-
-	flaggy.AttachSubcommand(cmd, 1)
-
-	loginCmd.Subcommand = cmd
-
-	return loginCmd
-}
-
-func (cmd *LoginCmd) Run(state *State) {
-	state.config.VerifyConnectionConfig()
-
-	if cmd.passphraseMode {
-		state.config.StorePassphraseInKeyring = !cmd.plainText
-
-		if !cmd.plainText {
-			passphrase, err := utils.PromptSecure("Enter passphrase")
-			if err != nil {
-				log.Fatal().Err(err).Msg("Failed to read passphrase")
-			}
-
-			defer passphrase.Destroy()
-
-			state.config.Passphrase = passphrase
-		} else {
-			log.Warn().Msg("Refusing to store passphrase in plain text, will ask for it when needed")
-		}
-	} else {
-		clientId, err := utils.PromptSecure("Enter Client ID")
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to read client ID")
-		}
-
-		defer clientId.Destroy()
-
-		clientSecret, err := utils.PromptSecure("Enter Client Secret")
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to read client secret")
-		}
-
-		defer clientSecret.Destroy()
-
-		if cmd.plainText {
-			state.config.Credentials = &Credentials{
-				Id:     clientId.String(),
-				Secret: clientSecret.String(),
-			}
-		} else {
-			state.config.Credentials = nil
-			state.config.SecureCredentials = &SecureCredentials{
-				Id:     clientId,
-				Secret: clientSecret,
-			}
-		}
-	}
-
-	err := Store(&state.configDir, *state.config)
+	err = Store(&state.configDir, *state.config)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to store configuration")
 	}
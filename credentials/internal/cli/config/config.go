@@ -20,21 +20,71 @@ import (
 	"github.com/awnumar/memguard"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	StoreHost                string
-	StorePort                *uint16
-	UseTls                   bool
-	Credentials              *Credentials
-	SecureCredentials        *SecureCredentials `toml:"-"`
-	StorePassphraseInKeyring bool
+	StoreHost         string
+	StorePort         *uint16
+	UseTls            bool
+	AdminName         string
+	Credentials       *Credentials
+	SecureCredentials *SecureCredentials `toml:"-"`
+	// KeyClientId is the client authenticating with the Ed25519 key in
+	// ClientKey, which takes precedence over any client secret
+	KeyClientId string                 `toml:",omitempty"`
+	ClientKey   *memguard.LockedBuffer `toml:"-"`
+	// Deprecated: the passphrase is no longer kept in the keyring, a session
+	// token is stored instead. Only read to clean up old keyring entries.
+	StorePassphraseInKeyring bool                   `toml:",omitempty"`
 	Passphrase               *memguard.LockedBuffer `toml:"-"`
+	SessionExpiresAt         int64
+	SessionScopes            []string
+	SessionToken             *memguard.LockedBuffer `toml:"-"`
+	ClientCertFile           string
+	ClientKeyFile            string
+	PendingEnrollmentId      string
+	// Timeout is the deadline of each call to the store, defaults to 30s
+	Timeout *store.Duration `toml:",omitempty"`
+	// TimeoutOverride is set by --timeout and takes precedence over Timeout
+	TimeoutOverride time.Duration `toml:"-"`
+	Keepalive       *Keepalive    `toml:",omitempty"`
+}
+
+// Keepalive configures the pings sent while calls are active, which detect
+// broken connections during long-running calls
+type Keepalive struct {
+	// time without activity after which a ping is sent, defaults to 1m
+	Time *store.Duration `toml:",omitempty"`
+	// time to wait for the acknowledgement of a ping, defaults to 20s
+	Timeout *store.Duration `toml:",omitempty"`
+}
+
+const (
+	DefaultTimeout          = 30 * time.Second
+	DefaultKeepaliveTime    = time.Minute
+	DefaultKeepaliveTimeout = 20 * time.Second
+)
+
+const unixSocketPrefix = "unix://"
+
+func (config *Config) IsUnixSocket() bool {
+	return strings.HasPrefix(config.StoreHost, unixSocketPrefix)
 }
 
 func (config *Config) HostString() string {
+	if config.IsUnixSocket() {
+		return config.StoreHost
+	}
+
 	var storePort uint16
 	if config.StorePort != nil {
 		storePort = *config.StorePort
@@ -44,7 +94,7 @@ func (config *Config) HostString() string {
 		storePort = 80
 	}
 
-	return fmt.Sprintf("%s:%d", config.StoreHost, storePort)
+	return net.JoinHostPort(config.StoreHost, strconv.Itoa(int(storePort)))
 }
 
 func (config *Config) Destroy() {
@@ -56,12 +106,52 @@ func (config *Config) Destroy() {
 		config.Passphrase.Destroy()
 	}
 
+	if config.SessionToken != nil {
+		config.SessionToken.Destroy()
+	}
+
+	if config.ClientKey != nil {
+		config.ClientKey.Destroy()
+	}
+
 	if config.SecureCredentials != nil {
 		config.SecureCredentials.Id.Destroy()
 		config.SecureCredentials.Secret.Destroy()
 	}
 }
 
+// CallTimeout returns the deadline of each call to the store
+func (config *Config) CallTimeout() time.Duration {
+	if config.TimeoutOverride > 0 {
+		return config.TimeoutOverride
+	}
+
+	if config.Timeout != nil {
+		return config.Timeout.Duration
+	}
+
+	return DefaultTimeout
+}
+
+func (config *Config) HasSession() bool {
+	return config.SessionToken != nil && time.Now().Before(time.UnixMilli(config.SessionExpiresAt))
+}
+
+// AdminCredentials returns the credentials to send along with admin calls.
+// While a session is active the passphrase isn't needed, since the session
+// token is sent as metadata instead.
+func (config *Config) AdminCredentials() *proto.AdminCredentials {
+	if config.HasSession() {
+		return &proto.AdminCredentials{}
+	}
+
+	if config.Passphrase == nil {
+		config.Passphrase = utils.AskForPassphrase()
+	}
+
+	return &proto.AdminCredentials{Passphrase: config.Passphrase.String(), Name: config.AdminName}
+}
+
 type Credentials struct {
 	Id     string
 	Secret string
@@ -117,12 +207,24 @@ func (config *Config) storeSecureValues() {
 		}()
 	}
 
-	if config.StorePassphraseInKeyring && config.Passphrase != nil {
-		passphraseKey := fmt.Sprintf("%s:%d-passphrase", config.StoreHost, storePort)
-		if err = setInKeyring(passphraseKey, config.Passphrase); err != nil {
-			log.Warn().Err(err).Msgf("Failed to store passphrase for %s:%d", config.StoreHost, storePort)
+	if config.ClientKey != nil {
+		keyKey := fmt.Sprintf("%s:%d-client-key", config.StoreHost, storePort)
+		if err = setInKeyring(keyKey, config.ClientKey); err != nil {
+			log.Warn().Err(err).Msgf("Failed to store client key for %s:%d", config.StoreHost, storePort)
 		}
 	}
+
+	sessionKey := fmt.Sprintf("%s:%d-session", config.StoreHost, storePort)
+	if config.SessionToken != nil {
+		if err = setInKeyring(sessionKey, config.SessionToken); err != nil {
+			log.Warn().Err(err).Msgf("Failed to store session for %s:%d", config.StoreHost, storePort)
+			config.SessionExpiresAt = 0
+		}
+	} else if config.SessionExpiresAt != 0 {
+		_ = setInKeyring(sessionKey, nil)
+		config.SessionExpiresAt = 0
+		config.SessionScopes = nil
+	}
 }
 
 func Load(path string) (*Config, error) {
@@ -182,16 +284,27 @@ func (config *Config) loadSecureValues() {
 		}
 	}
 
+	keyKey := fmt.Sprintf("%s:%d-client-key", config.StoreHost, storePort)
+	if config.ClientKey, err = getFromKeyring(keyKey); err != nil {
+		log.Warn().Err(err).Msgf("Failed to load client key for %s:%d", config.StoreHost, storePort)
+	}
+
 	if config.StorePassphraseInKeyring {
 		passphraseId := fmt.Sprintf("%s:%d-passphrase", config.StoreHost, storePort)
-		var passphrase *memguard.LockedBuffer
-
-		if passphrase, err = getFromKeyring(passphraseId); err != nil {
-			log.Warn().Err(err).Msgf("Failed to load passphrase for %s:%d", config.StoreHost, storePort)
+		if err = setInKeyring(passphraseId, nil); err != nil {
+			log.Warn().Err(err).Msgf("Failed to remove passphrase for %s:%d from keyring", config.StoreHost, storePort)
+		} else {
+			log.Info().Msg("Removed stored passphrase from keyring, use `cred login -p` to start a session instead")
 		}
 
-		if passphrase != nil {
-			config.Passphrase = passphrase
+		config.StorePassphraseInKeyring = false
+	}
+
+	if config.SessionExpiresAt != 0 && time.Now().Before(time.UnixMilli(config.SessionExpiresAt)) {
+		sessionKey := fmt.Sprintf("%s:%d-session", config.StoreHost, storePort)
+
+		if config.SessionToken, err = getFromKeyring(sessionKey); err != nil {
+			log.Warn().Err(err).Msgf("Failed to load session for %s:%d", config.StoreHost, storePort)
 		}
 	}
 }
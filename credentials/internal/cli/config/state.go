@@ -15,9 +15,12 @@
 
 package config
 
+import "time"
+
 type State struct {
-	configDir string
-	config    *Config
+	configDir       string
+	config          *Config
+	timeoutOverride time.Duration
 }
 
 func NewState(configDir string, config *Config) *State {
@@ -34,3 +37,13 @@ func (s *State) ConfigDir() string {
 func (s *State) Config() *Config {
 	return s.config
 }
+
+// SetTimeoutOverride applies --timeout to the config, also to the one created
+// by configure if none exists yet
+func (s *State) SetTimeoutOverride(timeout time.Duration) {
+	s.timeoutOverride = timeout
+
+	if s.config != nil {
+		s.config.TimeoutOverride = timeout
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState_SetTimeoutOverride(t *testing.T) {
+	state := NewState(t.TempDir(), &Config{})
+	state.SetTimeoutOverride(5 * time.Second)
+
+	assert.Equal(t, 5*time.Second, state.Config().CallTimeout())
+}
+
+func TestState_SetTimeoutOverride_NoConfig(t *testing.T) {
+	configDir := t.TempDir()
+
+	path, err := EnsureConfigPath(&configDir)
+	require.NoError(t, err)
+
+	config, err := Load(path)
+	require.NoError(t, err)
+	require.Nil(t, config)
+
+	state := NewState(configDir, config)
+	state.SetTimeoutOverride(5 * time.Second)
+
+	assert.Nil(t, state.Config())
+}
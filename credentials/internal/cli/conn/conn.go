@@ -19,8 +19,8 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
-	"fmt"
 	"net"
+	"strconv"
 	"time"
 )
 
@@ -34,7 +34,7 @@ func CheckIfTls(storeHost string, storePort *uint16) (bool, error) {
 		actualPort = *storePort
 	}
 
-	addr := fmt.Sprintf("%s:%d", storeHost, actualPort)
+	addr := net.JoinHostPort(storeHost, strconv.Itoa(int(actualPort)))
 	conf := &tls.Config{
 		InsecureSkipVerify: true,
 	}
@@ -24,18 +24,55 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 type GrpcClient interface {
 	GetInfo() (*proto.StoreInfo, error)
+	InitializeVault(initialization *proto.VaultInitialization) (*proto.VaultInfo, error)
 	UnlockVault(credentials *proto.AdminCredentials) error
 	LockVault() error
+	PromoteReplica(credentials *proto.AdminCredentials) (*proto.StoreInfo, error)
+	GetStatus(credentials *proto.AdminCredentials) (*proto.StoreStatus, error)
+	Login(request *proto.LoginRequest) (*proto.Session, error)
+	Logout() error
+	AddAdmin(creation *proto.AdminCreation) (*proto.AdminInfo, error)
+	RemoveAdmin(removal *proto.AdminRemoval) error
+	ListAdmins(search *proto.AdminSearch) ([]*proto.AdminInfo, error)
 	SetRecoveryRecipient(recipient *proto.RecoveryRecipient) error
 	CreateVaultItem(creation *proto.ItemCreation) (*proto.Item, error)
-	ListVaultItems(search *proto.ItemSearch) ([]*proto.Item, error)
+	GenerateVaultItem(generation *proto.ItemGeneration) (*proto.GeneratedItem, error)
+	ListVaultItems(search *proto.ItemSearch) (*ItemPage, error)
 	DeleteVaultItems(deletion *proto.ItemDeletion) ([]string, error)
 	ReadVaultItem(request *proto.ItemRequest) (*proto.ItemValue, error)
+	ReadVaultItems(request *proto.ItemsRequest) ([]*proto.ItemResult, error)
+	ShareVaultItem(share *proto.ItemShare) (*proto.SharedItem, error)
+	SetItemApproval(setting *proto.ItemApprovalSetting) (*proto.Item, error)
+	WatchVault(request *proto.WatchRequest, handle func(*proto.WatchEvent) error) error
+	ListApprovals(search *proto.ApprovalSearch) ([]*proto.ApprovalRequest, error)
+	DecideApproval(decision *proto.ApprovalDecision) (*proto.ApprovalRequest, error)
 	CreateClientCredentials(creation *proto.ClientCreation) (*proto.ClientCredentials, error)
+	GetChallenge(request *proto.ChallengeRequest) (*proto.Challenge, error)
+	QueryAuditLog(query *proto.AuditQuery) ([]*proto.AuditEntry, error)
+	VerifyAuditLog(request *proto.AuditVerificationRequest) (*proto.AuditVerification, error)
+	InitAuthority(creation *proto.AuthorityCreation) (*proto.AuthorityInfo, error)
+	GetAuthority() (*proto.AuthorityInfo, error)
+	CreateEnrollmentToken(creation *proto.EnrollmentTokenCreation) (*proto.EnrollmentToken, error)
+	EnrollClient(request *proto.EnrollmentRequest) (*proto.Enrollment, error)
+	GetEnrollment(query *proto.EnrollmentQuery) (*proto.Enrollment, error)
+	ListEnrollments(search *proto.EnrollmentSearch) ([]*proto.Enrollment, error)
+	DecideEnrollment(decision *proto.EnrollmentDecision) (*proto.Enrollment, error)
+	RevokeCertificate(revocation *proto.CertificateRevocation) (*proto.Enrollment, error)
+	RenewCertificate(request *proto.RenewalRequest) (*proto.Enrollment, error)
+}
+
+// ItemPage is a page of the items matching a search, the counts are
+// missing if the store doesn't support paging
+type ItemPage struct {
+	Items         []*proto.Item
+	Total         int
+	Matched       int
+	NextPageToken string
 }
 
 func Run[T any](config *config.Config, action func(client GrpcClient) (T, error)) (T, error) {
@@ -46,11 +83,29 @@ func Run[T any](config *config.Config, action func(client GrpcClient) (T, error)
 			skipVerify = true
 		}
 
-		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: skipVerify})))
+		tlsConfig := &tls.Config{InsecureSkipVerify: skipVerify}
+
+		if config.ClientCertFile != "" && config.ClientKeyFile != "" {
+			clientCert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to load client certificate")
+			}
+
+			tlsConfig.Certificates = []tls.Certificate{clientCert}
+		}
+
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	} else {
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	}
 
+	opts = append(
+		opts,
+		grpc.WithKeepaliveParams(keepaliveParams(config.Keepalive)),
+		grpc.WithChainUnaryInterceptor(unaryTimeoutInterceptor(config.CallTimeout()), unaryRequestIdInterceptor),
+		grpc.WithChainStreamInterceptor(streamTimeoutInterceptor(config.CallTimeout())),
+	)
+
 	conn, err := grpc.NewClient(config.HostString(), opts...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create gRPC client")
@@ -59,9 +114,14 @@ func Run[T any](config *config.Config, action func(client GrpcClient) (T, error)
 	client := proto.NewCredStoreClient(conn)
 	defer func() { _ = conn.Close() }()
 
+	ctx := context.Background()
+	if config.HasSession() {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+config.SessionToken.String())
+	}
+
 	grpcClient := grpcClientImpl{
 		client: client,
-		ctx:    context.Background(),
+		ctx:    ctx,
 	}
 
 	return action(&grpcClient)
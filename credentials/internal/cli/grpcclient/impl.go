@@ -19,8 +19,11 @@ import (
 	"context"
 	"errors"
 	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"io"
+	"strconv"
 )
 
 type grpcClientImpl struct {
@@ -37,6 +40,15 @@ func (g *grpcClientImpl) GetInfo() (*proto.StoreInfo, error) {
 	return info, nil
 }
 
+func (g *grpcClientImpl) InitializeVault(initialization *proto.VaultInitialization) (*proto.VaultInfo, error) {
+	info, err := g.client.InitializeVault(g.ctx, initialization)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return info, nil
+}
+
 func (g *grpcClientImpl) UnlockVault(credentials *proto.AdminCredentials) error {
 	if _, err := g.client.UnlockVault(g.ctx, credentials); err != nil {
 		return unpackError(err)
@@ -53,6 +65,79 @@ func (g *grpcClientImpl) LockVault() error {
 	return nil
 }
 
+func (g *grpcClientImpl) PromoteReplica(credentials *proto.AdminCredentials) (*proto.StoreInfo, error) {
+	info, err := g.client.PromoteReplica(g.ctx, credentials)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return info, nil
+}
+
+func (g *grpcClientImpl) GetStatus(credentials *proto.AdminCredentials) (*proto.StoreStatus, error) {
+	storeStatus, err := g.client.GetStatus(g.ctx, credentials)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return storeStatus, nil
+}
+
+func (g *grpcClientImpl) Login(request *proto.LoginRequest) (*proto.Session, error) {
+	session, err := g.client.Login(g.ctx, request)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return session, nil
+}
+
+func (g *grpcClientImpl) Logout() error {
+	if _, err := g.client.Logout(g.ctx, &proto.Unit{}); err != nil {
+		return unpackError(err)
+	}
+
+	return nil
+}
+
+func (g *grpcClientImpl) AddAdmin(creation *proto.AdminCreation) (*proto.AdminInfo, error) {
+	admin, err := g.client.AddAdmin(g.ctx, creation)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return admin, nil
+}
+
+func (g *grpcClientImpl) RemoveAdmin(removal *proto.AdminRemoval) error {
+	if _, err := g.client.RemoveAdmin(g.ctx, removal); err != nil {
+		return unpackError(err)
+	}
+
+	return nil
+}
+
+func (g *grpcClientImpl) ListAdmins(search *proto.AdminSearch) ([]*proto.AdminInfo, error) {
+	stream, err := g.client.ListAdmins(g.ctx, search)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	var admins []*proto.AdminInfo
+	for {
+		admin, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, unpackError(err)
+		}
+
+		admins = append(admins, admin)
+	}
+
+	return admins, nil
+}
+
 func (g *grpcClientImpl) SetRecoveryRecipient(recipient *proto.RecoveryRecipient) error {
 	if _, err := g.client.SetRecoveryRecipient(g.ctx, recipient); err != nil {
 		return unpackError(err)
@@ -70,13 +155,29 @@ func (g *grpcClientImpl) CreateVaultItem(creation *proto.ItemCreation) (*proto.I
 	return item, nil
 }
 
-func (g *grpcClientImpl) ListVaultItems(search *proto.ItemSearch) ([]*proto.Item, error) {
+func (g *grpcClientImpl) GenerateVaultItem(generation *proto.ItemGeneration) (*proto.GeneratedItem, error) {
+	item, err := g.client.GenerateVaultItem(g.ctx, generation)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return item, nil
+}
+
+// the response headers of ListVaultItems
+const (
+	totalCountKey    = "x-total-count"
+	matchedCountKey  = "x-matched-count"
+	nextPageTokenKey = "x-next-page-token"
+)
+
+func (g *grpcClientImpl) ListVaultItems(search *proto.ItemSearch) (*ItemPage, error) {
 	stream, err := g.client.ListVaultItems(g.ctx, search)
 	if err != nil {
 		return nil, unpackError(err)
 	}
 
-	var items []*proto.Item
+	page := &ItemPage{}
 	for {
 		item, err := stream.Recv()
 		if err == io.EOF {
@@ -85,10 +186,36 @@ func (g *grpcClientImpl) ListVaultItems(search *proto.ItemSearch) ([]*proto.Item
 			return nil, unpackError(err)
 		}
 
-		items = append(items, item)
+		page.Items = append(page.Items, item)
 	}
 
-	return items, nil
+	header, err := stream.Header()
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	page.Total = headerInt(header, totalCountKey, len(page.Items))
+	page.Matched = headerInt(header, matchedCountKey, len(page.Items))
+
+	if tokens := header.Get(nextPageTokenKey); len(tokens) > 0 {
+		page.NextPageToken = tokens[0]
+	}
+
+	return page, nil
+}
+
+func headerInt(header metadata.MD, key string, fallback int) int {
+	values := header.Get(key)
+	if len(values) == 0 {
+		return fallback
+	}
+
+	value, err := strconv.Atoi(values[0])
+	if err != nil {
+		return fallback
+	}
+
+	return value
 }
 
 func (g *grpcClientImpl) DeleteVaultItems(deletion *proto.ItemDeletion) ([]string, error) {
@@ -121,6 +248,97 @@ func (g *grpcClientImpl) ReadVaultItem(request *proto.ItemRequest) (*proto.ItemV
 	return value, nil
 }
 
+func (g *grpcClientImpl) ReadVaultItems(request *proto.ItemsRequest) ([]*proto.ItemResult, error) {
+	stream, err := g.client.ReadVaultItems(g.ctx, request)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	var results []*proto.ItemResult
+	for {
+		result, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, unpackError(err)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (g *grpcClientImpl) ShareVaultItem(share *proto.ItemShare) (*proto.SharedItem, error) {
+	shared, err := g.client.ShareVaultItem(g.ctx, share)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return shared, nil
+}
+
+// WatchVault hands each event to handle until the watch ends, it returns
+// nil if the store ended it
+func (g *grpcClientImpl) WatchVault(request *proto.WatchRequest, handle func(*proto.WatchEvent) error) error {
+	stream, err := g.client.WatchVault(g.ctx, request)
+	if err != nil {
+		return unpackWatchError(err)
+	}
+
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return unpackWatchError(err)
+		}
+
+		if err = handle(event); err != nil {
+			return err
+		}
+	}
+}
+
+func (g *grpcClientImpl) SetItemApproval(setting *proto.ItemApprovalSetting) (*proto.Item, error) {
+	item, err := g.client.SetItemApproval(g.ctx, setting)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return item, nil
+}
+
+func (g *grpcClientImpl) ListApprovals(search *proto.ApprovalSearch) ([]*proto.ApprovalRequest, error) {
+	stream, err := g.client.ListApprovals(g.ctx, search)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	var requests []*proto.ApprovalRequest
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, unpackError(err)
+		}
+
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+func (g *grpcClientImpl) DecideApproval(decision *proto.ApprovalDecision) (*proto.ApprovalRequest, error) {
+	request, err := g.client.DecideApproval(g.ctx, decision)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return request, nil
+}
+
 func (g *grpcClientImpl) CreateClientCredentials(creation *proto.ClientCreation) (*proto.ClientCredentials, error) {
 	creds, err := g.client.CreateClientCredentials(g.ctx, creation)
 	if err != nil {
@@ -130,6 +348,161 @@ func (g *grpcClientImpl) CreateClientCredentials(creation *proto.ClientCreation)
 	return creds, nil
 }
 
+func (g *grpcClientImpl) GetChallenge(request *proto.ChallengeRequest) (*proto.Challenge, error) {
+	challenge, err := g.client.GetChallenge(g.ctx, request)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return challenge, nil
+}
+
+func (g *grpcClientImpl) QueryAuditLog(query *proto.AuditQuery) ([]*proto.AuditEntry, error) {
+	stream, err := g.client.QueryAuditLog(g.ctx, query)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	var entries []*proto.AuditEntry
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, unpackError(err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (g *grpcClientImpl) VerifyAuditLog(request *proto.AuditVerificationRequest) (*proto.AuditVerification, error) {
+	verification, err := g.client.VerifyAuditLog(g.ctx, request)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return verification, nil
+}
+
+func (g *grpcClientImpl) InitAuthority(creation *proto.AuthorityCreation) (*proto.AuthorityInfo, error) {
+	info, err := g.client.InitAuthority(g.ctx, creation)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return info, nil
+}
+
+func (g *grpcClientImpl) GetAuthority() (*proto.AuthorityInfo, error) {
+	info, err := g.client.GetAuthority(g.ctx, &proto.Unit{})
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return info, nil
+}
+
+func (g *grpcClientImpl) CreateEnrollmentToken(creation *proto.EnrollmentTokenCreation) (*proto.EnrollmentToken, error) {
+	token, err := g.client.CreateEnrollmentToken(g.ctx, creation)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return token, nil
+}
+
+func (g *grpcClientImpl) EnrollClient(request *proto.EnrollmentRequest) (*proto.Enrollment, error) {
+	enrollment, err := g.client.EnrollClient(g.ctx, request)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return enrollment, nil
+}
+
+func (g *grpcClientImpl) GetEnrollment(query *proto.EnrollmentQuery) (*proto.Enrollment, error) {
+	enrollment, err := g.client.GetEnrollment(g.ctx, query)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return enrollment, nil
+}
+
+func (g *grpcClientImpl) ListEnrollments(search *proto.EnrollmentSearch) ([]*proto.Enrollment, error) {
+	stream, err := g.client.ListEnrollments(g.ctx, search)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	var enrollments []*proto.Enrollment
+	for {
+		enrollment, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, unpackError(err)
+		}
+
+		enrollments = append(enrollments, enrollment)
+	}
+
+	return enrollments, nil
+}
+
+func (g *grpcClientImpl) DecideEnrollment(decision *proto.EnrollmentDecision) (*proto.Enrollment, error) {
+	enrollment, err := g.client.DecideEnrollment(g.ctx, decision)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return enrollment, nil
+}
+
+func (g *grpcClientImpl) RevokeCertificate(revocation *proto.CertificateRevocation) (*proto.Enrollment, error) {
+	enrollment, err := g.client.RevokeCertificate(g.ctx, revocation)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return enrollment, nil
+}
+
+func (g *grpcClientImpl) RenewCertificate(request *proto.RenewalRequest) (*proto.Enrollment, error) {
+	enrollment, err := g.client.RenewCertificate(g.ctx, request)
+	if err != nil {
+		return nil, unpackError(err)
+	}
+
+	return enrollment, nil
+}
+
+// RetryableError is returned if a call failed because the store went away
+// or was overloaded, rather than rejecting the call
+type RetryableError struct {
+	err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.err
+}
+
+func unpackWatchError(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Canceled, codes.DeadlineExceeded:
+		return &RetryableError{unpackError(err)}
+	default:
+		return unpackError(err)
+	}
+}
+
 func unpackError(err error) error {
 	s, ok := status.FromError(err)
 	if !ok {
@@ -0,0 +1,98 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package grpcclient
+
+import (
+	"crypto/ed25519"
+	"github.com/awnumar/memguard"
+	"github.com/google/uuid"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+)
+
+// Reader holds the credentials to read vault items with. Client credentials
+// take precedence over admin credentials, none are set when reading as peer
+// of the local unix socket.
+type Reader struct {
+	Admin       *proto.AdminCredentials
+	Client      *proto.ClientCredentials
+	keyClientId string
+	clientKey   *memguard.LockedBuffer
+}
+
+func NewReader(config *config.Config) (*Reader, error) {
+	if config.ClientKey != nil && config.KeyClientId != "" {
+		return &Reader{keyClientId: config.KeyClientId, clientKey: config.ClientKey}, nil
+	}
+
+	if config.SecureCredentials != nil {
+		clientId, err := uuid.Parse(config.SecureCredentials.Id.String())
+		if err != nil {
+			return nil, err
+		}
+
+		return &Reader{Client: &proto.ClientCredentials{
+			Id:     clientId.String(),
+			Secret: config.SecureCredentials.Secret.String(),
+		}}, nil
+	}
+
+	if config.Credentials != nil {
+		clientId, err := uuid.Parse(config.Credentials.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Reader{Client: &proto.ClientCredentials{
+			Id:     clientId.String(),
+			Secret: config.Credentials.Secret,
+		}}, nil
+	}
+
+	if config.IsUnixSocket() && !config.HasSession() {
+		return &Reader{}, nil
+	}
+
+	return &Reader{Admin: config.AdminCredentials()}, nil
+}
+
+// IsLocalPeer is true if the unix socket peer identity is used
+func (r *Reader) IsLocalPeer() bool {
+	return r.Admin == nil && r.Client == nil && r.keyClientId == ""
+}
+
+// Key signs a fresh challenge if reading with the client key, nil otherwise.
+// Signing the nonce issued by the store proves possession of the key without
+// ever sending a reusable secret.
+func (r *Reader) Key(c GrpcClient) (*proto.ClientKeyCredentials, error) {
+	if r.keyClientId == "" {
+		return nil, nil
+	}
+
+	challenge, err := c.GetChallenge(&proto.ChallengeRequest{ClientId: r.keyClientId})
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey := ed25519.NewKeyFromSeed(r.clientKey.Bytes())
+	defer memguard.WipeBytes(privateKey)
+
+	return &proto.ClientKeyCredentials{
+		Id:        r.keyClientId,
+		Nonce:     challenge.GetNonce(),
+		Signature: ed25519.Sign(privateKey, challenge.GetNonce()),
+	}, nil
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package grpcclient
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIdKey is the trailer the store returns the ID of each call in
+const requestIdKey = "x-request-id"
+
+// unaryRequestIdInterceptor adds the request ID returned by the store to
+// errors, so the failure can be found in the store's logs
+func unaryRequestIdInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var trailer metadata.MD
+
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...)
+	if err == nil {
+		return nil
+	}
+
+	ids := trailer.Get(requestIdKey)
+	if len(ids) == 0 {
+		return err
+	}
+
+	s := status.Convert(err)
+
+	return status.Error(s.Code(), fmt.Sprintf("%s (request ID %s)", s.Message(), ids[0]))
+}
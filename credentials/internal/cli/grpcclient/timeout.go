@@ -0,0 +1,96 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package grpcclient
+
+import (
+	"context"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"time"
+)
+
+func keepaliveParams(keepaliveConfig *config.Keepalive) keepalive.ClientParameters {
+	params := keepalive.ClientParameters{
+		Time:    config.DefaultKeepaliveTime,
+		Timeout: config.DefaultKeepaliveTimeout,
+	}
+
+	if keepaliveConfig == nil {
+		return params
+	}
+
+	if keepaliveConfig.Time != nil {
+		params.Time = keepaliveConfig.Time.Duration
+	}
+
+	if keepaliveConfig.Timeout != nil {
+		params.Timeout = keepaliveConfig.Timeout.Duration
+	}
+
+	return params
+}
+
+// unaryTimeoutInterceptor applies the deadline to calls which don't have
+// one yet, so an unresponsive store can't block forever
+func unaryTimeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// streamTimeoutInterceptor applies the deadline to the whole stream, it's
+// released once the stream ends. Watches are meant to stay open, they're
+// kept alive by keepalive pings instead.
+func streamTimeoutInterceptor(timeout time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if _, ok := ctx.Deadline(); ok || method == proto.CredStore_WatchVault_FullMethodName {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		return &cancelingStream{ClientStream: stream, cancel: cancel}, nil
+	}
+}
+
+type cancelingStream struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+func (s *cancelingStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.cancel()
+	}
+
+	return err
+}
@@ -0,0 +1,208 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package item
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/awnumar/memguard"
+	"github.com/google/uuid"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	formatJson = "json"
+	formatEnv  = "env"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type batchItem struct {
+	id string
+	// the variable name of the env format
+	name string
+}
+
+type jsonItemResult struct {
+	Id    string `json:"id"`
+	Value string `json:"value,omitempty"`
+	// set instead of the value if it isn't valid UTF-8
+	ValueBase64 string `json:"value_base64,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// parseBatchItems parses item IDs optionally prefixed with a variable name,
+// e.g. BORG_PASSPHRASE=ID, without a name ITEM_<ID> is used
+func parseBatchItems(args []string) ([]batchItem, error) {
+	items := make([]batchItem, 0, len(args))
+	for _, arg := range args {
+		name, rawId, ok := strings.Cut(arg, "=")
+		if !ok {
+			rawId = arg
+		}
+
+		id, err := uuid.Parse(rawId)
+		if err != nil {
+			return nil, fmt.Errorf("invalid item ID %s: %v", rawId, err)
+		}
+
+		if !ok {
+			name = defaultEnvName(id.String())
+		} else if !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name: %s", name)
+		}
+
+		items = append(items, batchItem{id: id.String(), name: name})
+	}
+
+	return items, nil
+}
+
+func defaultEnvName(itemId string) string {
+	return "ITEM_" + strings.ReplaceAll(strings.ToUpper(itemId), "-", "_")
+}
+
+// readMultiple reads all items with a single request, the values of the items
+// which could be read are printed even if others failed
+func (cmd *readVaultItemCmd) readMultiple(state *config.State) {
+	if cmd.format == "" {
+		log.Fatal().Msg("Reading several items requires --format json or env")
+	} else if cmd.format != formatJson && cmd.format != formatEnv {
+		log.Fatal().Msgf("Invalid format: %s", cmd.format)
+	}
+
+	rawItems := flaggy.TrailingArguments
+	if cmd.itemId != "" {
+		rawItems = append([]string{cmd.itemId}, rawItems...)
+	}
+
+	items, err := parseBatchItems(rawItems)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to parse items")
+	}
+
+	reader := newItemReader(state)
+
+	request := &proto.ItemsRequest{Selector: cmd.selector}
+	for _, item := range items {
+		request.ItemIds = append(request.ItemIds, item.id)
+	}
+
+	if reader.Client != nil {
+		request.Credentials = &proto.ItemsRequest_Client{Client: reader.Client}
+	} else if reader.Admin != nil {
+		request.Credentials = &proto.ItemsRequest_Admin{Admin: reader.Admin}
+	}
+
+	results, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) ([]*proto.ItemResult, error) {
+			key, err := reader.Key(c)
+			if err != nil {
+				return nil, err
+			} else if key != nil {
+				request.Credentials = &proto.ItemsRequest_Key{Key: key}
+			}
+
+			return c.ReadVaultItems(request)
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to read items")
+	}
+
+	// the items matching the selector follow the listed ones
+	if len(results) < len(items) || (cmd.selector == "" && len(results) > len(items)) {
+		log.Fatal().Msgf("Expected %d items but received %d", len(items), len(results))
+	}
+
+	defer func() {
+		for _, result := range results {
+			memguard.WipeBytes(result.GetValue().GetValue())
+		}
+	}()
+
+	failed := 0
+	jsonResults := make([]jsonItemResult, 0, len(results))
+
+	for i, result := range results {
+		resultErr := result.GetError()
+		if approval := result.GetValue().GetApproval(); approval != nil {
+			resultErr = fmt.Sprintf(
+				"approval pending until %s, request ID: %s",
+				time.UnixMilli(approval.GetExpiresAt()).Format(time.RFC3339),
+				approval.GetId(),
+			)
+		}
+
+		if resultErr != "" {
+			failed++
+			log.Error().Msgf("Failed to read item %s: %s", result.GetItemId(), resultErr)
+		}
+
+		switch cmd.format {
+		case formatJson:
+			jsonResult := jsonItemResult{Id: result.GetItemId(), Error: resultErr}
+			if resultErr == "" {
+				value := result.GetValue().GetValue()
+				if utf8.Valid(value) {
+					jsonResult.Value = string(value)
+				} else {
+					jsonResult.ValueBase64 = base64.StdEncoding.EncodeToString(value)
+				}
+			}
+
+			jsonResults = append(jsonResults, jsonResult)
+		case formatEnv:
+			if resultErr == "" {
+				name := defaultEnvName(result.GetItemId())
+				if i < len(items) {
+					name = items[i].name
+				}
+
+				fmt.Printf("export %s=%s\n", name, shellQuote(string(result.GetValue().GetValue())))
+			}
+		}
+	}
+
+	if cmd.format == formatJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(jsonResults); err != nil {
+			log.Fatal().Err(err).Msg("Failed to write items")
+		}
+	}
+
+	if failed > 0 {
+		log.Fatal().Msgf("Failed to read %d of %d items", failed, len(results))
+	}
+}
+
+// shellQuote quotes the value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -25,7 +25,9 @@ type Cmd struct {
 	*listVaultItemsCmd
 	*readVaultItemCmd
 	*createVaultItemCmd
+	*generateVaultItemCmd
 	*deleteVaultItemsCmd
+	*shareVaultItemCmd
 }
 
 func NewCmd() *Cmd {
@@ -40,7 +42,9 @@ func NewCmd() *Cmd {
 	itemCmd.listVaultItemsCmd = newListVaultItemsCmd(cmd)
 	itemCmd.readVaultItemCmd = newReadVaultItemCmd(cmd)
 	itemCmd.createVaultItemCmd = newCreateVaultItemCmd(cmd)
+	itemCmd.generateVaultItemCmd = newGenerateVaultItemCmd(cmd)
 	itemCmd.deleteVaultItemsCmd = newDeleteVaultItemsCmd(cmd)
+	itemCmd.shareVaultItemCmd = newShareVaultItemCmd(cmd)
 
 	return itemCmd
 }
@@ -54,8 +58,12 @@ func (cmd *Cmd) Run(state *config.State) {
 		cmd.readVaultItemCmd.run(state)
 	} else if cmd.createVaultItemCmd.Used {
 		cmd.createVaultItemCmd.run(state)
+	} else if cmd.generateVaultItemCmd.Used {
+		cmd.generateVaultItemCmd.run(state)
 	} else if cmd.deleteVaultItemsCmd.Used {
 		cmd.deleteVaultItemsCmd.run(state)
+	} else if cmd.shareVaultItemCmd.Used {
+		cmd.shareVaultItemCmd.run(state)
 	} else {
 		flaggy.ShowHelpAndExit("")
 	}
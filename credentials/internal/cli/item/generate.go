@@ -0,0 +1,185 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package item
+
+import (
+	"errors"
+	"fmt"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"os"
+	"strings"
+	"time"
+)
+
+var characterClasses = map[string]proto.CharacterClass{
+	"lower":   proto.CharacterClass_CHARACTER_CLASS_LOWERCASE,
+	"upper":   proto.CharacterClass_CHARACTER_CLASS_UPPERCASE,
+	"digits":  proto.CharacterClass_CHARACTER_CLASS_DIGITS,
+	"symbols": proto.CharacterClass_CHARACTER_CLASS_SYMBOLS,
+}
+
+type generateVaultItemCmd struct {
+	*flaggy.Subcommand
+	description      string
+	valueType        string
+	length           int
+	classes          string
+	words            int
+	separator        string
+	requiresApproval bool
+	maxReads         int
+	ttl              time.Duration
+	labels           []string
+}
+
+func newGenerateVaultItemCmd(parent *flaggy.Subcommand) *generateVaultItemCmd {
+	generateCmd := &generateVaultItemCmd{
+		valueType: "password",
+	}
+
+	cmd := flaggy.NewSubcommand("generate")
+	cmd.ShortName = "g"
+	cmd.Description = "Creates a new vault item with a value generated by the store"
+
+	cmd.String(&generateCmd.description, "d", "description", "Description of the vault item")
+	cmd.String(&generateCmd.valueType, "t", "type", "Type of the value: password, passphrase, bytes, x25519 or ed25519")
+	cmd.Int(&generateCmd.length, "l", "length", "Length of the password or number of random bytes")
+	cmd.String(&generateCmd.classes, "c", "classes", "Comma separated character classes of the password: lower, upper, digits, symbols")
+	cmd.Int(&generateCmd.words, "w", "words", "Number of words of the passphrase")
+	cmd.String(&generateCmd.separator, "", "separator", "Separator of the passphrase words")
+	cmd.Bool(&generateCmd.requiresApproval, "", "requires-approval", "Reading the value requires the approval of a second admin")
+	cmd.Int(&generateCmd.maxReads, "", "max-reads", "Delete the item after it was read this many times")
+	cmd.Duration(&generateCmd.ttl, "", "ttl", "Delete the item once this period has passed")
+	cmd.StringSlice(&generateCmd.labels, "", "label", "Labels of the vault item as key=value, selected by item read --selector")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	generateCmd.Subcommand = cmd
+
+	return generateCmd
+}
+
+func (cmd *generateVaultItemCmd) run(state *config.State) {
+	var err error
+
+	if cmd.maxReads < 0 || cmd.ttl < 0 || cmd.length < 0 || cmd.words < 0 {
+		log.Fatal().Msg("Max reads, TTL, length and words must not be negative")
+	}
+
+	labels, err := parseLabels(cmd.labels)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid labels")
+	}
+
+	generation := &proto.ItemGeneration{
+		RequiresApproval: cmd.requiresApproval,
+		MaxReads:         uint32(cmd.maxReads),
+		TtlSeconds:       int64(cmd.ttl.Seconds()),
+		Labels:           labels,
+	}
+
+	if err = cmd.setPolicy(generation); err != nil {
+		log.Fatal().Err(err).Msg("Invalid generation policy")
+	}
+
+	cmd.description = strings.TrimSpace(cmd.description)
+	if cmd.description == "" {
+		cmd.description, err = utils.Prompt("Enter a description", "")
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to prompt for description")
+		}
+	}
+
+	generation.Credentials = state.Config().AdminCredentials()
+	generation.Description = cmd.description
+
+	generated, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.GeneratedItem, error) {
+			return c.GenerateVaultItem(generation)
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to generate vault item")
+	}
+
+	log.Info().Msgf("Generated vault item with ID: %s", generated.GetItem().GetId())
+
+	if generated.GetPublicKey() != "" {
+		log.Info().Msg("Public key:")
+		_, _ = os.Stdout.WriteString(generated.GetPublicKey() + "\n")
+	}
+}
+
+func (cmd *generateVaultItemCmd) setPolicy(generation *proto.ItemGeneration) error {
+	if cmd.valueType != "password" && cmd.classes != "" {
+		return errors.New("character classes only apply to passwords")
+	}
+
+	if cmd.valueType != "passphrase" && (cmd.words > 0 || cmd.separator != "") {
+		return errors.New("words and separator only apply to passphrases")
+	}
+
+	if cmd.valueType != "password" && cmd.valueType != "bytes" && cmd.length > 0 {
+		return errors.New("length only applies to passwords and random bytes")
+	}
+
+	switch cmd.valueType {
+	case "password":
+		policy := &proto.PasswordPolicy{Length: uint32(cmd.length)}
+		for _, name := range strings.Split(cmd.classes, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+
+			class, ok := characterClasses[name]
+			if !ok {
+				return fmt.Errorf("unknown character class: %s", name)
+			}
+
+			policy.CharacterClasses = append(policy.CharacterClasses, class)
+		}
+
+		generation.Policy = &proto.ItemGeneration_Password{Password: policy}
+	case "passphrase":
+		generation.Policy = &proto.ItemGeneration_Passphrase{
+			Passphrase: &proto.PassphrasePolicy{Words: uint32(cmd.words), Separator: cmd.separator},
+		}
+	case "bytes":
+		generation.Policy = &proto.ItemGeneration_RandomBytes{
+			RandomBytes: &proto.RandomBytesPolicy{Length: uint32(cmd.length)},
+		}
+	case "x25519":
+		generation.Policy = &proto.ItemGeneration_KeyPair{
+			KeyPair: &proto.KeyPairPolicy{Type: proto.KeyType_KEY_TYPE_X25519},
+		}
+	case "ed25519":
+		generation.Policy = &proto.ItemGeneration_KeyPair{
+			KeyPair: &proto.KeyPairPolicy{Type: proto.KeyType_KEY_TYPE_ED25519},
+		}
+	default:
+		return fmt.Errorf("unknown type: %s", cmd.valueType)
+	}
+
+	return nil
+}
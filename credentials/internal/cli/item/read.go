@@ -23,24 +23,45 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
 	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
-	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
 	"github.com/vemilyus/borg-collective/credentials/internal/proto"
 	"golang.org/x/term"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type listVaultItemsCmd struct {
 	*flaggy.Subcommand
-	search string
-	idOnly bool
+	search      string
+	idOnly      bool
+	sort        string
+	reverse     bool
+	limit       int
+	offset      int
+	clientItems string
+	selector    string
+}
+
+var itemSorts = map[string]proto.ItemSort{
+	"description": proto.ItemSort_ITEM_SORT_DESCRIPTION,
+	"modified":    proto.ItemSort_ITEM_SORT_MODIFIED,
+}
+
+var clientItemFilters = map[string]proto.ClientItemFilter{
+	"include": proto.ClientItemFilter_CLIENT_ITEMS_INCLUDE,
+	"exclude": proto.ClientItemFilter_CLIENT_ITEMS_EXCLUDE,
+	"only":    proto.ClientItemFilter_CLIENT_ITEMS_ONLY,
 }
 
 func newListVaultItemsCmd(parent *flaggy.Subcommand) *listVaultItemsCmd {
 	listCmd := &listVaultItemsCmd{
-		search: "",
-		idOnly: false,
+		search:      "",
+		idOnly:      false,
+		sort:        "description",
+		clientItems: "include",
 	}
 
 	cmd := flaggy.NewSubcommand("list")
@@ -49,6 +70,12 @@ func newListVaultItemsCmd(parent *flaggy.Subcommand) *listVaultItemsCmd {
 
 	cmd.AddPositionalValue(&listCmd.search, "SEARCH", 1, false, "Filter by description content")
 	cmd.Bool(&listCmd.idOnly, "q", "quiet", "Only display item IDs")
+	cmd.String(&listCmd.sort, "s", "sort", "Sort by description or modified")
+	cmd.Bool(&listCmd.reverse, "r", "reverse", "Sort in descending order")
+	cmd.Int(&listCmd.limit, "n", "limit", "The maximum number of items to list, 0 lists all")
+	cmd.Int(&listCmd.offset, "o", "offset", "The number of items to skip")
+	cmd.String(&listCmd.clientItems, "c", "clients", "Whether to include, exclude or only list client credential items")
+	cmd.String(&listCmd.selector, "", "selector", "Only list items whose labels match, e.g. env=prod,tier!=db,backup,!deprecated")
 
 	parent.AttachSubcommand(cmd, 1)
 
@@ -64,23 +91,46 @@ func (cmd *listVaultItemsCmd) run(state *config.State) {
 		actualSearch = nil
 	}
 
-	passphrase := state.Config().Passphrase
-	if passphrase == nil {
-		passphrase = utils.AskForPassphrase()
-		defer passphrase.Destroy()
+	sort, ok := itemSorts[cmd.sort]
+	if !ok {
+		log.Fatal().Msgf("Invalid sort: %s", cmd.sort)
+	}
+
+	clientItems, ok := clientItemFilters[cmd.clientItems]
+	if !ok {
+		log.Fatal().Msgf("Invalid client items filter: %s", cmd.clientItems)
+	}
+
+	if cmd.limit < 0 {
+		log.Fatal().Msg("Limit must not be negative")
+	}
+
+	if cmd.offset < 0 {
+		log.Fatal().Msg("Offset must not be negative")
 	}
 
-	items, err := grpcclient.Run(
+	adminCredentials := state.Config().AdminCredentials()
+
+	page, err := grpcclient.Run(
 		state.Config(),
-		func(c grpcclient.GrpcClient) ([]*proto.Item, error) {
+		func(c grpcclient.GrpcClient) (*grpcclient.ItemPage, error) {
 			search := &proto.ItemSearch{
-				Credentials: &proto.AdminCredentials{Passphrase: passphrase.String()},
+				Credentials: adminCredentials,
+				Sort:        sort,
+				Descending:  cmd.reverse,
+				ClientItems: clientItems,
+				PageSize:    uint32(cmd.limit),
+				Selector:    cmd.selector,
 			}
 
 			if actualSearch != nil {
 				search.Query = *actualSearch
 			}
 
+			if cmd.offset > 0 {
+				search.PageToken = strconv.Itoa(cmd.offset)
+			}
+
 			return c.ListVaultItems(search)
 		},
 	)
@@ -89,25 +139,64 @@ func (cmd *listVaultItemsCmd) run(state *config.State) {
 		log.Fatal().Err(err).Msg("Failed to retrieve list of items")
 	}
 
-	log.Info().Msgf("Retrieved %d items", len(items))
+	items := page.Items
+
+	log.Info().Msgf("Retrieved %d of %d matching items (%d in total)", len(items), page.Matched, page.Total)
 	if actualSearch != nil {
 		log.Info().Msgf("Used search: %s", *actualSearch)
 	}
 
+	if cmd.selector != "" {
+		log.Info().Msgf("Used selector: %s", cmd.selector)
+	}
+
+	if page.NextPageToken != "" {
+		log.Info().Msgf("More items available, continue with --offset %s", page.NextPageToken)
+	}
+
 	if cmd.idOnly {
 		for _, item := range items {
 			fmt.Println(item.GetId())
 		}
 	} else {
 		for _, item := range items {
-			fmt.Printf("%s\t%s\t%s\n", item.GetId(), item.GetDescription(), time.UnixMilli(item.GetCreatedAt()).Format(time.RFC3339))
+			description := item.GetDescription()
+			if item.GetRequiresApproval() {
+				description += " (requires approval)"
+			}
+
+			if item.GetMaxReads() > 0 {
+				description += fmt.Sprintf(" (%d of %d reads left)", item.GetRemainingReads(), item.GetMaxReads())
+			}
+
+			if item.GetExpiresAt() > 0 {
+				description += " (expires " + time.UnixMilli(item.GetExpiresAt()).Format(time.RFC3339) + ")"
+			}
+
+			if len(item.GetLabels()) > 0 {
+				description += " [" + formatLabels(item.GetLabels()) + "]"
+			}
+
+			fmt.Printf("%s\t%s\t%s\n", item.GetId(), description, time.UnixMilli(item.GetCreatedAt()).Format(time.RFC3339))
 		}
 	}
 }
 
+// formatLabels formats the labels sorted by key
+func formatLabels(labels map[string]string) string {
+	formatted := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		formatted = append(formatted, key+"="+labels[key])
+	}
+
+	return strings.Join(formatted, ",")
+}
+
 type readVaultItemCmd struct {
 	*flaggy.Subcommand
-	itemId string
+	itemId   string
+	format   string
+	selector string
 }
 
 func newReadVaultItemCmd(parent *flaggy.Subcommand) *readVaultItemCmd {
@@ -117,7 +206,9 @@ func newReadVaultItemCmd(parent *flaggy.Subcommand) *readVaultItemCmd {
 	cmd.ShortName = "r"
 	cmd.Description = "Reads an item value"
 
-	cmd.AddPositionalValue(&readCmd.itemId, "ITEM-ID", 1, true, "The ID of the item to read")
+	cmd.AddPositionalValue(&readCmd.itemId, "ITEM-IDS", 1, false, "The ID of the item to read, more IDs follow after --. NAME=ID sets the variable name of the env format")
+	cmd.String(&readCmd.format, "f", "format", "Output format, required for several items (json, env)")
+	cmd.String(&readCmd.selector, "s", "selector", "Also read the items whose labels match, e.g. env=prod,tier!=db,backup,!deprecated")
 
 	parent.AttachSubcommand(cmd, 1)
 
@@ -127,54 +218,42 @@ func newReadVaultItemCmd(parent *flaggy.Subcommand) *readVaultItemCmd {
 }
 
 func (cmd *readVaultItemCmd) run(state *config.State) {
+	if cmd.itemId == "" && cmd.selector == "" {
+		log.Fatal().Msg("Either item IDs or a selector is required")
+	}
+
+	if cmd.format != "" || cmd.selector != "" || len(flaggy.TrailingArguments) > 0 {
+		cmd.readMultiple(state)
+		return
+	}
+
 	itemId, err := uuid.Parse(cmd.itemId)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to parse item ID")
 	}
 
+	reader := newItemReader(state)
+
 	itemRequest := &proto.ItemRequest{
 		ItemId: itemId.String(),
 	}
 
-	if state.Config().SecureCredentials != nil {
-		clientId, err := uuid.Parse(state.Config().SecureCredentials.Id.String())
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to parse client ID")
-		}
-
-		defer memguard.WipeBytes(clientId[:])
-
-		itemRequest.Credentials = &proto.ItemRequest_Client{Client: &proto.ClientCredentials{
-			Id:     clientId.String(),
-			Secret: state.Config().SecureCredentials.Secret.String(),
-		}}
-	} else if state.Config().Credentials != nil {
-		clientId, err := uuid.Parse(state.Config().Credentials.Id)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to parse client ID")
-		}
-
-		defer memguard.WipeBytes(clientId[:])
-
-		itemRequest.Credentials = &proto.ItemRequest_Client{Client: &proto.ClientCredentials{
-			Id:     clientId.String(),
-			Secret: state.Config().Credentials.Secret,
-		}}
-	} else {
-		log.Info().Msg("Reading vault item using passphrase")
-
-		passphrase := state.Config().Passphrase
-		if passphrase == nil {
-			passphrase = utils.AskForPassphrase()
-			defer passphrase.Destroy()
-		}
-
-		itemRequest.Credentials = &proto.ItemRequest_Admin{Admin: &proto.AdminCredentials{Passphrase: passphrase.String()}}
+	if reader.Client != nil {
+		itemRequest.Credentials = &proto.ItemRequest_Client{Client: reader.Client}
+	} else if reader.Admin != nil {
+		itemRequest.Credentials = &proto.ItemRequest_Admin{Admin: reader.Admin}
 	}
 
 	itemValue, err := grpcclient.Run(
 		state.Config(),
 		func(c grpcclient.GrpcClient) (*proto.ItemValue, error) {
+			key, err := reader.Key(c)
+			if err != nil {
+				return nil, err
+			} else if key != nil {
+				itemRequest.Credentials = &proto.ItemRequest_Key{Key: key}
+			}
+
 			return c.ReadVaultItem(itemRequest)
 		},
 	)
@@ -183,6 +262,11 @@ func (cmd *readVaultItemCmd) run(state *config.State) {
 		log.Fatal().Err(err).Msg("Failed to read item")
 	}
 
+	if approval := itemValue.GetApproval(); approval != nil {
+		log.Info().Msgf("Reading this item requires approval by a second admin, request ID: %s", approval.GetId())
+		log.Fatal().Msgf("Approval pending until %s, read the item again once approved", time.UnixMilli(approval.GetExpiresAt()).Format(time.RFC3339))
+	}
+
 	secret := memguard.NewBufferFromBytes(itemValue.GetValue())
 	defer secret.Destroy()
 
@@ -192,3 +276,18 @@ func (cmd *readVaultItemCmd) run(state *config.State) {
 		println()
 	}
 }
+
+func newItemReader(state *config.State) *grpcclient.Reader {
+	reader, err := grpcclient.NewReader(state.Config())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to parse client ID")
+	}
+
+	if reader.IsLocalPeer() {
+		log.Info().Msg("Reading vault item as local peer")
+	} else if reader.Admin != nil {
+		log.Info().Msg("Reading vault item as admin")
+	}
+
+	return reader
+}
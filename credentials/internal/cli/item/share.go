@@ -0,0 +1,154 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package item
+
+import (
+	"filippo.io/age/armor"
+	"github.com/google/uuid"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type shareVaultItemCmd struct {
+	*flaggy.Subcommand
+	itemId        string
+	recipient     string
+	recipientFile string
+	outputFile    string
+	armor         bool
+}
+
+func newShareVaultItemCmd(parent *flaggy.Subcommand) *shareVaultItemCmd {
+	shareCmd := &shareVaultItemCmd{}
+
+	cmd := flaggy.NewSubcommand("share")
+	cmd.Description = "Encrypts an item value to an age recipient or SSH public key, the value is encrypted by the store"
+
+	cmd.AddPositionalValue(&shareCmd.itemId, "ITEM-ID", 1, true, "The ID of the item to share")
+	cmd.String(&shareCmd.recipient, "r", "recipient", "The age recipient (age1...) or SSH public key to encrypt to")
+	cmd.String(&shareCmd.recipientFile, "R", "recipient-file", "File containing the age recipient or SSH public key")
+	cmd.String(&shareCmd.outputFile, "o", "output", "Target file to store the encrypted value")
+	cmd.Bool(&shareCmd.armor, "a", "armor", "Encode the encrypted value as PEM")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	shareCmd.Subcommand = cmd
+
+	return shareCmd
+}
+
+func (cmd *shareVaultItemCmd) run(state *config.State) {
+	itemId, err := uuid.Parse(cmd.itemId)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to parse item ID")
+	}
+
+	if (cmd.recipient == "") == (cmd.recipientFile == "") {
+		log.Fatal().Msg("Specify either a recipient or a recipient file")
+	}
+
+	if cmd.recipientFile != "" {
+		recipient, err := os.ReadFile(cmd.recipientFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to read recipient file")
+		}
+
+		cmd.recipient = string(recipient)
+	}
+
+	if cmd.outputFile == "" && !cmd.armor && term.IsTerminal(int(os.Stdout.Fd())) {
+		log.Fatal().Msg("Refusing to write binary output to a terminal, use --armor or --output")
+	}
+
+	if cmd.outputFile != "" {
+		if stat, _ := os.Stat(cmd.outputFile); stat != nil {
+			doOverwrite, err := utils.PromptConfirm("Output file already exists, overwrite?", false)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to confirm overwriting")
+			}
+
+			if !doOverwrite {
+				log.Info().Msg("Not sharing the item, user aborted")
+				return
+			}
+		}
+	}
+
+	adminCredentials := state.Config().AdminCredentials()
+
+	shared, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.SharedItem, error) {
+			return c.ShareVaultItem(&proto.ItemShare{
+				Credentials: adminCredentials,
+				ItemId:      itemId.String(),
+				Recipient:   strings.TrimSpace(cmd.recipient),
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to share item")
+	}
+
+	if approval := shared.GetApproval(); approval != nil {
+		log.Info().Msgf("Reading this item requires approval by a second admin, request ID: %s", approval.GetId())
+		log.Fatal().Msgf("Approval pending until %s, share the item again once approved", time.UnixMilli(approval.GetExpiresAt()).Format(time.RFC3339))
+	}
+
+	out := io.Writer(os.Stdout)
+	if cmd.outputFile != "" {
+		file, err := os.OpenFile(cmd.outputFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create output file")
+		}
+
+		defer func() { _ = file.Close() }()
+
+		out = file
+	}
+
+	if err = writeCiphertext(out, shared.GetCiphertext(), cmd.armor); err != nil {
+		log.Fatal().Err(err).Msg("Failed to write encrypted value")
+	}
+
+	if cmd.outputFile != "" {
+		log.Info().Msgf("Encrypted item written to: %s", cmd.outputFile)
+	}
+}
+
+func writeCiphertext(out io.Writer, ciphertext []byte, armored bool) error {
+	if !armored {
+		_, err := out.Write(ciphertext)
+		return err
+	}
+
+	w := armor.NewWriter(out)
+	if _, err := w.Write(ciphertext); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
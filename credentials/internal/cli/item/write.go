@@ -17,6 +17,7 @@ package item
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/integrii/flaggy"
 	"github.com/rs/zerolog/log"
@@ -26,11 +27,16 @@ import (
 	"github.com/vemilyus/borg-collective/credentials/internal/proto"
 	"slices"
 	"strings"
+	"time"
 )
 
 type createVaultItemCmd struct {
 	*flaggy.Subcommand
-	description string
+	description      string
+	requiresApproval bool
+	maxReads         int
+	ttl              time.Duration
+	labels           []string
 }
 
 func newCreateVaultItemCmd(parent *flaggy.Subcommand) *createVaultItemCmd {
@@ -43,6 +49,10 @@ func newCreateVaultItemCmd(parent *flaggy.Subcommand) *createVaultItemCmd {
 	cmd.Description = "Creates a new vault item to securely store a secret value"
 
 	cmd.String(&createCmd.description, "d", "description", "Description of the vault item")
+	cmd.Bool(&createCmd.requiresApproval, "", "requires-approval", "Reading the value requires the approval of a second admin")
+	cmd.Int(&createCmd.maxReads, "", "max-reads", "Delete the item after it was read this many times")
+	cmd.Duration(&createCmd.ttl, "", "ttl", "Delete the item once this period has passed")
+	cmd.StringSlice(&createCmd.labels, "", "label", "Labels of the vault item as key=value, selected by item read --selector")
 
 	parent.AttachSubcommand(cmd, 1)
 
@@ -54,6 +64,15 @@ func newCreateVaultItemCmd(parent *flaggy.Subcommand) *createVaultItemCmd {
 func (cmd *createVaultItemCmd) run(state *config.State) {
 	var err error
 
+	if cmd.maxReads < 0 || cmd.ttl < 0 {
+		log.Fatal().Msg("Max reads and TTL must not be negative")
+	}
+
+	labels, err := parseLabels(cmd.labels)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid labels")
+	}
+
 	cmd.description = strings.TrimSpace(cmd.description)
 	if cmd.description == "" {
 		cmd.description, err = utils.Prompt("Enter a description", "")
@@ -80,19 +99,19 @@ func (cmd *createVaultItemCmd) run(state *config.State) {
 		log.Fatal().Msg("Secret value mismatch")
 	}
 
-	passphrase := state.Config().Passphrase
-	if passphrase == nil {
-		passphrase = utils.AskForPassphrase()
-		defer passphrase.Destroy()
-	}
+	adminCredentials := state.Config().AdminCredentials()
 
 	item, err := grpcclient.Run(
 		state.Config(),
 		func(c grpcclient.GrpcClient) (*proto.Item, error) {
 			return c.CreateVaultItem(&proto.ItemCreation{
-				Credentials: &proto.AdminCredentials{Passphrase: passphrase.String()},
-				Description: cmd.description,
-				Value:       secret.Bytes(),
+				Credentials:      adminCredentials,
+				Description:      cmd.description,
+				Value:            secret.Bytes(),
+				RequiresApproval: cmd.requiresApproval,
+				MaxReads:         uint32(cmd.maxReads),
+				TtlSeconds:       int64(cmd.ttl.Seconds()),
+				Labels:           labels,
 			})
 		},
 	)
@@ -104,6 +123,24 @@ func (cmd *createVaultItemCmd) run(state *config.State) {
 	log.Info().Msgf("Created vault item with ID: %s", item.Id)
 }
 
+// parseLabels parses key=value labels, a label without a value is set to an
+// empty value
+func parseLabels(raw []string) (map[string]string, error) {
+	labels := make(map[string]string, len(raw))
+	for _, label := range raw {
+		key, value, _ := strings.Cut(label, "=")
+
+		key = strings.TrimSpace(key)
+		if _, ok := labels[key]; ok {
+			return nil, fmt.Errorf("duplicate label: %s", key)
+		}
+
+		labels[key] = strings.TrimSpace(value)
+	}
+
+	return labels, nil
+}
+
 type deleteVaultItemsCmd struct {
 	*flaggy.Subcommand
 	firstItemId string
@@ -160,17 +197,13 @@ func (cmd *deleteVaultItemsCmd) run(state *config.State) {
 		return
 	}
 
-	passphrase := state.Config().Passphrase
-	if passphrase == nil {
-		passphrase = utils.AskForPassphrase()
-		defer passphrase.Destroy()
-	}
+	adminCredentials := state.Config().AdminCredentials()
 
 	deletedItemIds, err := grpcclient.Run(
 		state.Config(),
 		func(c grpcclient.GrpcClient) ([]string, error) {
 			return c.DeleteVaultItems(&proto.ItemDeletion{
-				Credentials: &proto.AdminCredentials{Passphrase: passphrase.String()},
+				Credentials: adminCredentials,
 				Id:          itemIds,
 			})
 		})
@@ -0,0 +1,267 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package login
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/awnumar/memguard"
+	"github.com/google/uuid"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"strings"
+	"time"
+)
+
+type Cmd struct {
+	*flaggy.Subcommand
+	plainText      bool
+	passphraseMode bool
+	keyMode        bool
+	scopes         []string
+	ttl            time.Duration
+	admin          string
+}
+
+func NewCmd() *Cmd {
+	loginCmd := &Cmd{
+		plainText:      false,
+		passphraseMode: false,
+		ttl:            15 * time.Minute,
+	}
+
+	cmd := flaggy.NewSubcommand("login")
+	cmd.Description = "Log in to a credential store"
+
+	cmd.Bool(&loginCmd.plainText, "t", "plain-text", "Store client credentials in plain text")
+	cmd.Bool(&loginCmd.passphraseMode, "p", "passphrase", "Start an admin session using the passphrase")
+	cmd.Bool(&loginCmd.keyMode, "k", "key", "Authenticate as client with an Ed25519 key kept in the keyring")
+	cmd.StringSlice(&loginCmd.scopes, "s", "scope", "Restrict the admin session to these scopes (read, reveal, write, admin)")
+	cmd.Duration(&loginCmd.ttl, "", "ttl", "How long the admin session remains valid")
+	cmd.String(&loginCmd.admin, "a", "admin", "Name of the admin to log in as (remembered for later calls)")
+
+	flaggy.AttachSubcommand(cmd, 1)
+
+	loginCmd.Subcommand = cmd
+
+	return loginCmd
+}
+
+func (cmd *Cmd) Run(state *config.State) {
+	state.Config().VerifyConnectionConfig()
+
+	if cmd.passphraseMode {
+		cmd.startSession(state)
+	} else if cmd.keyMode {
+		cmd.storeClientKey(state)
+	} else {
+		cmd.storeClientCredentials(state)
+	}
+
+	storeConfig(state)
+}
+
+func (cmd *Cmd) startSession(state *config.State) {
+	if cmd.plainText {
+		log.Warn().Msg("Refusing to store session in plain text, using the keyring instead")
+	}
+
+	if admin := strings.TrimSpace(cmd.admin); admin != "" {
+		state.Config().AdminName = admin
+	}
+
+	passphrase := utils.AskForPassphrase()
+	defer passphrase.Destroy()
+
+	var scopes []string
+	for _, scope := range cmd.scopes {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	session, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.Session, error) {
+			return c.Login(&proto.LoginRequest{
+				Credentials: &proto.AdminCredentials{Passphrase: passphrase.String(), Name: state.Config().AdminName},
+				Scopes:      scopes,
+				TtlSeconds:  int64(cmd.ttl.Seconds()),
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to log in")
+	}
+
+	if state.Config().SessionToken != nil {
+		state.Config().SessionToken.Destroy()
+	}
+
+	state.Config().SessionToken = memguard.NewBufferFromBytes([]byte(session.GetToken()))
+	state.Config().SessionExpiresAt = session.GetExpiresAt()
+	state.Config().SessionScopes = session.GetScopes()
+
+	log.Info().Msgf("Session started for %s with scopes: %s", session.GetAdmin(), strings.Join(session.GetScopes(), ", "))
+	log.Info().Msgf("Valid until %s", time.UnixMilli(session.GetExpiresAt()).Format(time.RFC3339))
+}
+
+func (cmd *Cmd) storeClientCredentials(state *config.State) {
+	clientId, err := utils.PromptSecure("Enter Client ID")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to read client ID")
+	}
+
+	clientSecret, err := utils.PromptSecure("Enter Client Secret")
+	if err != nil {
+		clientId.Destroy()
+		log.Fatal().Err(err).Msg("Failed to read client secret")
+	}
+
+	setClientCredentials(state.Config(), clientId, clientSecret, cmd.plainText)
+}
+
+// setClientCredentials hands the buffers to the config, which destroys them
+// once it's stored, the plain text credentials are copied out of them
+func setClientCredentials(cfg *config.Config, clientId *memguard.LockedBuffer, clientSecret *memguard.LockedBuffer, plainText bool) {
+	cfg.KeyClientId = ""
+
+	if plainText {
+		defer clientId.Destroy()
+		defer clientSecret.Destroy()
+
+		cfg.Credentials = &config.Credentials{
+			Id:     strings.Clone(clientId.String()),
+			Secret: strings.Clone(clientSecret.String()),
+		}
+	} else {
+		if cfg.SecureCredentials != nil {
+			cfg.SecureCredentials.Id.Destroy()
+			cfg.SecureCredentials.Secret.Destroy()
+		}
+
+		cfg.Credentials = nil
+		cfg.SecureCredentials = &config.SecureCredentials{
+			Id:     clientId,
+			Secret: clientSecret,
+		}
+	}
+}
+
+// storeClientKey generates a key pair unless one is already in the keyring,
+// the public key then has to be registered using `cred client create`.
+func (cmd *Cmd) storeClientKey(state *config.State) {
+	if cmd.plainText {
+		log.Fatal().Msg("Client keys are only kept in the keyring")
+	}
+
+	if state.Config().ClientKey == nil {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to generate client key")
+		}
+
+		state.Config().ClientKey = memguard.NewBufferFromBytes(privateKey.Seed())
+		memguard.WipeBytes(privateKey)
+
+		log.Info().Msg("Generated a new client key")
+	}
+
+	privateKey := ed25519.NewKeyFromSeed(state.Config().ClientKey.Bytes())
+	publicKey := base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+	memguard.WipeBytes(privateKey)
+
+	log.Info().Msgf("Public key: %s", publicKey)
+	log.Info().Msgf("Register it using: cred client create -d DESCRIPTION --public-key %s", publicKey)
+
+	clientId, err := utils.Prompt("Enter Client ID (leave empty if the key isn't registered yet)", state.Config().KeyClientId)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to read client ID")
+	}
+
+	if clientId = strings.TrimSpace(clientId); clientId == "" {
+		log.Info().Msg("Run `cred login --key` again once the key is registered")
+		return
+	}
+
+	if _, err = uuid.Parse(clientId); err != nil {
+		log.Fatal().Err(err).Msg("Failed to parse client ID")
+	}
+
+	state.Config().KeyClientId = clientId
+
+	log.Info().Msgf("Authenticating as client %s using the client key", clientId)
+}
+
+type LogoutCmd struct {
+	*flaggy.Subcommand
+}
+
+func NewLogoutCmd() *LogoutCmd {
+	logoutCmd := &LogoutCmd{}
+
+	cmd := flaggy.NewSubcommand("logout")
+	cmd.Description = "Ends the current admin session"
+
+	flaggy.AttachSubcommand(cmd, 1)
+
+	logoutCmd.Subcommand = cmd
+
+	return logoutCmd
+}
+
+func (cmd *LogoutCmd) Run(state *config.State) {
+	state.Config().VerifyConnectionConfig()
+
+	if !state.Config().HasSession() {
+		log.Info().Msg("No active session")
+	} else {
+		_, err := grpcclient.Run(
+			state.Config(),
+			func(c grpcclient.GrpcClient) (any, error) {
+				return nil, c.Logout()
+			},
+		)
+
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to revoke session on the store")
+		} else {
+			log.Info().Msg("Session revoked")
+		}
+	}
+
+	if state.Config().SessionToken != nil {
+		state.Config().SessionToken.Destroy()
+		state.Config().SessionToken = nil
+	}
+
+	storeConfig(state)
+}
+
+func storeConfig(state *config.State) {
+	configDir := state.ConfigDir()
+
+	err := config.Store(&configDir, *state.Config())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to store configuration")
+	}
+}
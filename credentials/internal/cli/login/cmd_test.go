@@ -0,0 +1,55 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package login
+
+import (
+	"github.com/awnumar/memguard"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetClientCredentials_PlainText(t *testing.T) {
+	configDir := t.TempDir()
+
+	cfg := &config.Config{StoreHost: "localhost"}
+	setClientCredentials(cfg, memguard.NewBufferFromBytes([]byte("client-id")), memguard.NewBufferFromBytes([]byte("client-secret")), true)
+
+	require.NoError(t, config.Store(&configDir, *cfg))
+
+	loaded, err := config.Load(filepath.Join(configDir, "config.toml"))
+	require.NoError(t, err)
+	require.NotNil(t, loaded.Credentials)
+	assert.Equal(t, "client-id", loaded.Credentials.Id)
+	assert.Equal(t, "client-secret", loaded.Credentials.Secret)
+}
+
+func TestSetClientCredentials_Keyring(t *testing.T) {
+	cfg := &config.Config{StoreHost: "localhost", Credentials: &config.Credentials{Id: "old", Secret: "old"}}
+	setClientCredentials(cfg, memguard.NewBufferFromBytes([]byte("client-id")), memguard.NewBufferFromBytes([]byte("client-secret")), false)
+
+	// the buffers are still alive when the config is stored
+	assert.Nil(t, cfg.Credentials)
+	require.NotNil(t, cfg.SecureCredentials)
+	assert.Equal(t, "client-id", cfg.SecureCredentials.Id.String())
+	assert.Equal(t, "client-secret", cfg.SecureCredentials.Secret.String())
+
+	cfg.Destroy()
+	assert.False(t, cfg.SecureCredentials.Id.IsAlive())
+}
@@ -24,13 +24,17 @@ import (
 	"github.com/vemilyus/borg-collective/credentials/internal/proto"
 	"golang.org/x/crypto/ssh/terminal"
 	"os"
+	"time"
 )
 
 type Cmd struct {
 	*flaggy.Subcommand
 	*infoCmd
+	*statusCmd
+	*initCmd
 	*unlockCmd
 	*lockCmd
+	*promoteCmd
 	*setRecoveryRecipientCmd
 	*exportCmd
 }
@@ -45,8 +49,11 @@ func NewCmd() *Cmd {
 
 	storeCmd.Subcommand = cmd
 	storeCmd.infoCmd = newInfoCmd(cmd)
+	storeCmd.statusCmd = newStatusCmd(cmd)
+	storeCmd.initCmd = newInitCmd(cmd)
 	storeCmd.unlockCmd = newUnlockCmd(cmd)
 	storeCmd.lockCmd = newLockCmd(cmd)
+	storeCmd.promoteCmd = newPromoteCmd(cmd)
 	storeCmd.setRecoveryRecipientCmd = newSetRecoveryRecipientCmd(cmd)
 	storeCmd.exportCmd = newExportCmd(cmd)
 
@@ -58,10 +65,16 @@ func (cmd *Cmd) Run(state *config.State) {
 
 	if cmd.infoCmd.Used {
 		cmd.infoCmd.run(state)
+	} else if cmd.statusCmd.Used {
+		cmd.statusCmd.run(state)
+	} else if cmd.initCmd.Used {
+		cmd.initCmd.run(state)
 	} else if cmd.unlockCmd.Used {
 		cmd.unlockCmd.run(state)
 	} else if cmd.lockCmd.Used {
 		cmd.lockCmd.run(state)
+	} else if cmd.promoteCmd.Used {
+		cmd.promoteCmd.run(state)
 	} else if cmd.setRecoveryRecipientCmd.Used {
 		cmd.setRecoveryRecipientCmd.run(state)
 	} else if cmd.exportCmd.Used {
@@ -115,8 +128,13 @@ func (cmd *infoCmd) run(state *config.State) {
 	} else {
 		log.Info().Msg("Remote store info")
 		log.Info().Msgf("    Version: %s", storeInfo.GetVersion())
+		log.Info().Msgf("    Initialized: %v", storeInfo.GetIsVaultInitialized())
 		log.Info().Msgf("    Locked: %v", storeInfo.GetIsVaultLocked())
 		log.Info().Msgf("    Production mode: %v", storeInfo.GetIsProduction())
+
+		if storeInfo.GetIsReplica() {
+			log.Info().Msg("    Replica: true")
+		}
 	}
 }
 
@@ -146,7 +164,7 @@ func (cmd *unlockCmd) run(state *config.State) {
 	_, err := grpcclient.Run(
 		state.Config(),
 		func(c grpcclient.GrpcClient) (any, error) {
-			return nil, c.UnlockVault(&proto.AdminCredentials{Passphrase: passphrase.String()})
+			return nil, c.UnlockVault(&proto.AdminCredentials{Passphrase: passphrase.String(), Name: state.Config().AdminName})
 		},
 	)
 
@@ -188,3 +206,83 @@ func (cmd *lockCmd) run(state *config.State) {
 
 	log.Info().Msgf("Locked remote store at %s", state.Config().HostString())
 }
+
+func printReplicationStatus(replication *proto.ReplicationStatus) {
+	log.Info().Msg("    Replication:")
+
+	if replication.GetRole() == proto.ReplicationRole_REPLICATION_REPLICA {
+		log.Info().Msg("        Role: replica")
+		log.Info().Msgf("        Primary: %s", replication.GetPrimary())
+
+		if replication.GetLastSyncAt() > 0 {
+			log.Info().Msgf("        Last sync: %s", time.UnixMilli(replication.GetLastSyncAt()).Format(time.RFC3339))
+			log.Info().Msgf("        Lag: %s", time.Duration(replication.GetLagMillis())*time.Millisecond)
+		} else {
+			log.Info().Msg("        Last sync: never")
+		}
+
+		if lastError := replication.GetLastError(); lastError != "" {
+			log.Warn().Msgf("        Last error: %s", lastError)
+		}
+
+		return
+	}
+
+	log.Info().Msg("        Role: primary")
+	if replication.GetPromoted() {
+		log.Info().Msg("        Promoted from replica: true")
+	}
+
+	for _, replica := range replication.GetReplicas() {
+		log.Info().Msgf(
+			"        Replica %s, last seen %s",
+			replica.GetSource(),
+			time.UnixMilli(replica.GetLastSeenAt()).Format(time.RFC3339),
+		)
+	}
+}
+
+type promoteCmd struct {
+	*flaggy.Subcommand
+}
+
+func newPromoteCmd(parent *flaggy.Subcommand) *promoteCmd {
+	pCmd := &promoteCmd{}
+
+	cmd := flaggy.NewSubcommand("promote")
+	cmd.Description = "Promotes the remote replica to be the primary store"
+
+	parent.AttachSubcommand(cmd, 1)
+
+	pCmd.Subcommand = cmd
+
+	return pCmd
+}
+
+func (cmd *promoteCmd) run(state *config.State) {
+	log.Warn().Msg("The replica stops following its primary for good,\n  make sure the former primary doesn't serve anymore!")
+	doPromote, err := utils.PromptConfirm("Confirm promoting the replica", false)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to confirm")
+	}
+
+	if !doPromote {
+		log.Info().Msg("Not promoting the replica, user aborted")
+		return
+	}
+
+	adminCredentials := state.Config().AdminCredentials()
+
+	_, err = grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.StoreInfo, error) {
+			return c.PromoteReplica(adminCredentials)
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to promote replica")
+	}
+
+	log.Info().Msgf("Promoted %s to primary", state.Config().HostString())
+}
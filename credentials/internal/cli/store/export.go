@@ -86,17 +86,19 @@ func (cmd *exportCmd) run(state *config.State) {
 	finalData, err := grpcclient.Run(
 		state.Config(),
 		func(c grpcclient.GrpcClient) (*exportData, error) {
-			rawItems, err := c.ListVaultItems(&proto.ItemSearch{Credentials: &proto.AdminCredentials{Passphrase: passphrase.String()}})
+			page, err := c.ListVaultItems(&proto.ItemSearch{Credentials: &proto.AdminCredentials{Passphrase: passphrase.String(), Name: state.Config().AdminName}})
 			if err != nil {
 				log.Fatal().Err(err).Msg("Failed to retrieve list of items")
 			}
 
+			rawItems := page.Items
+
 			log.Info().Msgf("Retrieved %d items", len(rawItems))
 
 			var exportItems []*exportItem
 			for _, rawItem := range rawItems {
 				value, err := c.ReadVaultItem(&proto.ItemRequest{
-					Credentials: &proto.ItemRequest_Admin{Admin: &proto.AdminCredentials{Passphrase: passphrase.String()}},
+					Credentials: &proto.ItemRequest_Admin{Admin: &proto.AdminCredentials{Passphrase: passphrase.String(), Name: state.Config().AdminName}},
 					ItemId:      rawItem.GetId(),
 				})
 
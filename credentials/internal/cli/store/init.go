@@ -0,0 +1,135 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"crypto/subtle"
+	"filippo.io/age"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/utils"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"os"
+	"time"
+)
+
+type initCmd struct {
+	*flaggy.Subcommand
+	recoveryRecipient string
+	generateRecovery  bool
+}
+
+func newInitCmd(parent *flaggy.Subcommand) *initCmd {
+	iCmd := &initCmd{}
+
+	cmd := flaggy.NewSubcommand("init")
+	cmd.Description = "Creates the vault of a new remote store"
+
+	cmd.String(&iCmd.recoveryRecipient, "r", "recovery-recipient", "The age recipient to additionally encrypt items for")
+	cmd.Bool(&iCmd.generateRecovery, "g", "generate-recovery", "Generate a new recovery identity and use its recipient")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	iCmd.Subcommand = cmd
+
+	return iCmd
+}
+
+func (cmd *initCmd) run(state *config.State) {
+	if cmd.recoveryRecipient != "" && cmd.generateRecovery {
+		log.Fatal().Msg("Either specify a recovery recipient or generate one, not both")
+	}
+
+	if cmd.recoveryRecipient != "" {
+		if _, err := age.ParseX25519Recipient(cmd.recoveryRecipient); err != nil {
+			log.Fatal().Err(err).Msg("Invalid recovery recipient")
+		}
+	}
+
+	log.Info().Msgf("Initializing the vault of the remote store at %s", state.Config().HostString())
+
+	passphrase, err := utils.PromptSecure("Enter new passphrase")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to enter passphrase")
+	}
+
+	defer passphrase.Destroy()
+
+	confirmation, err := utils.PromptSecure("Repeat passphrase")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to enter passphrase")
+	}
+
+	defer confirmation.Destroy()
+
+	if passphrase.Size() == 0 {
+		log.Fatal().Msg("The passphrase must not be empty")
+	} else if subtle.ConstantTimeCompare(passphrase.Bytes(), confirmation.Bytes()) != 1 {
+		log.Fatal().Msg("The passphrases don't match")
+	}
+
+	if cmd.generateRecovery {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to generate identity")
+		}
+
+		log.Info().Msg("New recovery identity generated...")
+		log.Warn().Msg("Save the new identity now, it will never be shown again!")
+		log.Info().Send()
+
+		_, _ = os.Stdout.WriteString(identity.String())
+
+		log.Info().Send()
+
+		doConfirm, err := utils.PromptConfirm("Did you save the recovery identity?", false)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to confirm")
+		}
+
+		if !doConfirm {
+			log.Info().Msg("Not initializing the vault, user aborted")
+			return
+		}
+
+		cmd.recoveryRecipient = identity.Recipient().String()
+	}
+
+	info, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.VaultInfo, error) {
+			return c.InitializeVault(&proto.VaultInitialization{
+				Passphrase:        passphrase.String(),
+				RecoveryRecipient: cmd.recoveryRecipient,
+			})
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize the vault")
+	}
+
+	log.Info().Msg("Initialized and unlocked the vault")
+	log.Info().Msgf("    ID: %s", info.GetId())
+	log.Info().Msgf("    Production mode: %v", info.GetSecure())
+	log.Info().Msgf("    Created: %s", time.UnixMilli(info.GetCreatedAt()).Format(time.RFC3339))
+
+	if cmd.recoveryRecipient == "" {
+		log.Warn().Msg("No recovery recipient set, the items can't be recovered without the passphrase")
+	}
+}
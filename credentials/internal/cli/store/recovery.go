@@ -84,7 +84,7 @@ func (cmd *setRecoveryRecipientCmd) run(state *config.State) {
 		state.Config(),
 		func(c grpcclient.GrpcClient) (any, error) {
 			return nil, c.SetRecoveryRecipient(&proto.RecoveryRecipient{
-				Credentials: &proto.AdminCredentials{Passphrase: passphrase.String()},
+				Credentials: &proto.AdminCredentials{Passphrase: passphrase.String(), Name: state.Config().AdminName},
 				Recipient:   identity.Recipient().String(),
 			})
 		},
@@ -0,0 +1,151 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"fmt"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"os"
+	"time"
+)
+
+// certificates expiring sooner are highlighted
+const certificateExpiryWarning = 14 * 24 * time.Hour
+
+type statusCmd struct {
+	*flaggy.Subcommand
+	json bool
+}
+
+func newStatusCmd(parent *flaggy.Subcommand) *statusCmd {
+	sCmd := &statusCmd{
+		json: false,
+	}
+
+	cmd := flaggy.NewSubcommand("status")
+	cmd.Description = "Shows the detailed status of the remote store, requires an unlocked vault"
+
+	cmd.Bool(&sCmd.json, "", "json", "Print the status as JSON")
+
+	parent.AttachSubcommand(cmd, 1)
+
+	sCmd.Subcommand = cmd
+
+	return sCmd
+}
+
+func (cmd *statusCmd) run(state *config.State) {
+	adminCredentials := state.Config().AdminCredentials()
+
+	status, err := grpcclient.Run(
+		state.Config(),
+		func(c grpcclient.GrpcClient) (*proto.StoreStatus, error) {
+			return c.GetStatus(adminCredentials)
+		},
+	)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to get store status")
+	}
+
+	if cmd.json {
+		marshalled, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(status)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to marshal store status")
+		}
+
+		_, _ = os.Stdout.Write(append(marshalled, '\n'))
+		return
+	}
+
+	info := status.GetInfo()
+
+	log.Info().Msg("Remote store status")
+	log.Info().Msgf("    Version: %s", info.GetVersion())
+	log.Info().Msgf("    Production mode: %v", info.GetIsProduction())
+	log.Info().Msgf("    Uptime: %s (since %s)", (time.Duration(status.GetUptimeMillis()) * time.Millisecond).Round(time.Second), formatTime(status.GetStartedAt()))
+
+	log.Info().Msg("    Vault:")
+	if status.GetVaultId() != "" {
+		log.Info().Msgf("        ID: %s", status.GetVaultId())
+	}
+
+	log.Info().Msgf("        Format version: %d", status.GetVaultFormatVersion())
+	log.Info().Msgf("        Items: %d", status.GetItemCount())
+	log.Info().Msgf("        Clients: %d", status.GetClientCount())
+	log.Info().Msgf("        Pending approvals: %d", status.GetPendingApprovals())
+	log.Info().Msgf("        Storage size: %s", formatBytes(status.GetStorageBytes()))
+
+	if status.GetLastUnlockAt() > 0 {
+		log.Info().Msgf("        Last unlocked: %s by %s", formatTime(status.GetLastUnlockAt()), status.GetLastUnlockBy())
+	}
+
+	if fingerprints := status.GetRecoveryRecipientFingerprints(); len(fingerprints) > 0 {
+		for _, fingerprint := range fingerprints {
+			log.Info().Msgf("        Recovery recipient: %s", fingerprint)
+		}
+	} else {
+		log.Warn().Msg("        Recovery recipient: not set")
+	}
+
+	if certificate := status.GetTlsCertificate(); certificate != nil {
+		log.Info().Msg("    TLS certificate:")
+		log.Info().Msgf("        Subject: %s", certificate.GetSubject())
+
+		notAfter := time.UnixMilli(certificate.GetNotAfter())
+		if time.Until(notAfter) < certificateExpiryWarning {
+			log.Warn().Msgf("        Expires: %s", notAfter.Format(time.RFC3339))
+		} else {
+			log.Info().Msgf("        Expires: %s", notAfter.Format(time.RFC3339))
+		}
+	}
+
+	if counters := status.GetAuthCounters(); counters != nil {
+		log.Info().Msg("    Authentication:")
+		log.Info().Msgf("        Failures: %d (admin: %d, client: %d)", counters.GetFailures(), counters.GetAdminFailures(), counters.GetClientFailures())
+		log.Info().Msgf("        Rejected calls: %d", counters.GetRejectedCalls())
+		log.Info().Msgf("        Blocked sources: %d", counters.GetBlockedSources())
+		log.Info().Msgf("        Auto-locks: %d", counters.GetAutoLocks())
+	}
+
+	if replication := status.GetReplication(); replication != nil {
+		printReplicationStatus(replication)
+	}
+}
+
+func formatTime(unixMilli int64) string {
+	return time.UnixMilli(unixMilli).Format(time.RFC3339)
+}
+
+func formatBytes(size uint64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
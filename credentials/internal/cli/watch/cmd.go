@@ -0,0 +1,142 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/integrii/flaggy"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/config"
+	"github.com/vemilyus/borg-collective/credentials/internal/cli/grpcclient"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"strings"
+	"time"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+type Cmd struct {
+	*flaggy.Subcommand
+	itemIds     []string
+	resumeToken string
+}
+
+func NewCmd() *Cmd {
+	watchCmd := &Cmd{}
+
+	cmd := flaggy.NewSubcommand("watch")
+	cmd.Description = "Prints changes to the vault as they happen, reconnecting if the connection is lost"
+
+	cmd.StringSlice(&watchCmd.itemIds, "i", "item", "Only watch the items with these IDs")
+	cmd.String(&watchCmd.resumeToken, "r", "resume", "Continue after the event with this token (the last column)")
+
+	flaggy.AttachSubcommand(cmd, 1)
+
+	watchCmd.Subcommand = cmd
+
+	return watchCmd
+}
+
+func (cmd *Cmd) Run(state *config.State) {
+	state.Config().VerifyConnectionConfig()
+
+	for _, rawId := range cmd.itemIds {
+		if _, err := uuid.Parse(rawId); err != nil {
+			log.Fatal().Err(err).Msg("Failed to parse item ID")
+		}
+	}
+
+	reader, err := grpcclient.NewReader(state.Config())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to parse client ID")
+	}
+
+	token := cmd.resumeToken
+	delay := minReconnectDelay
+
+	for {
+		_, err = grpcclient.Run(
+			state.Config(),
+			func(c grpcclient.GrpcClient) (any, error) {
+				request := &proto.WatchRequest{ResumeToken: token, ItemIds: cmd.itemIds}
+
+				key, err := reader.Key(c)
+				if err != nil {
+					return nil, err
+				}
+
+				if key != nil {
+					request.Credentials = &proto.WatchRequest_Key{Key: key}
+				} else if reader.Client != nil {
+					request.Credentials = &proto.WatchRequest_Client{Client: reader.Client}
+				} else if reader.Admin != nil {
+					request.Credentials = &proto.WatchRequest_Admin{Admin: reader.Admin}
+				}
+
+				return nil, c.WatchVault(request, func(event *proto.WatchEvent) error {
+					token = event.GetResumeToken()
+					delay = minReconnectDelay
+
+					printEvent(event)
+
+					return nil
+				})
+			},
+		)
+
+		var retryable *grpcclient.RetryableError
+		if err != nil && !errors.As(err, &retryable) {
+			log.Fatal().Err(err).Msg("Failed to watch vault")
+		}
+
+		if err != nil {
+			log.Warn().Err(err).Msgf("Watch interrupted, reconnecting in %s", delay)
+		} else {
+			log.Info().Msgf("Watch ended by the store, reconnecting in %s", delay)
+		}
+
+		time.Sleep(delay)
+		delay = min(2*delay, maxReconnectDelay)
+	}
+}
+
+func printEvent(event *proto.WatchEvent) {
+	subject := event.GetClientId()
+	if item := event.GetItem(); item != nil {
+		subject = item.GetId()
+		if item.GetDescription() != "" {
+			subject += " " + item.GetDescription()
+		}
+	}
+
+	timestamp := ""
+	if event.GetTimestamp() > 0 {
+		timestamp = time.UnixMilli(event.GetTimestamp()).Format(time.RFC3339)
+	}
+
+	fmt.Printf(
+		"%s\t%s\t%s\t%s\n",
+		timestamp,
+		strings.ToLower(strings.TrimPrefix(event.GetType().String(), "WATCH_")),
+		subject,
+		event.GetResumeToken(),
+	)
+}
@@ -0,0 +1,35 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build linux
+
+package logging
+
+import (
+	"errors"
+	"github.com/coreos/go-systemd/v22/journal"
+	"github.com/rs/zerolog/journald"
+	"io"
+)
+
+// journaldWriter sends the entries using the native journal protocol, the
+// fields of an entry become journal fields
+func journaldWriter() (io.Writer, error) {
+	if !journal.Enabled() {
+		return nil, errors.New("journald isn't available")
+	}
+
+	return journald.NewJournalDWriter(), nil
+}
@@ -16,15 +16,41 @@
 package logging
 
 import (
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"io"
 	golog "log"
 	"os"
 	"strings"
 	"time"
 )
 
+const (
+	FormatConsole = "console"
+	FormatJson    = "json"
+)
+
+// Options configure where and how the store logs, see ConfigureLogging
+type Options struct {
+	// FormatConsole or FormatJson, journald always receives structured
+	// entries
+	Format   string
+	Stdout   bool
+	File     *FileOptions
+	Journald bool
+	NoColor  bool
+}
+
+type FileOptions struct {
+	Path string
+	// size in bytes after which the file is rotated
+	MaxSize int64
+	// number of rotated files which are kept
+	MaxBackups int
+}
+
 type zerologLogger struct {
 	logger zerolog.Logger
 }
@@ -37,16 +63,96 @@ func (z *zerologLogger) Write(p []byte) (n int, err error) {
 }
 
 func InitLogging(prod bool) {
-	logWriter := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339, NoColor: prod}
+	SetDefaultLevel(prod)
+
+	initLogging(consoleWriter(os.Stdout, prod))
+}
+
+// ConfigureLogging replaces the output set up by InitLogging. The returned
+// function closes the log file, if any.
+func ConfigureLogging(options Options) (func(), error) {
+	var writers []io.Writer
+	closeOutputs := func() {}
+
+	if options.Stdout {
+		writers = append(writers, formatWriter(os.Stdout, options.Format, options.NoColor))
+	}
+
+	if options.File != nil {
+		file, err := OpenRotatingFile(options.File.Path, options.File.MaxSize, options.File.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+
+		writers = append(writers, formatWriter(file, options.Format, true))
+		closeOutputs = func() {
+			_ = file.Close()
+		}
+	}
+
+	if options.Journald {
+		writer, err := journaldWriter()
+		if err != nil {
+			closeOutputs()
+			return nil, err
+		}
+
+		writers = append(writers, writer)
+	}
+
+	if len(writers) == 0 {
+		return nil, errors.New("no log output configured")
+	}
+
+	initLogging(zerolog.MultiLevelWriter(writers...))
+
+	return closeOutputs, nil
+}
+
+func formatWriter(out io.Writer, format string, noColor bool) io.Writer {
+	if format == FormatJson {
+		return out
+	}
+
+	return consoleWriter(out, noColor)
+}
+
+func consoleWriter(out io.Writer, noColor bool) zerolog.ConsoleWriter {
+	logWriter := zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339, NoColor: noColor}
 	logWriter.FormatLevel = func(i interface{}) string {
 		return strings.ToUpper(fmt.Sprintf("| %5s |", i))
 	}
 
+	return logWriter
+}
+
+// SetDefaultLevel sets the level used if none is configured, info in
+// production mode and debug otherwise
+func SetDefaultLevel(prod bool) {
 	if prod {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
+}
 
-	initLogging(logWriter)
+// ParseLevel parses a configured log level, only the levels which are
+// actually used are accepted.
+func ParseLevel(level string) (zerolog.Level, error) {
+	switch strings.ToLower(level) {
+	case "trace":
+		return zerolog.TraceLevel, nil
+	case "debug":
+		return zerolog.DebugLevel, nil
+	case "info":
+		return zerolog.InfoLevel, nil
+	case "warn":
+		return zerolog.WarnLevel, nil
+	case "error":
+		return zerolog.ErrorLevel, nil
+	default:
+		return zerolog.NoLevel, fmt.Errorf("unknown log level %s", level)
+	}
 }
 
 func InitSimpleLogging() {
@@ -67,9 +173,13 @@ func InitSimpleLogging() {
 	initLogging(logWriter)
 }
 
-func initLogging(logWriter zerolog.ConsoleWriter) {
+func initLogging(logWriter io.Writer) {
 	log.Logger = log.Output(logWriter)
 
+	// calls log through the logger of their context, which carries e.g. the
+	// request ID
+	zerolog.DefaultContextLogger = &log.Logger
+
 	golog.SetFlags(0)
 	golog.SetOutput(&zerologLogger{logger: log.Logger})
 }
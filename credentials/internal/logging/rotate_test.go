@@ -0,0 +1,67 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+
+	file, err := OpenRotatingFile(path, 10, 2)
+	assert.NoError(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = file.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, file.Close())
+
+	assertContent := func(path string, expected string) {
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(data))
+	}
+
+	assertContent(path, "fourth\n")
+	assertContent(path+".1", "third\n")
+	assertContent(path+".2", "second\n")
+
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRotatingFile_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+	assert.NoError(t, os.WriteFile(path, []byte("existing\n"), 0o600))
+
+	file, err := OpenRotatingFile(path, 12, 0)
+	assert.NoError(t, err)
+
+	_, err = file.Write([]byte("next\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "next\n", string(data))
+}
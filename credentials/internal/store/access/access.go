@@ -0,0 +1,229 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package access
+
+import (
+	"fmt"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Decision is the outcome of checking a source address, Rule describes the
+// rule which matched.
+type Decision struct {
+	Allowed bool
+	Rule    string
+}
+
+type network struct {
+	*net.IPNet
+	raw string
+}
+
+// Policy decides which source addresses may connect to the store, and from
+// where individual clients may access it.
+type Policy struct {
+	lock       sync.RWMutex
+	allowLocal bool
+	allow      []network
+	deny       []network
+	clients    map[string][]network
+}
+
+func NewPolicy(config *store.AccessConfig, prod bool) (*Policy, error) {
+	policy := &Policy{
+		allowLocal: !prod,
+		clients:    make(map[string][]network),
+	}
+
+	if config == nil {
+		return policy, nil
+	}
+
+	if config.AllowLocal != nil {
+		policy.allowLocal = *config.AllowLocal
+	}
+
+	var err error
+	if policy.allow, err = parseNetworks(config.Allow); err != nil {
+		return nil, fmt.Errorf("invalid allow rule: %v", err)
+	}
+
+	if policy.deny, err = parseNetworks(config.Deny); err != nil {
+		return nil, fmt.Errorf("invalid deny rule: %v", err)
+	}
+
+	for clientId, rawNetworks := range config.Clients {
+		networks, err := parseNetworks(rawNetworks)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule for client %s: %v", clientId, err)
+		}
+
+		policy.clients[strings.ToLower(clientId)] = networks
+	}
+
+	return policy, nil
+}
+
+// Update replaces the rules with the ones of the configuration, the current
+// rules are kept if the configuration is invalid.
+func (p *Policy) Update(config *store.AccessConfig, prod bool) error {
+	fresh, err := NewPolicy(config, prod)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.allowLocal = fresh.allowLocal
+	p.allow = fresh.allow
+	p.deny = fresh.deny
+	p.clients = fresh.clients
+
+	return nil
+}
+
+// CheckSource decides whether a connection from remote to local is accepted.
+// Deny rules are checked first, then local connections are handled by the
+// allow local switch, and finally the allow rules apply.
+func (p *Policy) CheckSource(remote net.IP, local net.IP) (Decision, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if n := match(p.deny, remote); n != nil {
+		return Decision{Allowed: false, Rule: "deny " + n.raw}, nil
+	}
+
+	isLocal, err := isLocalHost(remote, local)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	if isLocal {
+		if p.allowLocal {
+			return Decision{Allowed: true, Rule: "allow local"}, nil
+		}
+
+		return Decision{Allowed: false, Rule: "deny local"}, nil
+	}
+
+	if len(p.allow) == 0 {
+		return Decision{Allowed: true, Rule: "allow all"}, nil
+	}
+
+	if n := match(p.allow, remote); n != nil {
+		return Decision{Allowed: true, Rule: "allow " + n.raw}, nil
+	}
+
+	return Decision{Allowed: false, Rule: "not in allow list"}, nil
+}
+
+// CheckClient decides whether the authenticated client may access the store
+// from the remote address. Clients without restrictions may connect from any
+// source accepted by CheckSource.
+func (p *Policy) CheckClient(clientId string, remote net.IP) Decision {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	networks, ok := p.clients[strings.ToLower(clientId)]
+	if !ok {
+		return Decision{Allowed: true, Rule: "client unrestricted"}
+	}
+
+	if remote == nil {
+		return Decision{Allowed: false, Rule: "client " + clientId + " unknown source"}
+	}
+
+	if n := match(networks, remote); n != nil {
+		return Decision{Allowed: true, Rule: "client " + clientId + " allow " + n.raw}
+	}
+
+	return Decision{Allowed: false, Rule: "client " + clientId + " not in allow list"}
+}
+
+func parseNetworks(rawNetworks []string) ([]network, error) {
+	var networks []network
+
+	for _, raw := range rawNetworks {
+		raw = strings.TrimSpace(raw)
+
+		if !strings.Contains(raw, "/") {
+			ip := net.ParseIP(raw)
+			if ip == nil {
+				return nil, fmt.Errorf("not an IP address or network: %s", raw)
+			}
+
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+
+			networks = append(networks, network{
+				IPNet: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)},
+				raw:   raw,
+			})
+
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network{IPNet: ipNet, raw: raw})
+	}
+
+	return networks, nil
+}
+
+func match(networks []network, ip net.IP) *network {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return &n
+		}
+	}
+
+	return nil
+}
+
+func isLocalHost(remoteIP, localIP net.IP) (bool, error) {
+	if remoteIP.IsLoopback() {
+		return true, nil
+	}
+
+	if remoteIP.Equal(localIP) {
+		return true, nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false, err
+	}
+
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			if ipnet.IP.Equal(remoteIP) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
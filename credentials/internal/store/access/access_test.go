@@ -0,0 +1,130 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package access
+
+import (
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var storeIP = net.ParseIP("192.0.2.1")
+
+func TestCheckSource_Defaults(t *testing.T) {
+	policy, err := NewPolicy(nil, true)
+	assert.NoError(t, err)
+
+	decision, err := policy.CheckSource(net.ParseIP("127.0.0.1"), storeIP)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed) // local connections are rejected in production
+	assert.Equal(t, "deny local", decision.Rule)
+
+	decision, err = policy.CheckSource(net.ParseIP("198.51.100.7"), storeIP)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	policy, err = NewPolicy(nil, false)
+	assert.NoError(t, err)
+
+	decision, err = policy.CheckSource(net.ParseIP("127.0.0.1"), storeIP)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestCheckSource_Rules(t *testing.T) {
+	allowLocal := true
+	policy, err := NewPolicy(&store.AccessConfig{
+		AllowLocal: &allowLocal,
+		Allow:      []string{"10.20.0.0/16"},
+		Deny:       []string{"10.20.99.0/24", "127.0.0.2"},
+	}, true)
+	assert.NoError(t, err)
+
+	decision, _ := policy.CheckSource(net.ParseIP("127.0.0.1"), storeIP)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, "allow local", decision.Rule)
+
+	decision, _ = policy.CheckSource(net.ParseIP("127.0.0.2"), storeIP)
+	assert.False(t, decision.Allowed) // deny takes precedence
+	assert.Equal(t, "deny 127.0.0.2", decision.Rule)
+
+	decision, _ = policy.CheckSource(net.ParseIP("10.20.1.5"), storeIP)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, "allow 10.20.0.0/16", decision.Rule)
+
+	decision, _ = policy.CheckSource(net.ParseIP("10.20.99.5"), storeIP)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "deny 10.20.99.0/24", decision.Rule)
+
+	decision, _ = policy.CheckSource(net.ParseIP("198.51.100.7"), storeIP)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "not in allow list", decision.Rule)
+}
+
+func TestCheckClient(t *testing.T) {
+	policy, err := NewPolicy(&store.AccessConfig{
+		Clients: map[string][]string{
+			"3F2504E0-4F89-11D3-9A0C-0305E82C3301": {"10.20.1.0/24"},
+		},
+	}, true)
+	assert.NoError(t, err)
+
+	decision := policy.CheckClient("3f2504e0-4f89-11d3-9a0c-0305e82c3301", net.ParseIP("10.20.1.9"))
+	assert.True(t, decision.Allowed)
+
+	decision = policy.CheckClient("3f2504e0-4f89-11d3-9a0c-0305e82c3301", net.ParseIP("10.20.2.9"))
+	assert.False(t, decision.Allowed)
+
+	decision = policy.CheckClient("other", net.ParseIP("10.20.2.9"))
+	assert.True(t, decision.Allowed)
+}
+
+func TestNewPolicy_Invalid(t *testing.T) {
+	_, err := NewPolicy(&store.AccessConfig{Allow: []string{"10.0.0.0/33"}}, true)
+	assert.Error(t, err)
+
+	_, err = NewPolicy(&store.AccessConfig{Deny: []string{"example.com"}}, true)
+	assert.Error(t, err)
+}
+
+func TestUnixPolicy(t *testing.T) {
+	policy, err := NewUnixPolicy(&store.UnixSocketConfig{
+		Users: map[string]*store.UnixPeerConfig{
+			"1000": {ClientId: "3F2504E0-4F89-11D3-9A0C-0305E82C3301", Items: []string{"ABC"}},
+		},
+		Groups: map[string]*store.UnixPeerConfig{
+			"2000": {AllowAdmin: true},
+		},
+	})
+	assert.NoError(t, err)
+
+	peer, decision := policy.Resolve(PeerIdentity{Uid: 1000, Gid: 2000})
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, "user 1000", decision.Rule) // the user takes precedence
+	assert.Equal(t, "3f2504e0-4f89-11d3-9a0c-0305e82c3301", peer.ClientId)
+	assert.True(t, peer.MayRead("abc"))
+	assert.False(t, peer.MayRead("def"))
+
+	peer, decision = policy.Resolve(PeerIdentity{Uid: 1001, Gid: 2000})
+	assert.True(t, decision.Allowed)
+	assert.True(t, peer.AllowAdmin)
+	assert.True(t, peer.MayRead("def"))
+
+	_, decision = policy.Resolve(PeerIdentity{Uid: 1001, Gid: 2001})
+	assert.False(t, decision.Allowed)
+}
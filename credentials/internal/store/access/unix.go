@@ -0,0 +1,217 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package access
+
+import (
+	"context"
+	"fmt"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"google.golang.org/grpc/peer"
+	"net"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PeerIdentity is the identity of a process connected via Unix socket, as
+// reported by the kernel.
+type PeerIdentity struct {
+	Uid uint32
+	Gid uint32
+	Pid int32
+}
+
+// UnixPeer is an accepted peer on the Unix socket along with the settings
+// that apply to it. It's attached to calls as their auth info.
+type UnixPeer struct {
+	PeerIdentity
+	Rule       string
+	ClientId   string
+	Items      []string
+	AllowAdmin bool
+}
+
+func (p *UnixPeer) AuthType() string {
+	return "unix"
+}
+
+// UnixPeerFromContext returns the peer of a call made via the Unix socket, or
+// nil if the call was made some other way.
+func UnixPeerFromContext(ctx context.Context) *UnixPeer {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	unixPeer, _ := p.AuthInfo.(*UnixPeer)
+
+	return unixPeer
+}
+
+// SourceAddress identifies the origin of a call, the uid for peers on the Unix
+// socket and the IP address otherwise
+func SourceAddress(ctx context.Context) string {
+	if unixPeer := UnixPeerFromContext(ctx); unixPeer != nil {
+		return fmt.Sprintf("uid:%d", unixPeer.Uid)
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+
+	if tcpAddr, ok := p.Addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+
+	return p.Addr.String()
+}
+
+func (p *UnixPeer) MayRead(itemId string) bool {
+	return len(p.Items) == 0 || slices.Contains(p.Items, strings.ToLower(itemId))
+}
+
+type unixRule struct {
+	name   string
+	config *store.UnixPeerConfig
+}
+
+// UnixPolicy maps local users and groups to their settings. Peers without a
+// matching user or group are rejected.
+type UnixPolicy struct {
+	lock   sync.RWMutex
+	users  map[uint32]unixRule
+	groups map[uint32]unixRule
+}
+
+func NewUnixPolicy(config *store.UnixSocketConfig) (*UnixPolicy, error) {
+	policy := &UnixPolicy{
+		users:  make(map[uint32]unixRule),
+		groups: make(map[uint32]unixRule),
+	}
+
+	for name, peerConfig := range config.Users {
+		uid, err := lookupId(name, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+
+			return u.Uid, nil
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid unix socket user %s: %v", name, err)
+		}
+
+		policy.users[uid] = unixRule{name: "user " + name, config: normalizePeerConfig(peerConfig)}
+	}
+
+	for name, peerConfig := range config.Groups {
+		gid, err := lookupId(name, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+
+			return g.Gid, nil
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid unix socket group %s: %v", name, err)
+		}
+
+		policy.groups[gid] = unixRule{name: "group " + name, config: normalizePeerConfig(peerConfig)}
+	}
+
+	return policy, nil
+}
+
+// Update replaces the rules with the ones of the configuration, the current
+// rules are kept if the configuration is invalid.
+func (p *UnixPolicy) Update(config *store.UnixSocketConfig) error {
+	fresh, err := NewUnixPolicy(config)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.users = fresh.users
+	p.groups = fresh.groups
+
+	return nil
+}
+
+// Resolve looks up the settings for the peer, a rule for the user takes
+// precedence over one for the group.
+func (p *UnixPolicy) Resolve(identity PeerIdentity) (*UnixPeer, Decision) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	rule, ok := p.users[identity.Uid]
+	if !ok {
+		rule, ok = p.groups[identity.Gid]
+	}
+
+	if !ok {
+		return nil, Decision{Allowed: false, Rule: "unknown unix peer"}
+	}
+
+	return &UnixPeer{
+		PeerIdentity: identity,
+		Rule:         rule.name,
+		ClientId:     rule.config.ClientId,
+		Items:        rule.config.Items,
+		AllowAdmin:   rule.config.AllowAdmin,
+	}, Decision{Allowed: true, Rule: rule.name}
+}
+
+func lookupId(name string, lookup func(string) (string, error)) (uint32, error) {
+	rawId := name
+
+	if _, err := strconv.ParseUint(name, 10, 32); err != nil {
+		if rawId, err = lookup(name); err != nil {
+			return 0, err
+		}
+	}
+
+	id, err := strconv.ParseUint(rawId, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(id), nil
+}
+
+func normalizePeerConfig(config *store.UnixPeerConfig) *store.UnixPeerConfig {
+	if config == nil {
+		return &store.UnixPeerConfig{}
+	}
+
+	normalized := *config
+	normalized.ClientId = strings.ToLower(strings.TrimSpace(config.ClientId))
+	normalized.Items = nil
+
+	for _, item := range config.Items {
+		normalized.Items = append(normalized.Items, strings.ToLower(strings.TrimSpace(item)))
+	}
+
+	return &normalized
+}
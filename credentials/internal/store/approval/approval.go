@@ -0,0 +1,273 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package approval
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRequestTtl  = time.Hour
+	DefaultFetchWindow = 5 * time.Minute
+)
+
+type Status int
+
+const (
+	StatusPending Status = iota
+	StatusApproved
+	StatusDenied
+	StatusConsumed
+	StatusExpired
+)
+
+type EventType string
+
+const (
+	EventRequested EventType = "requested"
+	EventApproved  EventType = "approved"
+	EventDenied    EventType = "denied"
+	EventConsumed  EventType = "consumed"
+	EventExpired   EventType = "expired"
+)
+
+type Options struct {
+	// how long a request stays pending before it expires
+	RequestTtl time.Duration
+	// how long an approved value may be fetched after the approval
+	FetchWindow time.Duration
+}
+
+type Request struct {
+	Id        uuid.UUID
+	ItemId    uuid.UUID
+	Requester string
+	Status    Status
+	CreatedAt time.Time
+	// end of the pending period, or of the fetch window once approved
+	ExpiresAt time.Time
+	DecidedBy string
+	DecidedAt time.Time
+}
+
+type Event struct {
+	Type    EventType
+	Request Request
+	At      time.Time
+}
+
+// Registry keeps track of requests to read items which require the approval
+// of a second admin. Requests only live in memory, so they don't survive a
+// restart of the store.
+type Registry struct {
+	lock      sync.Mutex
+	options   Options
+	requests  map[uuid.UUID]*Request
+	listeners []func(Event)
+}
+
+func NewRegistry(options Options) *Registry {
+	if options.RequestTtl <= 0 {
+		options.RequestTtl = DefaultRequestTtl
+	}
+
+	if options.FetchWindow <= 0 {
+		options.FetchWindow = DefaultFetchWindow
+	}
+
+	return &Registry{
+		lock:     sync.Mutex{},
+		options:  options,
+		requests: make(map[uuid.UUID]*Request),
+	}
+}
+
+// SetOptions replaces the options, pending requests keep their expiry.
+func (r *Registry) SetOptions(options Options) {
+	fresh := NewRegistry(options)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.options = fresh.options
+}
+
+// OnEvent registers a listener which is called for every change of a
+// request. Listeners are called synchronously, outside the registry's lock.
+func (r *Registry) OnEvent(listener func(Event)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.listeners = append(r.listeners, listener)
+}
+
+// Redeem looks for an approved request of the requester for the item and
+// consumes it, in which case true is returned. Otherwise, the pending
+// request is returned, which is created if it doesn't exist yet.
+func (r *Registry) Redeem(itemId uuid.UUID, requester string) (*Request, bool) {
+	r.lock.Lock()
+
+	now := time.Now()
+	events := r.expireUnsafe(now)
+
+	var result *Request
+	redeemed := false
+
+	for _, request := range r.requests {
+		if request.ItemId != itemId || request.Requester != requester {
+			continue
+		}
+
+		if request.Status == StatusApproved {
+			request.Status = StatusConsumed
+			events = append(events, Event{Type: EventConsumed, Request: *request, At: now})
+
+			result = request
+			redeemed = true
+			break
+		} else if request.Status == StatusPending {
+			result = request
+		}
+	}
+
+	if result == nil {
+		result = &Request{
+			Id:        uuid.New(),
+			ItemId:    itemId,
+			Requester: requester,
+			Status:    StatusPending,
+			CreatedAt: now,
+			ExpiresAt: now.Add(r.options.RequestTtl),
+		}
+
+		r.requests[result.Id] = result
+		events = append(events, Event{Type: EventRequested, Request: *result, At: now})
+	}
+
+	copied := *result
+	r.unlockAndEmit(events)
+
+	return &copied, redeemed
+}
+
+// Decide approves or denies a pending request. The approver can't be the
+// one who made the request.
+func (r *Registry) Decide(id uuid.UUID, approver string, approve bool) (*Request, error) {
+	r.lock.Lock()
+
+	now := time.Now()
+	events := r.expireUnsafe(now)
+
+	request, ok := r.requests[id]
+	if !ok {
+		r.unlockAndEmit(events)
+		return nil, errors.New("approval request not found")
+	}
+
+	if request.Status != StatusPending {
+		r.unlockAndEmit(events)
+		return nil, errors.New("approval request is not pending")
+	}
+
+	if request.Requester == approver {
+		r.unlockAndEmit(events)
+		return nil, errors.New("approval requires a second admin")
+	}
+
+	request.DecidedBy = approver
+	request.DecidedAt = now
+
+	if approve {
+		request.Status = StatusApproved
+		request.ExpiresAt = now.Add(r.options.FetchWindow)
+		events = append(events, Event{Type: EventApproved, Request: *request, At: now})
+	} else {
+		request.Status = StatusDenied
+		events = append(events, Event{Type: EventDenied, Request: *request, At: now})
+	}
+
+	copied := *request
+	r.unlockAndEmit(events)
+
+	return &copied, nil
+}
+
+// Requests returns the pending requests, and also the recently decided ones
+// if includeDecided is set.
+func (r *Registry) Requests(includeDecided bool) []Request {
+	r.lock.Lock()
+
+	events := r.expireUnsafe(time.Now())
+
+	var result []Request
+	for _, request := range r.requests {
+		if includeDecided || request.Status == StatusPending {
+			result = append(result, *request)
+		}
+	}
+
+	r.unlockAndEmit(events)
+
+	slices.SortFunc(result, func(a, b Request) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return result
+}
+
+// Reset drops all requests, approved ones can't be redeemed afterward.
+func (r *Registry) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.requests = make(map[uuid.UUID]*Request)
+}
+
+func (r *Registry) expireUnsafe(now time.Time) []Event {
+	var events []Event
+
+	for _, request := range r.requests {
+		if (request.Status == StatusPending || request.Status == StatusApproved) && now.After(request.ExpiresAt) {
+			request.Status = StatusExpired
+			events = append(events, Event{Type: EventExpired, Request: *request, At: now})
+		}
+	}
+
+	// decided requests are kept around for a while, so they can be listed
+	maps.DeleteFunc(r.requests, func(_ uuid.UUID, request *Request) bool {
+		return request.Status != StatusPending &&
+			request.Status != StatusApproved &&
+			now.After(request.CreatedAt.Add(2*r.options.RequestTtl))
+	})
+
+	return events
+}
+
+func (r *Registry) unlockAndEmit(events []Event) {
+	listeners := slices.Clone(r.listeners)
+	r.lock.Unlock()
+
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
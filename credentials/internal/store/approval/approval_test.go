@@ -0,0 +1,108 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package approval
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedeem(t *testing.T) {
+	registry := NewRegistry(Options{})
+
+	var events []EventType
+	registry.OnEvent(func(event Event) {
+		events = append(events, event.Type)
+	})
+
+	itemId := uuid.New()
+
+	request, ok := registry.Redeem(itemId, "admin:alice")
+	assert.False(t, ok)
+	assert.Equal(t, StatusPending, request.Status)
+
+	// Asking again doesn't create another request
+	again, ok := registry.Redeem(itemId, "admin:alice")
+	assert.False(t, ok)
+	assert.Equal(t, request.Id, again.Id)
+	assert.Len(t, registry.Requests(false), 1)
+
+	// The requester can't approve their own request
+	_, err := registry.Decide(request.Id, "admin:alice", true)
+	assert.Error(t, err)
+
+	approved, err := registry.Decide(request.Id, "admin:bob", true)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusApproved, approved.Status)
+	assert.Equal(t, "admin:bob", approved.DecidedBy)
+
+	// Approval only applies to the requester
+	_, ok = registry.Redeem(itemId, "admin:carol")
+	assert.False(t, ok)
+
+	redeemed, ok := registry.Redeem(itemId, "admin:alice")
+	assert.True(t, ok)
+	assert.Equal(t, request.Id, redeemed.Id)
+
+	// The value can only be fetched once
+	next, ok := registry.Redeem(itemId, "admin:alice")
+	assert.False(t, ok)
+	assert.NotEqual(t, request.Id, next.Id)
+
+	assert.Equal(
+		t,
+		[]EventType{EventRequested, EventApproved, EventRequested, EventConsumed, EventRequested},
+		events,
+	)
+}
+
+func TestDeny(t *testing.T) {
+	registry := NewRegistry(Options{})
+
+	request, _ := registry.Redeem(uuid.New(), "client:abc")
+
+	denied, err := registry.Decide(request.Id, "admin:bob", false)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusDenied, denied.Status)
+
+	_, err = registry.Decide(request.Id, "admin:bob", true)
+	assert.Error(t, err)
+
+	assert.Empty(t, registry.Requests(false))
+	assert.Len(t, registry.Requests(true), 1)
+}
+
+func TestExpiry(t *testing.T) {
+	registry := NewRegistry(Options{RequestTtl: time.Minute, FetchWindow: time.Millisecond})
+
+	itemId := uuid.New()
+	request, _ := registry.Redeem(itemId, "admin:alice")
+
+	_, err := registry.Decide(request.Id, "admin:bob", true)
+	assert.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := registry.Redeem(itemId, "admin:alice")
+	assert.False(t, ok)
+
+	requests := registry.Requests(true)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, StatusExpired, requests[0].Status)
+}
@@ -0,0 +1,590 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logPath       = ".audit"
+	headPath      = logPath + "/head.json"
+	anchorPath    = logPath + "/anchor"
+	segmentSuffix = ".jsonl"
+	segmentDigits = 20
+
+	// SegmentSize is the maximum number of entries per file
+	SegmentSize = 256
+
+	// OperationRepair records an incomplete entry dropped from the end of the
+	// log, left behind by an interrupted append
+	OperationRepair = "RepairAuditLog"
+)
+
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeDenied  Outcome = "denied"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Entry is a single record in the audit log. Every entry contains the hash of
+// its predecessor, so editing or removing any entry breaks the chain.
+type Entry struct {
+	Seq       uint64    `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Operation string    `json:"operation"`
+	Actor     string    `json:"actor,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	ItemIds   []string  `json:"item_ids,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	Outcome   Outcome   `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash,omitempty"`
+}
+
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// head anchors the end of the chain, so truncating the log can be detected.
+// While the vault is unlocked it's additionally sealed as the anchor, which
+// can't be recomputed without the vault.
+type head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+type Filter struct {
+	Since     time.Time
+	Until     time.Time
+	Operation string
+	Actor     string
+	ItemId    string
+	Outcomes  []Outcome
+	// Limit restricts the result to the most recent entries, 0 returns all
+	Limit int
+}
+
+func (f Filter) matches(entry Entry) bool {
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && !entry.Timestamp.Before(f.Until) {
+		return false
+	}
+
+	if f.Operation != "" && !strings.EqualFold(f.Operation, entry.Operation) {
+		return false
+	}
+
+	if f.Actor != "" && f.Actor != entry.Actor && !strings.HasSuffix(entry.Actor, ":"+f.Actor) {
+		return false
+	}
+
+	if f.ItemId != "" && !slices.Contains(entry.ItemIds, f.ItemId) {
+		return false
+	}
+
+	if len(f.Outcomes) > 0 && !slices.Contains(f.Outcomes, entry.Outcome) {
+		return false
+	}
+
+	return true
+}
+
+type Verification struct {
+	Valid bool
+	// Entries is the number of entries checked
+	Entries  uint64
+	HeadSeq  uint64
+	HeadHash string
+	// AnchoredSeq is the last entry covered by the sealed anchor, the entries
+	// after it were appended while the vault was locked and are only chained
+	AnchoredSeq uint64
+	// InvalidSeq is the sequence number at which verification failed
+	InvalidSeq uint64
+	Problem    string
+	// Gaps are the entries recording an incomplete entry which was dropped,
+	// the chain is intact but the dropped entry is lost
+	Gaps []uint64
+}
+
+// Sealer authenticates the anchor of the log, implemented by the vault
+type Sealer interface {
+	IsLocked() bool
+	ReadSealedFile(path string) ([]byte, bool, error)
+	WriteSealedFile(path string, content []byte) error
+}
+
+// Log is an append-only, hash-chained record of all operations on the store,
+// persisted through the vault backend independently of the lock state.
+type Log struct {
+	lock    sync.Mutex
+	backend vault.Backend
+	sealer  Sealer
+	head    head
+	// an append failed and may have left an incomplete entry behind
+	torn bool
+	now  func() time.Time
+}
+
+// Open continues the log in the backend, without a sealer the log isn't
+// anchored and only the hash chain is verified.
+func Open(backend vault.Backend, sealer Sealer) (*Log, error) {
+	l := &Log{
+		backend: backend,
+		sealer:  sealer,
+		now:     time.Now,
+	}
+
+	h, err := readHead(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	segments, err := listSegments(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	l.head = h
+
+	// continue after the head even if entries are missing at the end, so
+	// the gap stays visible to the verifier
+	if len(segments) > 0 {
+		segment, err := readSegment(backend, segments[len(segments)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(segment) > 0 {
+			last := segment[len(segment)-1]
+			if last.Seq > l.head.Seq {
+				l.head = head{Seq: last.Seq, Hash: last.Hash}
+			}
+		}
+	}
+
+	if err = l.repairUnsafe(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Append completes the entry with its sequence number, timestamp and hashes
+// and persists it.
+func (l *Log) Append(entry Entry) (Entry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.torn {
+		if err := l.repairUnsafe(); err != nil {
+			return Entry{}, err
+		}
+
+		l.torn = false
+	}
+
+	return l.appendUnsafe(entry)
+}
+
+func (l *Log) appendUnsafe(entry Entry) (Entry, error) {
+	entry.Seq = l.head.Seq + 1
+	entry.Timestamp = l.now().UTC()
+	entry.PrevHash = l.head.Hash
+
+	hash, err := entry.computeHash()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry.Hash = hash
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	// entries are only ever appended, the existing ones aren't rewritten
+	if err = l.backend.AppendFile(segmentPath(segmentStart(entry.Seq)), append(data, '\n')); err != nil {
+		l.torn = true
+		return Entry{}, err
+	}
+
+	newHead := head{Seq: entry.Seq, Hash: entry.Hash}
+	if err = writeHead(l.backend, newHead); err != nil {
+		return Entry{}, err
+	}
+
+	l.head = newHead
+
+	if err = l.anchorUnsafe(); err != nil {
+		return Entry{}, err
+	}
+
+	return entry, nil
+}
+
+// repairUnsafe drops an incomplete entry from the end of the last segment, so
+// the next entry doesn't continue it, and records the loss in the log
+func (l *Log) repairUnsafe() error {
+	segments, err := listSegments(l.backend)
+	if err != nil || len(segments) == 0 {
+		return err
+	}
+
+	last := segments[len(segments)-1]
+
+	data, err := l.backend.ReadFile(last)
+	if err != nil {
+		return err
+	}
+
+	_, tail, err := parseSegment(data, last)
+	if err != nil || len(tail) == 0 {
+		return err
+	}
+
+	if err = l.backend.WriteFile(last, data[:len(data)-len(tail)]); err != nil {
+		return err
+	}
+
+	_, err = l.appendUnsafe(Entry{
+		Operation: OperationRepair,
+		Outcome:   OutcomeFailure,
+		Error:     fmt.Sprintf("dropped an incomplete entry of %d bytes from the end of %s", len(tail), last),
+	})
+
+	return err
+}
+
+// anchorUnsafe seals the head, which is only possible while the vault is
+// unlocked. The entries appended in the meantime are anchored with the next
+// entry after unlocking.
+func (l *Log) anchorUnsafe() error {
+	if l.sealer == nil || l.sealer.IsLocked() {
+		return nil
+	}
+
+	data, err := json.Marshal(l.head)
+	if err != nil {
+		return err
+	}
+
+	err = l.sealer.WriteSealedFile(anchorPath, data)
+	if err != nil && l.sealer.IsLocked() {
+		// locked concurrently
+		return nil
+	}
+
+	return err
+}
+
+// Query returns all entries matching the filter in chronological order.
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var result []Entry
+	err := l.forEachUnsafe(func(entry Entry) error {
+		if filter.matches(entry) {
+			result = append(result, entry)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+
+	return result, nil
+}
+
+// Verify recomputes the hash chain across all segments and compares its end
+// with the persisted head, which detects edited, removed and truncated
+// entries.
+func (l *Log) Verify() (Verification, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	h, err := readHead(l.backend)
+	if err != nil {
+		return Verification{}, err
+	}
+
+	result := Verification{HeadSeq: h.Seq, HeadHash: h.Hash}
+
+	anchor, err := l.readAnchorUnsafe(h)
+	if err != nil {
+		result.Problem = err.Error()
+		return result, nil
+	}
+
+	result.AnchoredSeq = anchor.Seq
+
+	var expectedSeq uint64 = 1
+	prevHash := ""
+
+	errInvalid := errors.New("invalid")
+	fail := func(seq uint64, problem string) error {
+		result.InvalidSeq = seq
+		result.Problem = problem
+
+		return errInvalid
+	}
+
+	err = l.forEachUnsafe(func(entry Entry) error {
+		if entry.Seq != expectedSeq {
+			return fail(expectedSeq, fmt.Sprintf("expected entry %d, found %d", expectedSeq, entry.Seq))
+		}
+
+		if entry.PrevHash != prevHash {
+			return fail(entry.Seq, "previous hash doesn't match")
+		}
+
+		hash, err := entry.computeHash()
+		if err != nil {
+			return err
+		}
+
+		if hash != entry.Hash {
+			return fail(entry.Seq, "entry hash doesn't match its content")
+		}
+
+		if entry.Seq == anchor.Seq && entry.Hash != anchor.Hash {
+			return fail(entry.Seq, "entry hash doesn't match the sealed anchor")
+		}
+
+		if entry.Operation == OperationRepair {
+			result.Gaps = append(result.Gaps, entry.Seq)
+		}
+
+		result.Entries++
+		expectedSeq++
+		prevHash = entry.Hash
+
+		return nil
+	})
+
+	if errors.Is(err, errInvalid) {
+		return result, nil
+	} else if err != nil {
+		return Verification{}, err
+	}
+
+	lastSeq := expectedSeq - 1
+	if lastSeq < anchor.Seq {
+		result.InvalidSeq = lastSeq + 1
+		result.Problem = fmt.Sprintf("log is truncated, the anchor is at %d but the last entry is %d", anchor.Seq, lastSeq)
+
+		return result, nil
+	}
+
+	if lastSeq < h.Seq {
+		result.InvalidSeq = lastSeq + 1
+		result.Problem = fmt.Sprintf("log is truncated, head is at %d but the last entry is %d", h.Seq, lastSeq)
+
+		return result, nil
+	}
+
+	if lastSeq > h.Seq || (h.Seq > 0 && prevHash != h.Hash) {
+		result.InvalidSeq = h.Seq
+		result.Problem = "head doesn't match the last entry"
+
+		return result, nil
+	}
+
+	result.Valid = true
+
+	return result, nil
+}
+
+// readAnchorUnsafe returns the authenticated anchor, an empty one if the log
+// isn't anchored
+func (l *Log) readAnchorUnsafe(h head) (head, error) {
+	if l.sealer == nil {
+		return head{}, nil
+	}
+
+	data, authenticated, err := l.sealer.ReadSealedFile(anchorPath)
+	if err != nil {
+		return head{}, err
+	} else if data == nil {
+		// every unlock is logged and anchored, so only a log that never saw
+		// the vault unlocked may lack the anchor
+		if h.Seq > 0 && !l.sealer.IsLocked() {
+			return head{}, errors.New("the log isn't anchored")
+		}
+
+		return head{}, nil
+	} else if !authenticated {
+		return head{}, errors.New("the anchor can't be authenticated while the vault is locked")
+	}
+
+	var anchor head
+	if err = json.Unmarshal(data, &anchor); err != nil {
+		return head{}, fmt.Errorf("invalid audit log anchor: %v", err)
+	}
+
+	return anchor, nil
+}
+
+func (l *Log) forEachUnsafe(f func(Entry) error) error {
+	segments, err := listSegments(l.backend)
+	if err != nil {
+		return err
+	}
+
+	for _, segmentPath := range segments {
+		entries, err := readSegment(l.backend, segmentPath)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err = f(entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// segmentStart returns the sequence number of the first entry in the segment
+// containing the entry
+func segmentStart(seq uint64) uint64 {
+	return (seq-1)/SegmentSize*SegmentSize + 1
+}
+
+func segmentPath(firstSeq uint64) string {
+	name := strconv.FormatUint(firstSeq, 10)
+	name = strings.Repeat("0", segmentDigits-len(name)) + name
+
+	return path.Join(logPath, name+segmentSuffix)
+}
+
+func listSegments(backend vault.Backend) ([]string, error) {
+	files, err := backend.ListFiles(logPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []string
+	for _, file := range files {
+		if strings.HasSuffix(file, segmentSuffix) {
+			segments = append(segments, file)
+		}
+	}
+
+	// zero padded names sort in sequence order
+	slices.Sort(segments)
+
+	return segments, nil
+}
+
+// readSegment returns the complete entries of the segment, an incomplete
+// entry at its end is skipped until the log is repaired
+func readSegment(backend vault.Backend, path string) ([]Entry, error) {
+	data, err := backend.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, _, err := parseSegment(data, path)
+
+	return entries, err
+}
+
+// parseSegment parses the newline terminated entries and returns the bytes
+// after the last newline as the tail, which an interrupted append leaves
+// behind. Any other invalid entry fails.
+func parseSegment(data []byte, path string) ([]Entry, []byte, error) {
+	complete := data[:bytes.LastIndexByte(data, '\n')+1]
+	tail := data[len(complete):]
+
+	var entries []Entry
+	for _, line := range bytes.Split(complete, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, nil, fmt.Errorf("invalid audit log entry in %s: %v", path, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, tail, nil
+}
+
+func readHead(backend vault.Backend) (head, error) {
+	data, err := backend.ReadFile(headPath)
+	if err != nil {
+		return head{}, err
+	} else if data == nil {
+		return head{}, nil
+	}
+
+	var h head
+	if err = json.Unmarshal(data, &h); err != nil {
+		return head{}, fmt.Errorf("invalid audit log head: %v", err)
+	}
+
+	return h, nil
+}
+
+func writeHead(backend vault.Backend, h head) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	return backend.WriteFile(headPath, data)
+}
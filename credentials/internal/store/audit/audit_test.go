@@ -0,0 +1,279 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLog(t *testing.T, entries int) (*Log, vault.Backend) {
+	backend := vault.NewLocalStorageBackend(t.TempDir())
+	assert.NoError(t, backend.Init())
+
+	l, err := Open(backend, nil)
+	assert.NoError(t, err)
+
+	for i := 0; i < entries; i++ {
+		_, err = l.Append(Entry{Operation: "ReadVaultItem", Actor: "admin:root", ItemIds: []string{"item"}, Outcome: OutcomeSuccess})
+		assert.NoError(t, err)
+	}
+
+	return l, backend
+}
+
+func TestLog_AppendAndQuery(t *testing.T) {
+	l, backend := newTestLog(t, SegmentSize+10)
+
+	_, err := l.Append(Entry{Operation: "UnlockVault", Actor: "admin:intern", Outcome: OutcomeDenied})
+	assert.NoError(t, err)
+
+	segments, err := listSegments(backend)
+	assert.NoError(t, err)
+	assert.Len(t, segments, 2)
+
+	entries, err := l.Query(Filter{Outcomes: []Outcome{OutcomeDenied}})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, uint64(SegmentSize+11), entries[0].Seq)
+
+	entries, err = l.Query(Filter{Actor: "root", Limit: 5})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 5)
+	assert.Equal(t, uint64(SegmentSize+10), entries[4].Seq)
+
+	// Reopening continues the chain
+	reopened, err := Open(backend, nil)
+	assert.NoError(t, err)
+
+	entry, err := reopened.Append(Entry{Operation: "LockVault", Outcome: OutcomeSuccess})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(SegmentSize+12), entry.Seq)
+
+	verification, err := reopened.Verify()
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Problem)
+	assert.Equal(t, uint64(SegmentSize+12), verification.Entries)
+}
+
+func TestLog_VerifyDetectsEdit(t *testing.T) {
+	l, backend := newTestLog(t, 5)
+
+	path := segmentPath(1)
+	data, err := backend.ReadFile(path)
+	assert.NoError(t, err)
+
+	data = []byte(strings.Replace(string(data), `"actor":"admin:root"`, `"actor":"admin:other"`, 1))
+	assert.NoError(t, backend.WriteFile(path, data))
+
+	verification, err := l.Verify()
+	assert.NoError(t, err)
+	assert.False(t, verification.Valid)
+	assert.Equal(t, uint64(1), verification.InvalidSeq)
+}
+
+func TestLog_VerifyDetectsTruncation(t *testing.T) {
+	l, backend := newTestLog(t, 5)
+
+	data, err := backend.ReadFile(segmentPath(1))
+	assert.NoError(t, err)
+
+	lines := strings.SplitAfter(string(data), "\n")
+	assert.NoError(t, backend.WriteFile(segmentPath(1), []byte(strings.Join(lines[:3], ""))))
+
+	verification, err := l.Verify()
+	assert.NoError(t, err)
+	assert.False(t, verification.Valid)
+	assert.Equal(t, uint64(4), verification.InvalidSeq)
+
+	// Appending after the truncation doesn't hide the gap
+	reopened, err := Open(backend, nil)
+	assert.NoError(t, err)
+
+	entry, err := reopened.Append(Entry{Operation: "LockVault", Outcome: OutcomeSuccess})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), entry.Seq)
+
+	verification, err = reopened.Verify()
+	assert.NoError(t, err)
+	assert.False(t, verification.Valid)
+	assert.Equal(t, uint64(4), verification.InvalidSeq)
+}
+
+func TestLog_TornAppend(t *testing.T) {
+	_, backend := newTestLog(t, 5)
+
+	data, err := json.Marshal(Entry{Seq: 6, Operation: "ReadVaultItem", Outcome: OutcomeSuccess})
+	require.NoError(t, err)
+	require.NoError(t, backend.AppendFile(segmentPath(1), data[:len(data)/2]))
+
+	// Reopening drops the incomplete entry and records the gap
+	reopened, err := Open(backend, nil)
+	require.NoError(t, err)
+
+	entries, err := reopened.Query(Filter{Operation: OperationRepair})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, uint64(6), entries[0].Seq)
+
+	entry, err := reopened.Append(Entry{Operation: "LockVault", Outcome: OutcomeSuccess})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), entry.Seq)
+
+	verification, err := reopened.Verify()
+	require.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Problem)
+	assert.Equal(t, []uint64{6}, verification.Gaps)
+}
+
+// tearingBackend writes only half of the next appended data
+type tearingBackend struct {
+	vault.Backend
+	tear bool
+}
+
+func (b *tearingBackend) AppendFile(path string, data []byte) error {
+	if b.tear {
+		b.tear = false
+		_ = b.Backend.AppendFile(path, data[:len(data)/2])
+
+		return errors.New("no space left on device")
+	}
+
+	return b.Backend.AppendFile(path, data)
+}
+
+func TestLog_TornAppendWhileOpen(t *testing.T) {
+	_, backend := newTestLog(t, 5)
+
+	tearing := &tearingBackend{Backend: backend}
+
+	l, err := Open(tearing, nil)
+	require.NoError(t, err)
+
+	tearing.tear = true
+	_, err = l.Append(Entry{Operation: "ReadVaultItem", Outcome: OutcomeSuccess})
+	assert.Error(t, err)
+
+	// The next entry doesn't continue the incomplete one
+	entry, err := l.Append(Entry{Operation: "LockVault", Outcome: OutcomeSuccess})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), entry.Seq)
+
+	verification, err := l.Verify()
+	require.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Problem)
+	assert.Equal(t, []uint64{6}, verification.Gaps)
+}
+
+func TestOpen_CorruptEntry(t *testing.T) {
+	_, backend := newTestLog(t, 5)
+
+	data, err := backend.ReadFile(segmentPath(1))
+	require.NoError(t, err)
+
+	lines := strings.SplitAfter(string(data), "\n")
+	lines[2] = lines[2][:len(lines[2])/2] + "\n"
+	require.NoError(t, backend.WriteFile(segmentPath(1), []byte(strings.Join(lines, ""))))
+
+	_, err = Open(backend, nil)
+	assert.Error(t, err)
+}
+
+// rewriteChain edits an entry and recomputes the chain and the head, like
+// anyone with write access to the storage could
+func rewriteChain(t *testing.T, backend vault.Backend, seq uint64) {
+	entries, err := readSegment(backend, segmentPath(1))
+	require.NoError(t, err)
+
+	entries[seq-1].Actor = "admin:other"
+
+	var data []byte
+	prevHash := ""
+	for i := range entries {
+		entries[i].PrevHash = prevHash
+		entries[i].Hash, err = entries[i].computeHash()
+		require.NoError(t, err)
+
+		line, err := json.Marshal(entries[i])
+		require.NoError(t, err)
+
+		data = append(append(data, line...), '\n')
+		prevHash = entries[i].Hash
+	}
+
+	require.NoError(t, backend.WriteFile(segmentPath(1), data))
+	require.NoError(t, writeHead(backend, head{Seq: entries[len(entries)-1].Seq, Hash: prevHash}))
+}
+
+//goland:noinspection GoRedundantConversion
+func TestLog_Anchor(t *testing.T) {
+	v, err := vault.NewVault(&vault.Options{Backend: vault.NewLocalStorageBackend(t.TempDir())})
+	require.NoError(t, err)
+
+	_, err = v.Initialize(string([]byte("correct_passphrase")), nil)
+	require.NoError(t, err)
+
+	l, err := Open(v.Options().Backend, v)
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err = l.Append(Entry{Operation: "ReadVaultItem", Actor: "admin:root", Outcome: OutcomeSuccess})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, v.Lock())
+
+	// the failed unlocks of an attacker are logged without the vault
+	for range 2 {
+		_, err = l.Append(Entry{Operation: "UnlockVault", Outcome: OutcomeDenied})
+		require.NoError(t, err)
+	}
+
+	verification, err := l.Verify()
+	require.NoError(t, err)
+	assert.False(t, verification.Valid)
+
+	require.NoError(t, v.Unlock(string([]byte("correct_passphrase"))))
+
+	verification, err = l.Verify()
+	require.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Problem)
+	assert.Equal(t, uint64(3), verification.AnchoredSeq)
+	assert.Equal(t, uint64(5), verification.Entries)
+
+	// a consistently rewritten chain doesn't match the anchor
+	rewriteChain(t, v.Options().Backend, 2)
+
+	verification, err = l.Verify()
+	require.NoError(t, err)
+	assert.False(t, verification.Valid)
+	assert.Equal(t, uint64(3), verification.InvalidSeq)
+
+	// neither does a missing one
+	_, err = v.Options().Backend.DeleteFile(anchorPath)
+	require.NoError(t, err)
+
+	verification, err = l.Verify()
+	require.NoError(t, err)
+	assert.False(t, verification.Valid)
+}
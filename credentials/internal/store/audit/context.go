@@ -0,0 +1,95 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+type recordKey struct{}
+
+// Record collects the details of a single call which are only known to the
+// service handling it, to be completed into an Entry once the call finished.
+type Record struct {
+	lock    sync.Mutex
+	actor   string
+	itemIds []string
+	target  string
+}
+
+func NewContext(ctx context.Context) (context.Context, *Record) {
+	record := &Record{}
+
+	return context.WithValue(ctx, recordKey{}, record), record
+}
+
+func fromContext(ctx context.Context) *Record {
+	record, _ := ctx.Value(recordKey{}).(*Record)
+
+	return record
+}
+
+// SetActor records the admin or client performing the call, for failed
+// authentication this is the identity that was claimed.
+func SetActor(ctx context.Context, actor string) {
+	if record := fromContext(ctx); record != nil {
+		record.lock.Lock()
+		defer record.lock.Unlock()
+
+		record.actor = actor
+	}
+}
+
+func AddItems(ctx context.Context, itemIds ...string) {
+	if record := fromContext(ctx); record != nil {
+		record.lock.Lock()
+		defer record.lock.Unlock()
+
+		record.itemIds = append(record.itemIds, itemIds...)
+	}
+}
+
+// SetTarget records the admin, client or request the call operated on.
+func SetTarget(ctx context.Context, target string) {
+	if record := fromContext(ctx); record != nil {
+		record.lock.Lock()
+		defer record.lock.Unlock()
+
+		record.target = target
+	}
+}
+
+// Entry creates the entry for the recorded call, without any chain data.
+func (r *Record) Entry(operation string, source string, outcome Outcome, err error) Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry := Entry{
+		Operation: operation,
+		Actor:     r.actor,
+		Source:    source,
+		ItemIds:   append([]string(nil), r.itemIds...),
+		Target:    r.target,
+		Outcome:   outcome,
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
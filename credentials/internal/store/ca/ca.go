@@ -0,0 +1,702 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/awnumar/memguard"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"maps"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	authorityPath   = ".ca/authority.json"
+	keyPath         = ".ca/key.age"
+	enrollmentsPath = ".ca/enrollments.json"
+	tokensPath      = ".ca/tokens.json"
+
+	ClientCertificateValidity = 90 * 24 * time.Hour
+	pendingEnrollmentTtl      = 7 * 24 * time.Hour
+)
+
+var ErrInvalidToken = errors.New("invalid enrollment token")
+
+type EnrollmentStatus int
+
+const (
+	EnrollmentPending EnrollmentStatus = iota
+	EnrollmentApproved
+	EnrollmentDenied
+	EnrollmentRevoked
+)
+
+type Enrollment struct {
+	Id          uuid.UUID        `json:"id"`
+	CommonName  string           `json:"common_name"`
+	Status      EnrollmentStatus `json:"status"`
+	Request     string           `json:"request,omitempty"`
+	Certificate string           `json:"certificate,omitempty"`
+	Serial      string           `json:"serial,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	ExpiresAt   time.Time        `json:"expires_at"`
+}
+
+type authorityState struct {
+	KeyChecksum string `json:"key_checksum"`
+	Certificate string `json:"certificate"`
+}
+
+type enrollmentToken struct {
+	CommonName string    `json:"common_name"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Authority is a minimal certificate authority which issues client
+// certificates for mutual TLS. The private key of the authority is encrypted
+// by the vault outside its items, so signing requires an unlocked vault,
+// while verifying client certificates does not. The state files are sealed
+// by the vault, until it's unlocked they're trusted without being
+// authenticated.
+type Authority struct {
+	lock          sync.RWMutex
+	vault         *vault.Vault
+	authenticated bool
+	state         *authorityState
+	certificate   *x509.Certificate
+	roots         *x509.CertPool
+	enrollments   map[uuid.UUID]*Enrollment
+	tokens        map[string]enrollmentToken
+	revoked       map[string]struct{}
+}
+
+func NewAuthority(v *vault.Vault) (*Authority, error) {
+	authority := &Authority{
+		lock:          sync.RWMutex{},
+		vault:         v,
+		authenticated: false,
+		state:         nil,
+		certificate:   nil,
+		roots:         nil,
+		enrollments:   make(map[uuid.UUID]*Enrollment),
+		tokens:        make(map[string]enrollmentToken),
+		revoked:       make(map[string]struct{}),
+	}
+
+	if err := authority.loadUnsafe(); err != nil {
+		return nil, err
+	}
+
+	return authority, nil
+}
+
+// Reload reads the state changed by someone else, e.g. by the replication
+// from another store. Once the vault is unlocked this authenticates the
+// state, if that fails no client certificate is trusted anymore.
+func (a *Authority) Reload() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.resetUnsafe()
+
+	if err := a.loadUnsafe(); err != nil {
+		a.resetUnsafe()
+		return err
+	}
+
+	return nil
+}
+
+func (a *Authority) resetUnsafe() {
+	a.authenticated = false
+	a.state = nil
+	a.certificate = nil
+	a.roots = nil
+	a.enrollments = make(map[uuid.UUID]*Enrollment)
+	a.tokens = make(map[string]enrollmentToken)
+	a.revoked = make(map[string]struct{})
+}
+
+func (a *Authority) loadUnsafe() error {
+	a.authenticated = !a.vault.IsLocked()
+
+	var state authorityState
+	ok, err := a.readJson(authorityPath, &state)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate authority: %v", err)
+	} else if ok {
+		if err = a.applyStateUnsafe(&state); err != nil {
+			return fmt.Errorf("failed to load certificate authority: %v", err)
+		}
+	}
+
+	var enrollments []*Enrollment
+	if _, err = a.readJson(enrollmentsPath, &enrollments); err != nil {
+		return fmt.Errorf("failed to load enrollments: %v", err)
+	}
+
+	for _, enrollment := range enrollments {
+		a.enrollments[enrollment.Id] = enrollment
+		if enrollment.Status == EnrollmentRevoked {
+			a.revoked[enrollment.Serial] = struct{}{}
+		}
+	}
+
+	if _, err = a.readJson(tokensPath, &a.tokens); err != nil {
+		return fmt.Errorf("failed to load enrollment tokens: %v", err)
+	}
+
+	return nil
+}
+
+func (a *Authority) IsInitialized() bool {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return a.certificate != nil
+}
+
+// IsAuthenticated returns whether the state was authenticated by the
+// unlocked vault
+func (a *Authority) IsAuthenticated() bool {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return a.authenticated
+}
+
+func (a *Authority) Certificate() *x509.Certificate {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return a.certificate
+}
+
+func (a *Authority) Init(commonName string, validity time.Duration) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.certificate != nil {
+		return errors.New("certificate authority already initialized")
+	}
+
+	if a.vault.IsLocked() {
+		return errors.New("vault is locked")
+	}
+
+	if commonName == "" {
+		return errors.New("common name is empty")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          newSerial(),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %v", err)
+	}
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %v", err)
+	}
+
+	keyChecksum := sha256.Sum256(keyDer)
+
+	if err = a.vault.WriteSecretFile(keyPath, memguard.NewBufferFromBytes(keyDer)); err != nil {
+		return err
+	}
+
+	state := &authorityState{
+		KeyChecksum: hex.EncodeToString(keyChecksum[:]),
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer})),
+	}
+
+	if err = a.writeJson(authorityPath, state); err != nil {
+		return err
+	}
+
+	a.authenticated = true
+
+	return a.applyStateUnsafe(state)
+}
+
+func (a *Authority) CreateToken(commonName string, ttl time.Duration) (string, time.Time, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.certificate == nil {
+		return "", time.Time{}, errors.New("certificate authority not initialized")
+	}
+
+	a.pruneUnsafe()
+
+	token := rand.Text()
+	expiresAt := time.Now().Add(ttl)
+
+	a.tokens[tokenKey(token)] = enrollmentToken{
+		CommonName: commonName,
+		ExpiresAt:  expiresAt,
+	}
+
+	if err := a.writeJson(tokensPath, a.tokens); err != nil {
+		delete(a.tokens, tokenKey(token))
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// Enroll registers a certificate signing request. If a valid one-time token
+// is supplied the certificate is issued immediately, otherwise the
+// enrollment remains pending until an admin decides on it.
+func (a *Authority) Enroll(csrPem string, token string) (*Enrollment, error) {
+	csr, err := parseCsr(csrPem)
+	if err != nil {
+		return nil, err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.certificate == nil {
+		return nil, errors.New("certificate authority not initialized")
+	}
+
+	a.pruneUnsafe()
+
+	enrollment := &Enrollment{
+		Id:         uuid.New(),
+		CommonName: csr.Subject.CommonName,
+		Status:     EnrollmentPending,
+		Request:    csrPem,
+		CreatedAt:  time.Now(),
+		ExpiresAt:  time.Now().Add(pendingEnrollmentTtl),
+	}
+
+	if token != "" {
+		key := tokenKey(token)
+
+		t, ok := a.tokens[key]
+		if !ok || time.Now().After(t.ExpiresAt) {
+			return nil, ErrInvalidToken
+		}
+
+		if t.CommonName != "" && t.CommonName != csr.Subject.CommonName {
+			return nil, errors.New("enrollment token was issued for a different common name")
+		}
+
+		if err = a.issueUnsafe(enrollment, csr); err != nil {
+			return nil, err
+		}
+
+		delete(a.tokens, key)
+		if err = a.writeJson(tokensPath, a.tokens); err != nil {
+			log.Warn().Err(err).Msg("failed to persist consumed enrollment token")
+		}
+	}
+
+	a.enrollments[enrollment.Id] = enrollment
+	if err = a.persistEnrollmentsUnsafe(); err != nil {
+		delete(a.enrollments, enrollment.Id)
+		return nil, err
+	}
+
+	result := *enrollment
+	return &result, nil
+}
+
+func (a *Authority) Enrollment(id uuid.UUID) (*Enrollment, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	enrollment, ok := a.enrollments[id]
+	if !ok {
+		return nil, errors.New("enrollment not found")
+	}
+
+	result := *enrollment
+	return &result, nil
+}
+
+func (a *Authority) Enrollments(includeIssued bool) []Enrollment {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	var result []Enrollment
+	for _, enrollment := range a.enrollments {
+		if includeIssued || enrollment.Status == EnrollmentPending {
+			result = append(result, *enrollment)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b Enrollment) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return result
+}
+
+func (a *Authority) Decide(id uuid.UUID, approve bool) (*Enrollment, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	enrollment, ok := a.enrollments[id]
+	if !ok {
+		return nil, errors.New("enrollment not found")
+	}
+
+	if enrollment.Status != EnrollmentPending {
+		return nil, errors.New("enrollment is not pending")
+	}
+
+	updated := *enrollment
+	if approve {
+		csr, err := parseCsr(enrollment.Request)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = a.issueUnsafe(&updated, csr); err != nil {
+			return nil, err
+		}
+	} else {
+		updated.Status = EnrollmentDenied
+		updated.Request = ""
+	}
+
+	a.enrollments[id] = &updated
+	if err := a.persistEnrollmentsUnsafe(); err != nil {
+		a.enrollments[id] = enrollment
+		return nil, err
+	}
+
+	result := updated
+	return &result, nil
+}
+
+func (a *Authority) Revoke(serial string) (*Enrollment, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for id, enrollment := range a.enrollments {
+		if enrollment.Serial != serial || enrollment.Status != EnrollmentApproved {
+			continue
+		}
+
+		updated := *enrollment
+		updated.Status = EnrollmentRevoked
+
+		a.enrollments[id] = &updated
+		a.revoked[serial] = struct{}{}
+
+		if err := a.persistEnrollmentsUnsafe(); err != nil {
+			a.enrollments[id] = enrollment
+			delete(a.revoked, serial)
+			return nil, err
+		}
+
+		result := updated
+		return &result, nil
+	}
+
+	return nil, errors.New("no issued certificate with serial: " + serial)
+}
+
+// Renew issues a new certificate for the holder of a still valid client
+// certificate. The common name of the new certificate has to match.
+func (a *Authority) Renew(peer *x509.Certificate, csrPem string) (*Enrollment, error) {
+	if peer == nil {
+		return nil, errors.New("no client certificate presented")
+	}
+
+	csr, err := parseCsr(csrPem)
+	if err != nil {
+		return nil, err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if err = a.verifyUnsafe(peer); err != nil {
+		return nil, err
+	}
+
+	if csr.Subject.CommonName != peer.Subject.CommonName {
+		return nil, errors.New("common name mismatch")
+	}
+
+	enrollment := &Enrollment{
+		Id:         uuid.New(),
+		CommonName: csr.Subject.CommonName,
+		CreatedAt:  time.Now(),
+	}
+
+	if err = a.issueUnsafe(enrollment, csr); err != nil {
+		return nil, err
+	}
+
+	a.enrollments[enrollment.Id] = enrollment
+	if err = a.persistEnrollmentsUnsafe(); err != nil {
+		delete(a.enrollments, enrollment.Id)
+		return nil, err
+	}
+
+	result := *enrollment
+	return &result, nil
+}
+
+func (a *Authority) Verify(cert *x509.Certificate) error {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return a.verifyUnsafe(cert)
+}
+
+// VerifyPeerCertificate is meant to be used as tls.Config.VerifyPeerCertificate
+// in combination with tls.RequestClientCert, so that clients without a
+// certificate can still connect (e.g. to enroll).
+func (a *Authority) VerifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return nil
+	}
+
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+
+	err = a.Verify(cert)
+	if err != nil {
+		log.Warn().Err(err).Str("subject", cert.Subject.CommonName).Msg("rejecting client certificate")
+	}
+
+	return err
+}
+
+func (a *Authority) verifyUnsafe(cert *x509.Certificate) error {
+	if a.roots == nil {
+		return errors.New("certificate authority not initialized")
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:     a.roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := a.revoked[SerialString(cert.SerialNumber)]; ok {
+		return errors.New("certificate has been revoked")
+	}
+
+	return nil
+}
+
+func (a *Authority) issueUnsafe(enrollment *Enrollment, csr *x509.CertificateRequest) error {
+	if a.certificate == nil {
+		return errors.New("certificate authority not initialized")
+	}
+
+	if !a.authenticated {
+		return errors.New("certificate authority isn't authenticated, unlock the vault")
+	}
+
+	keyBuf, err := a.vault.ReadSecretFile(keyPath)
+	if err != nil {
+		return err
+	} else if keyBuf == nil {
+		return errors.New("certificate authority key not found")
+	}
+
+	defer keyBuf.Destroy()
+
+	keyChecksum := sha256.Sum256(keyBuf.Bytes())
+	if hex.EncodeToString(keyChecksum[:]) != a.state.KeyChecksum {
+		return errors.New("certificate authority key checksum mismatch")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(keyBuf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate authority key: %v", err)
+	}
+
+	now := time.Now()
+	notAfter := now.Add(ClientCertificateValidity)
+	if notAfter.After(a.certificate.NotAfter) {
+		notAfter = a.certificate.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: newSerial(),
+		Subject:      pkix.Name{CommonName: csr.Subject.CommonName},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, template, a.certificate, csr.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to sign certificate: %v", err)
+	}
+
+	enrollment.Status = EnrollmentApproved
+	enrollment.Request = ""
+	enrollment.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}))
+	enrollment.Serial = SerialString(template.SerialNumber)
+	enrollment.ExpiresAt = notAfter
+
+	log.Info().
+		Str("subject", enrollment.CommonName).
+		Str("serial", enrollment.Serial).
+		Msg("issued client certificate")
+
+	return nil
+}
+
+func (a *Authority) applyStateUnsafe(state *authorityState) error {
+	block, _ := pem.Decode([]byte(state.Certificate))
+	if block == nil {
+		return errors.New("invalid certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	a.state = state
+	a.certificate = cert
+	a.roots = roots
+
+	return nil
+}
+
+// pruneUnsafe drops expired tokens and stale pending enrollments.
+func (a *Authority) pruneUnsafe() {
+	now := time.Now()
+
+	maps.DeleteFunc(a.tokens, func(_ string, t enrollmentToken) bool {
+		return now.After(t.ExpiresAt)
+	})
+
+	maps.DeleteFunc(a.enrollments, func(_ uuid.UUID, e *Enrollment) bool {
+		return e.Status == EnrollmentPending && now.After(e.ExpiresAt)
+	})
+}
+
+func (a *Authority) persistEnrollmentsUnsafe() error {
+	return a.writeJson(enrollmentsPath, slices.Collect(maps.Values(a.enrollments)))
+}
+
+// readJson reads a sealed state file, which isn't authenticated while the
+// vault is locked
+func (a *Authority) readJson(path string, target any) (bool, error) {
+	data, authenticated, err := a.vault.ReadSealedFile(path)
+	if err != nil {
+		return false, err
+	} else if data == nil {
+		return false, nil
+	}
+
+	a.authenticated = a.authenticated && authenticated
+
+	return true, json.Unmarshal(data, target)
+}
+
+func (a *Authority) writeJson(path string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return a.vault.WriteSealedFile(path, data)
+}
+
+func SerialString(serial *big.Int) string {
+	return serial.Text(16)
+}
+
+func parseCsr(csrPem string) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode([]byte(csrPem))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("invalid certificate signing request")
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+
+	if csr.Subject.CommonName == "" {
+		return nil, errors.New("certificate signing request has no common name")
+	}
+
+	return csr, nil
+}
+
+func newSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return serial
+}
+
+func tokenKey(token string) string {
+	raw := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(raw[:])
+}
@@ -0,0 +1,232 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuthority(t *testing.T) (*vault.Vault, *Authority) {
+	v, err := vault.NewVault(&vault.Options{
+		Backend: vault.NewLocalStorageBackend(t.TempDir()),
+	})
+	assert.NoError(t, err)
+
+	//goland:noinspection GoRedundantConversion
+	_, err = v.Initialize(string([]byte("correct_passphrase")), nil)
+	assert.NoError(t, err)
+
+	authority, err := NewAuthority(v)
+	assert.NoError(t, err)
+
+	err = authority.Init("test CA", time.Hour*24)
+	assert.NoError(t, err)
+
+	return v, authority
+}
+
+func newTestCsr(t *testing.T, commonName string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	csrDer, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}, key)
+	assert.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDer}))
+}
+
+func parseTestCertificate(t *testing.T, certPem string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(certPem))
+	assert.NotNil(t, block)
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err)
+
+	return cert
+}
+
+func TestInit(t *testing.T) {
+	v, authority := newTestAuthority(t)
+
+	assert.True(t, authority.IsInitialized())
+	assert.True(t, authority.Certificate().IsCA)
+
+	// Initializing twice is not allowed
+	err := authority.Init("other CA", time.Hour)
+	assert.Error(t, err)
+
+	// The authority is restored from storage
+	restored, err := NewAuthority(v)
+	assert.NoError(t, err)
+	assert.True(t, restored.IsInitialized())
+	assert.Equal(t, authority.Certificate().Raw, restored.Certificate().Raw)
+}
+
+func TestEnroll_Approval(t *testing.T) {
+	_, authority := newTestAuthority(t)
+
+	enrollment, err := authority.Enroll(newTestCsr(t, "host-a"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, EnrollmentPending, enrollment.Status)
+	assert.Empty(t, enrollment.Certificate)
+
+	assert.Len(t, authority.Enrollments(false), 1)
+
+	enrollment, err = authority.Decide(enrollment.Id, true)
+	assert.NoError(t, err)
+	assert.Equal(t, EnrollmentApproved, enrollment.Status)
+
+	cert := parseTestCertificate(t, enrollment.Certificate)
+	assert.Equal(t, "host-a", cert.Subject.CommonName)
+	assert.NoError(t, authority.Verify(cert))
+
+	// Deciding twice is not allowed
+	_, err = authority.Decide(enrollment.Id, false)
+	assert.Error(t, err)
+
+	assert.Len(t, authority.Enrollments(false), 0)
+	assert.Len(t, authority.Enrollments(true), 1)
+}
+
+func TestEnroll_Token(t *testing.T) {
+	_, authority := newTestAuthority(t)
+
+	token, _, err := authority.CreateToken("host-b", time.Hour)
+	assert.NoError(t, err)
+
+	// Token is bound to a common name
+	_, err = authority.Enroll(newTestCsr(t, "host-c"), token)
+	assert.Error(t, err)
+
+	enrollment, err := authority.Enroll(newTestCsr(t, "host-b"), token)
+	assert.NoError(t, err)
+	assert.Equal(t, EnrollmentApproved, enrollment.Status)
+
+	// Tokens can only be used once
+	_, err = authority.Enroll(newTestCsr(t, "host-b"), token)
+	assert.Error(t, err)
+}
+
+func TestRevoke(t *testing.T) {
+	v, authority := newTestAuthority(t)
+
+	token, _, err := authority.CreateToken("", time.Hour)
+	assert.NoError(t, err)
+
+	enrollment, err := authority.Enroll(newTestCsr(t, "host-d"), token)
+	assert.NoError(t, err)
+
+	cert := parseTestCertificate(t, enrollment.Certificate)
+
+	_, err = authority.Revoke(enrollment.Serial)
+	assert.NoError(t, err)
+	assert.Error(t, authority.Verify(cert))
+
+	// Revocations survive a restart
+	restored, err := NewAuthority(v)
+	assert.NoError(t, err)
+	assert.Error(t, restored.Verify(cert))
+
+	// Revoked certificates cannot be renewed
+	_, err = authority.Renew(cert, newTestCsr(t, "host-d"))
+	assert.Error(t, err)
+}
+
+func TestRenew(t *testing.T) {
+	_, authority := newTestAuthority(t)
+
+	token, _, err := authority.CreateToken("", time.Hour)
+	assert.NoError(t, err)
+
+	enrollment, err := authority.Enroll(newTestCsr(t, "host-e"), token)
+	assert.NoError(t, err)
+
+	cert := parseTestCertificate(t, enrollment.Certificate)
+
+	// Common name has to match
+	_, err = authority.Renew(cert, newTestCsr(t, "host-f"))
+	assert.Error(t, err)
+
+	renewed, err := authority.Renew(cert, newTestCsr(t, "host-e"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, enrollment.Serial, renewed.Serial)
+	assert.NoError(t, authority.Verify(parseTestCertificate(t, renewed.Certificate)))
+}
+
+func TestSigningRequiresUnlockedVault(t *testing.T) {
+	v, authority := newTestAuthority(t)
+
+	enrollment, err := authority.Enroll(newTestCsr(t, "host-g"), "")
+	assert.NoError(t, err)
+
+	err = v.Lock()
+	assert.NoError(t, err)
+
+	_, err = authority.Decide(enrollment.Id, true)
+	assert.Error(t, err)
+
+	enrollment, err = authority.Enrollment(enrollment.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, EnrollmentPending, enrollment.Status)
+}
+
+func TestInit_KeyIsNoItem(t *testing.T) {
+	v, authority := newTestAuthority(t)
+
+	assert.True(t, authority.IsAuthenticated())
+	assert.Empty(t, v.Items())
+}
+
+func TestReload_TamperedState(t *testing.T) {
+	v, authority := newTestAuthority(t)
+
+	enrollment, err := authority.Enroll(newTestCsr(t, "host-h"), "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, v.Lock())
+
+	// Swap in the root of another authority
+	other, _ := newTestAuthority(t)
+	data, err := other.Options().Backend.ReadFile(authorityPath)
+	assert.NoError(t, err)
+	assert.NoError(t, v.Options().Backend.WriteFile(authorityPath, data))
+
+	// Without the vault the state can't be authenticated
+	restored, err := NewAuthority(v)
+	require.NoError(t, err)
+	assert.False(t, restored.IsAuthenticated())
+
+	_, err = restored.Decide(enrollment.Id, true)
+	assert.Error(t, err)
+
+	//goland:noinspection GoRedundantConversion
+	assert.NoError(t, v.Unlock(string([]byte("correct_passphrase"))))
+
+	assert.Error(t, restored.Reload())
+	assert.False(t, restored.IsInitialized())
+	assert.False(t, restored.IsAuthenticated())
+}
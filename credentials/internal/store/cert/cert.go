@@ -17,6 +17,7 @@ package cert
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"github.com/rs/zerolog/log"
 	"os"
 	"os/signal"
@@ -75,6 +76,14 @@ func (reloader *X509KeyPairReloader) reload() error {
 	return nil
 }
 
+// Leaf returns the currently served certificate
+func (reloader *X509KeyPairReloader) Leaf() *x509.Certificate {
+	reloader.lock.RLock()
+	defer reloader.lock.RUnlock()
+
+	return reloader.certificate.Leaf
+}
+
 func (reloader *X509KeyPairReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	reloader.lock.RLock()
 	defer reloader.lock.RUnlock()
@@ -0,0 +1,151 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package challenge
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	NonceSize = 32
+
+	DefaultTtl = time.Minute
+	// MaxOutstanding limits the memory held by challenges nobody answers
+	MaxOutstanding = 4096
+	// MaxPerSource keeps a single source from using up all challenges. There
+	// is no limit per client, as anyone may request challenges for any client
+	// and could lock it out that way.
+	MaxPerSource = 64
+)
+
+var ErrTooManyChallenges = errors.New("too many outstanding challenges")
+
+type Challenge struct {
+	ClientId  string
+	Source    string
+	Nonce     []byte
+	ExpiresAt time.Time
+}
+
+// Registry hands out single-use nonces which clients sign with their private
+// key to prove its possession without ever sending a reusable secret.
+type Registry struct {
+	lock       sync.Mutex
+	ttl        time.Duration
+	challenges map[string]Challenge
+	perSource  map[string]int
+	now        func() time.Time
+}
+
+func NewRegistry(ttl time.Duration) *Registry {
+	if ttl <= 0 {
+		ttl = DefaultTtl
+	}
+
+	return &Registry{
+		ttl:        ttl,
+		challenges: map[string]Challenge{},
+		perSource:  map[string]int{},
+		now:        time.Now,
+	}
+}
+
+// Issue creates a challenge for the client, requested from the source
+func (r *Registry) Issue(clientId string, source string) (Challenge, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.exhaustedUnsafe(source) {
+		r.expireUnsafe()
+
+		if r.exhaustedUnsafe(source) {
+			return Challenge{}, ErrTooManyChallenges
+		}
+	}
+
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return Challenge{}, err
+	}
+
+	challenge := Challenge{
+		ClientId:  clientId,
+		Source:    source,
+		Nonce:     nonce,
+		ExpiresAt: r.now().Add(r.ttl),
+	}
+
+	r.challenges[hex.EncodeToString(nonce)] = challenge
+	r.perSource[source]++
+
+	return challenge, nil
+}
+
+// Redeem consumes the challenge, returns false if it was never issued to the
+// client or already expired.
+func (r *Registry) Redeem(clientId string, nonce []byte) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := hex.EncodeToString(nonce)
+
+	challenge, ok := r.challenges[key]
+	if !ok {
+		return false
+	}
+
+	r.deleteUnsafe(key, challenge)
+
+	if r.now().After(challenge.ExpiresAt) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(challenge.ClientId), []byte(clientId)) == 1
+}
+
+func (r *Registry) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.challenges = map[string]Challenge{}
+	r.perSource = map[string]int{}
+}
+
+func (r *Registry) exhaustedUnsafe(source string) bool {
+	return len(r.challenges) >= MaxOutstanding || r.perSource[source] >= MaxPerSource
+}
+
+func (r *Registry) deleteUnsafe(key string, challenge Challenge) {
+	delete(r.challenges, key)
+
+	if r.perSource[challenge.Source]--; r.perSource[challenge.Source] <= 0 {
+		delete(r.perSource, challenge.Source)
+	}
+}
+
+func (r *Registry) expireUnsafe() {
+	now := r.now()
+	for key, challenge := range r.challenges {
+		if now.After(challenge.ExpiresAt) {
+			r.deleteUnsafe(key, challenge)
+		}
+	}
+}
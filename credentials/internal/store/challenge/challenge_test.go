@@ -0,0 +1,96 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package challenge
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedeem(t *testing.T) {
+	registry := NewRegistry(0)
+
+	challenge, err := registry.Issue("client-a", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Len(t, challenge.Nonce, NonceSize)
+
+	// Only the client the challenge was issued to may redeem it
+	assert.False(t, registry.Redeem("client-b", challenge.Nonce))
+
+	challenge, err = registry.Issue("client-a", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, registry.Redeem("client-a", challenge.Nonce))
+
+	// Challenges can only be used once
+	assert.False(t, registry.Redeem("client-a", challenge.Nonce))
+}
+
+func TestRedeem_Expired(t *testing.T) {
+	now := time.Now()
+
+	registry := NewRegistry(time.Minute)
+	registry.now = func() time.Time { return now }
+
+	challenge, err := registry.Issue("client-a", "10.0.0.1")
+	assert.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	assert.False(t, registry.Redeem("client-a", challenge.Nonce))
+}
+
+func TestIssue_Limits(t *testing.T) {
+	now := time.Now()
+
+	registry := NewRegistry(time.Minute)
+	registry.now = func() time.Time { return now }
+
+	// a single source can't lock the client out
+	for range MaxPerSource {
+		_, err := registry.Issue("client-a", "10.0.0.1")
+		assert.NoError(t, err)
+	}
+
+	_, err := registry.Issue("client-a", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrTooManyChallenges)
+
+	challenge, err := registry.Issue("client-a", "10.0.0.2")
+	assert.NoError(t, err)
+
+	for i := range MaxPerSource - 1 {
+		_, err = registry.Issue(fmt.Sprintf("client-%d", i), "10.0.0.2")
+		assert.NoError(t, err)
+	}
+
+	_, err = registry.Issue("client-b", "10.0.0.2")
+	assert.ErrorIs(t, err, ErrTooManyChallenges)
+
+	// redeeming frees the challenge
+	assert.True(t, registry.Redeem("client-a", challenge.Nonce))
+	assert.Equal(t, MaxPerSource-1, registry.perSource["10.0.0.2"])
+
+	_, err = registry.Issue("client-b", "10.0.0.2")
+	assert.NoError(t, err)
+
+	// as does expiring
+	now = now.Add(2 * time.Minute)
+
+	_, err = registry.Issue("client-a", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Len(t, registry.challenges, 1)
+}
@@ -17,19 +17,189 @@ package store
 
 import (
 	"errors"
+	"fmt"
 	"github.com/pelletier/go-toml/v2"
+	"github.com/vemilyus/borg-collective/credentials/internal/logging"
+	"net"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	StoragePath   string
 	ListenAddress string
 	Tls           *TlsConfig
+	RateLimit     *RateLimitConfig
+	Approval      *ApprovalConfig
+	Access        *AccessConfig
+	UnixSocket    *UnixSocketConfig
+	Metrics       *MetricsConfig
+	Transport     *TransportConfig
+	Gateway       *GatewayConfig
+	Replication   *ReplicationConfig
+	// registers the gRPC server reflection service, e.g. for grpcurl
+	Reflection bool
+	// how long in-flight calls may take to complete when shutting down,
+	// defaults to 10s
+	ShutdownTimeout *Duration
+	// one of trace, debug, info, warn or error, defaults to info in production
+	// mode and debug otherwise
+	LogLevel string
+	Logging  *LoggingConfig
+}
+
+type LoggingConfig struct {
+	// console or json, defaults to console
+	Format string
+	// whether to log to stdout, defaults to true
+	Stdout *bool
+	File   *LogFileConfig
+	// logs to the systemd journal using its native protocol
+	Journald bool
+}
+
+type LogFileConfig struct {
+	Path string
+	// size in MiB after which the file is rotated, defaults to 100
+	MaxSize *int
+	// number of rotated files which are kept, defaults to 5
+	MaxBackups *int
+}
+
+type MetricsConfig struct {
+	// address of the HTTP listener serving Prometheus metrics, the endpoint
+	// isn't authenticated, defaults to 127.0.0.1:9464
+	ListenAddress string
+	// defaults to /metrics
+	Path string
+}
+
+type GatewayConfig struct {
+	// address of the HTTP/JSON listener, it uses the same TLS configuration
+	// and access policy as the gRPC listener
+	ListenAddress string
+}
+
+type ReplicationConfig struct {
+	// file holding the secret replicas authenticate with, it has to be the
+	// same on the primary and its replicas
+	TokenFile string
+	// address of the store to replicate from, makes this store a replica
+	Primary string
+	// how often changes are pulled from the primary, defaults to 10s
+	Interval *Duration
+	// CA certificate verifying the primary in production mode, the system
+	// roots are used if not set
+	CaFile string
+	// client certificate presented to the primary, needed if it requires
+	// client certificates
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+type TransportConfig struct {
+	// clients sending keepalive pings more often are disconnected, defaults
+	// to 30s
+	KeepaliveMinTime *Duration
+	// whether clients may send keepalive pings without active calls,
+	// defaults to true
+	KeepaliveWithoutCalls *bool
+	// time without activity after which the store pings the client, defaults
+	// to 2h
+	KeepaliveTime *Duration
+	// time to wait for the acknowledgement of a ping, defaults to 20s
+	KeepaliveTimeout *Duration
+	// time after which idle connections are closed, not limited by default
+	MaxConnectionIdle *Duration
+	// maximum size of received messages in bytes, defaults to 4 MiB
+	MaxReceiveSize *int
+	// maximum size of sent messages in bytes, defaults to 16 MiB
+	MaxSendSize *int
+	// maximum number of concurrent calls per connection, defaults to 100
+	MaxConcurrentStreams *uint32
+	// time for new connections to complete the handshake, defaults to 10s
+	HandshakeTimeout *Duration
+	// maximum number of open TCP connections, not limited if 0
+	MaxConnections int
+	// maximum number of open TCP connections per source address, not limited
+	// if 0
+	MaxConnectionsPerSource int
 }
 
 type TlsConfig struct {
-	CertFile string
-	KeyFile  string
+	CertFile          string
+	KeyFile           string
+	RequireClientCert bool
+}
+
+type RateLimitConfig struct {
+	FreeAttempts  *int
+	BaseDelay     *Duration
+	MaxDelay      *Duration
+	AutoLockAfter int
+}
+
+type AccessConfig struct {
+	// whether connections from the store host itself are accepted, defaults
+	// to false in production mode
+	AllowLocal *bool
+	// networks (CIDR or single addresses) which may connect, all sources may
+	// connect if this is empty
+	Allow []string
+	// networks which are always rejected, takes precedence over Allow
+	Deny []string
+	// networks individual clients may connect from, keyed by client ID
+	Clients map[string][]string
+}
+
+type UnixSocketConfig struct {
+	Path string
+	// permissions of the socket file, defaults to 0660
+	Mode *uint32
+	// local users who may connect, keyed by user name or numeric uid
+	Users map[string]*UnixPeerConfig
+	// local groups who may connect, keyed by group name or numeric gid, only
+	// used if the user isn't configured explicitly
+	Groups map[string]*UnixPeerConfig
+}
+
+type UnixPeerConfig struct {
+	// client the peer is identified as, no client secret is needed
+	ClientId string
+	// items the peer may read, any item if empty
+	Items []string
+	// whether the peer may call admin operations (which still require admin
+	// credentials)
+	AllowAdmin bool
+}
+
+type ApprovalConfig struct {
+	RequestTtl  *Duration
+	FetchWindow *Duration
+}
+
+// Duration is a time.Duration which is written as a string (e.g. "1m30s")
+// in the configuration file.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	d.Duration = parsed
+
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -43,15 +213,261 @@ func LoadConfig(path string) (*Config, error) {
 	}()
 
 	decoder := toml.NewDecoder(configReader)
+	decoder.DisallowUnknownFields()
 
 	var conf Config
 	if err = decoder.Decode(&conf); err != nil {
+		return nil, decodeError(path, err)
+	}
+
+	if err = applyEnv(&conf, os.Environ()); err != nil {
 		return nil, err
 	}
 
 	return &conf, nil
 }
 
+func decodeError(path string, err error) error {
+	var strictErr *toml.StrictMissingError
+	if errors.As(err, &strictErr) {
+		var errs []error
+		for _, keyErr := range strictErr.Errors {
+			row, column := keyErr.Position()
+			errs = append(errs, fmt.Errorf("%s:%d:%d: unknown key %s", path, row, column, strings.Join(keyErr.Key(), ".")))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		row, column := decodeErr.Position()
+		return fmt.Errorf("%s:%d:%d: %v", path, row, column, decodeErr)
+	}
+
+	return fmt.Errorf("%s: %v", path, err)
+}
+
+// Validate checks the settings which can be verified without touching the
+// system, all problems are reported at once.
+func (c *Config) Validate(prod bool) error {
+	var errs []error
+	invalid := func(key string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.StoragePath == "" {
+		invalid("StoragePath", "is required")
+	}
+
+	if c.ListenAddress == "" {
+		invalid("ListenAddress", "is required")
+	} else if _, _, err := net.SplitHostPort(c.ListenAddress); err != nil {
+		invalid("ListenAddress", "%v", err)
+	}
+
+	if c.Tls == nil {
+		if prod {
+			invalid("Tls", "is required in production mode")
+		}
+	} else {
+		if c.Tls.CertFile == "" {
+			invalid("Tls.CertFile", "is required")
+		}
+
+		if c.Tls.KeyFile == "" {
+			invalid("Tls.KeyFile", "is required")
+		}
+	}
+
+	if c.RateLimit != nil {
+		if c.RateLimit.FreeAttempts != nil && *c.RateLimit.FreeAttempts < 0 {
+			invalid("RateLimit.FreeAttempts", "must not be negative")
+		}
+
+		if c.RateLimit.BaseDelay != nil && c.RateLimit.BaseDelay.Duration <= 0 {
+			invalid("RateLimit.BaseDelay", "must be positive")
+		}
+
+		if c.RateLimit.MaxDelay != nil && c.RateLimit.BaseDelay != nil &&
+			c.RateLimit.MaxDelay.Duration < c.RateLimit.BaseDelay.Duration {
+			invalid("RateLimit.MaxDelay", "must not be less than RateLimit.BaseDelay")
+		}
+
+		if c.RateLimit.AutoLockAfter < 0 {
+			invalid("RateLimit.AutoLockAfter", "must not be negative")
+		}
+	}
+
+	if c.Approval != nil {
+		if c.Approval.RequestTtl != nil && c.Approval.RequestTtl.Duration <= 0 {
+			invalid("Approval.RequestTtl", "must be positive")
+		}
+
+		if c.Approval.FetchWindow != nil && c.Approval.FetchWindow.Duration <= 0 {
+			invalid("Approval.FetchWindow", "must be positive")
+		}
+	}
+
+	if c.UnixSocket != nil {
+		if !filepath.IsAbs(c.UnixSocket.Path) {
+			invalid("UnixSocket.Path", "must be an absolute path")
+		}
+
+		if c.UnixSocket.Mode != nil && *c.UnixSocket.Mode&^uint32(os.ModePerm) != 0 {
+			invalid("UnixSocket.Mode", "must only contain permission bits")
+		}
+	}
+
+	if c.Metrics != nil {
+		if c.Metrics.ListenAddress != "" {
+			if _, _, err := net.SplitHostPort(c.Metrics.ListenAddress); err != nil {
+				invalid("Metrics.ListenAddress", "%v", err)
+			}
+		}
+
+		if c.Metrics.Path != "" && !strings.HasPrefix(c.Metrics.Path, "/") {
+			invalid("Metrics.Path", "must start with /")
+		}
+	}
+
+	if c.Gateway != nil {
+		if c.Gateway.ListenAddress == "" {
+			invalid("Gateway.ListenAddress", "is required")
+		} else if _, _, err := net.SplitHostPort(c.Gateway.ListenAddress); err != nil {
+			invalid("Gateway.ListenAddress", "%v", err)
+		}
+	}
+
+	if c.Replication != nil {
+		if c.Replication.TokenFile == "" {
+			invalid("Replication.TokenFile", "is required")
+		}
+
+		if c.Replication.Primary != "" {
+			if _, _, err := net.SplitHostPort(c.Replication.Primary); err != nil {
+				invalid("Replication.Primary", "%v", err)
+			}
+		}
+
+		if c.Replication.Interval != nil && c.Replication.Interval.Duration <= 0 {
+			invalid("Replication.Interval", "must be positive")
+		}
+
+		if (c.Replication.ClientCertFile == "") != (c.Replication.ClientKeyFile == "") {
+			invalid("Replication.ClientKeyFile", "is required along with Replication.ClientCertFile")
+		}
+	}
+
+	if c.Transport != nil {
+		positiveDuration := func(key string, d *Duration) {
+			if d != nil && d.Duration <= 0 {
+				invalid(key, "must be positive")
+			}
+		}
+
+		positiveDuration("Transport.KeepaliveMinTime", c.Transport.KeepaliveMinTime)
+		positiveDuration("Transport.KeepaliveTime", c.Transport.KeepaliveTime)
+		positiveDuration("Transport.KeepaliveTimeout", c.Transport.KeepaliveTimeout)
+		positiveDuration("Transport.MaxConnectionIdle", c.Transport.MaxConnectionIdle)
+		positiveDuration("Transport.HandshakeTimeout", c.Transport.HandshakeTimeout)
+
+		if c.Transport.MaxReceiveSize != nil && *c.Transport.MaxReceiveSize <= 0 {
+			invalid("Transport.MaxReceiveSize", "must be positive")
+		}
+
+		if c.Transport.MaxSendSize != nil && *c.Transport.MaxSendSize <= 0 {
+			invalid("Transport.MaxSendSize", "must be positive")
+		}
+
+		if c.Transport.MaxConcurrentStreams != nil && *c.Transport.MaxConcurrentStreams == 0 {
+			invalid("Transport.MaxConcurrentStreams", "must be positive")
+		}
+
+		if c.Transport.MaxConnections < 0 {
+			invalid("Transport.MaxConnections", "must not be negative")
+		}
+
+		if c.Transport.MaxConnectionsPerSource < 0 {
+			invalid("Transport.MaxConnectionsPerSource", "must not be negative")
+		}
+	}
+
+	if c.ShutdownTimeout != nil && c.ShutdownTimeout.Duration <= 0 {
+		invalid("ShutdownTimeout", "must be positive")
+	}
+
+	if c.LogLevel != "" {
+		if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+			invalid("LogLevel", "%v", err)
+		}
+	}
+
+	if c.Logging != nil {
+		if c.Logging.Format != "" && c.Logging.Format != logging.FormatConsole && c.Logging.Format != logging.FormatJson {
+			invalid("Logging.Format", "must be %s or %s", logging.FormatConsole, logging.FormatJson)
+		}
+
+		if c.Logging.Stdout != nil && !*c.Logging.Stdout && c.Logging.File == nil && !c.Logging.Journald {
+			invalid("Logging.Stdout", "can't be disabled without logging to a file or journald")
+		}
+
+		if c.Logging.File != nil {
+			if c.Logging.File.Path == "" {
+				invalid("Logging.File.Path", "is required")
+			}
+
+			if c.Logging.File.MaxSize != nil && *c.Logging.File.MaxSize <= 0 {
+				invalid("Logging.File.MaxSize", "must be positive")
+			}
+
+			if c.Logging.File.MaxBackups != nil && *c.Logging.File.MaxBackups < 0 {
+				invalid("Logging.File.MaxBackups", "must not be negative")
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RestartRequired lists the settings which differ from the other config but
+// only take effect when the store is restarted.
+func (c *Config) RestartRequired(other *Config) []string {
+	var changed []string
+	check := func(key string, equal bool) {
+		if !equal {
+			changed = append(changed, key)
+		}
+	}
+
+	check("StoragePath", c.StoragePath == other.StoragePath)
+	check("ListenAddress", c.ListenAddress == other.ListenAddress)
+	check("Tls", equalPtr(c.Tls, other.Tls))
+	check("Metrics", equalPtr(c.Metrics, other.Metrics))
+	check("Reflection", c.Reflection == other.Reflection)
+	check("Transport", reflect.DeepEqual(c.Transport, other.Transport))
+	check("Gateway", equalPtr(c.Gateway, other.Gateway))
+	check("Replication", reflect.DeepEqual(c.Replication, other.Replication))
+	check("Logging", reflect.DeepEqual(c.Logging, other.Logging))
+
+	// the unix socket's policy can be reloaded, the socket itself can't
+	check("UnixSocket", (c.UnixSocket == nil) == (other.UnixSocket == nil))
+	if c.UnixSocket != nil && other.UnixSocket != nil {
+		check("UnixSocket.Path", c.UnixSocket.Path == other.UnixSocket.Path)
+		check("UnixSocket.Mode", equalPtr(c.UnixSocket.Mode, other.UnixSocket.Mode))
+	}
+
+	return changed
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
 func InitStoragePath(config *Config) error {
 	if config.StoragePath == "" {
 		return errors.New("no storage path configured")
@@ -0,0 +1,119 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoadConfig_UnknownKey(t *testing.T) {
+	path := writeConfig(t, "StoragePath = \"/tmp\"\nListenAdress = \":8443\"\n")
+
+	_, err := LoadConfig(path)
+	assert.ErrorContains(t, err, "config.toml:2:1: unknown key ListenAdress")
+}
+
+func TestLoadConfig_EnvOverrides(t *testing.T) {
+	path := writeConfig(t, "StoragePath = \"/tmp\"\nListenAddress = \":8443\"\n")
+
+	t.Setenv("CREDSTORE_LISTEN_ADDRESS", "127.0.0.1:9443")
+	t.Setenv("CREDSTORE_RATE_LIMIT_MAX_DELAY", "5m")
+	t.Setenv("CREDSTORE_ACCESS_ALLOW", "10.0.0.0/8, 192.168.0.0/16")
+	t.Setenv("CREDSTORE_UNIX_SOCKET_MODE", "0600")
+
+	config, err := LoadConfig(path)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "127.0.0.1:9443", config.ListenAddress)
+	assert.Equal(t, 5*time.Minute, config.RateLimit.MaxDelay.Duration)
+	assert.Nil(t, config.RateLimit.BaseDelay)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.0.0/16"}, config.Access.Allow)
+	assert.Equal(t, uint32(0o600), *config.UnixSocket.Mode)
+	assert.Nil(t, config.Tls)
+}
+
+func TestLoadConfig_UnknownEnv(t *testing.T) {
+	path := writeConfig(t, "StoragePath = \"/tmp\"\nListenAddress = \":8443\"\n")
+
+	t.Setenv("CREDSTORE_LISTEN_PORT", "8443")
+
+	config, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ":8443", config.ListenAddress)
+}
+
+func TestLoadConfig_InvalidEnv(t *testing.T) {
+	path := writeConfig(t, "StoragePath = \"/tmp\"\nListenAddress = \":8443\"\n")
+
+	t.Setenv("CREDSTORE_ACCESS_CLIENTS", "client")
+	t.Setenv("CREDSTORE_RATE_LIMIT_MAX_DELAY", "soon")
+
+	_, err := LoadConfig(path)
+	assert.ErrorContains(t, err, "CREDSTORE_ACCESS_CLIENTS: can't be set from the environment")
+	assert.ErrorContains(t, err, "CREDSTORE_RATE_LIMIT_MAX_DELAY")
+}
+
+func TestValidate(t *testing.T) {
+	freeAttempts := -1
+
+	config := &Config{
+		ListenAddress:   "localhost",
+		RateLimit:       &RateLimitConfig{FreeAttempts: &freeAttempts},
+		UnixSocket:      &UnixSocketConfig{Path: "credstore.sock"},
+		LogLevel:        "verbose",
+		ShutdownTimeout: &Duration{},
+		Transport:       &TransportConfig{KeepaliveTime: &Duration{}, MaxConnections: -1},
+		Replication:     &ReplicationConfig{Primary: "primary", ClientCertFile: "replica.pem"},
+		Logging:         &LoggingConfig{Format: "text", File: &LogFileConfig{}},
+	}
+
+	err := config.Validate(true)
+	assert.ErrorContains(t, err, "StoragePath: is required")
+	assert.ErrorContains(t, err, "ListenAddress: address localhost: missing port in address")
+	assert.ErrorContains(t, err, "Tls: is required in production mode")
+	assert.ErrorContains(t, err, "RateLimit.FreeAttempts: must not be negative")
+	assert.ErrorContains(t, err, "UnixSocket.Path: must be an absolute path")
+	assert.ErrorContains(t, err, "LogLevel: unknown log level verbose")
+	assert.ErrorContains(t, err, "ShutdownTimeout: must be positive")
+	assert.ErrorContains(t, err, "Transport.KeepaliveTime: must be positive")
+	assert.ErrorContains(t, err, "Transport.MaxConnections: must not be negative")
+	assert.ErrorContains(t, err, "Replication.TokenFile: is required")
+	assert.ErrorContains(t, err, "Replication.Primary: address primary: missing port in address")
+	assert.ErrorContains(t, err, "Replication.ClientKeyFile: is required along with Replication.ClientCertFile")
+	assert.ErrorContains(t, err, "Logging.Format: must be console or json")
+	assert.ErrorContains(t, err, "Logging.File.Path: is required")
+
+	assert.NoError(t, (&Config{StoragePath: "/tmp", ListenAddress: ":8443"}).Validate(false))
+}
+
+func TestRestartRequired(t *testing.T) {
+	current := &Config{StoragePath: "/tmp", ListenAddress: ":8443", LogLevel: "info"}
+	reloaded := &Config{StoragePath: "/tmp", ListenAddress: ":9443", LogLevel: "debug", Reflection: true}
+
+	assert.Equal(t, []string{"ListenAddress", "Reflection"}, current.RestartRequired(reloaded))
+}
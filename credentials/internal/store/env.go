@@ -0,0 +1,183 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix is the prefix of environment variables overriding settings of
+// the configuration file, e.g. CREDSTORE_TLS_CERT_FILE overrides Tls.CertFile
+const EnvPrefix = "CREDSTORE_"
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// applyEnv overrides the settings for which an environment variable is set.
+// Lists are separated by commas, maps can't be overridden. Unknown variables
+// are ignored, as the environment of a service may contain unrelated ones.
+func applyEnv(conf *Config, environ []string) error {
+	overrides := make(map[string]string)
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+		if ok && strings.HasPrefix(name, EnvPrefix) {
+			overrides[name] = value
+		}
+	}
+
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	var errs []error
+	applyEnvFields(reflect.ValueOf(conf).Elem(), strings.TrimSuffix(EnvPrefix, "_"), overrides, &errs)
+
+	for _, name := range slices.Sorted(maps.Keys(overrides)) {
+		log.Warn().Str("variable", name).Msg("ignoring environment variable, it doesn't match any setting")
+	}
+
+	return errors.Join(errs...)
+}
+
+func applyEnvFields(value reflect.Value, prefix string, overrides map[string]string, errs *[]error) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + "_" + envName(field.Name)
+		target := value.Field(i)
+
+		if target.Kind() == reflect.Pointer && target.Type().Elem().Kind() == reflect.Struct &&
+			!target.Type().Implements(textUnmarshalerType) {
+			if !hasEnvPrefix(overrides, name+"_") {
+				continue
+			}
+
+			if target.IsNil() {
+				target.Set(reflect.New(target.Type().Elem()))
+			}
+
+			applyEnvFields(target.Elem(), name, overrides, errs)
+			continue
+		}
+
+		raw, ok := overrides[name]
+		if !ok {
+			continue
+		}
+
+		delete(overrides, name)
+
+		if err := setEnvValue(target, raw); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+}
+
+func setEnvValue(target reflect.Value, raw string) error {
+	if target.Kind() == reflect.Pointer {
+		fresh := reflect.New(target.Type().Elem())
+		if err := setEnvValue(fresh.Elem(), raw); err != nil {
+			return err
+		}
+
+		target.Set(fresh)
+		return nil
+	}
+
+	if unmarshaler, ok := target.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+
+		target.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		target.SetInt(parsed)
+	case reflect.Uint32:
+		// modes are usually written in octal
+		parsed, err := strconv.ParseUint(raw, 0, 32)
+		if err != nil {
+			return err
+		}
+
+		target.SetUint(parsed)
+	case reflect.Slice:
+		if target.Type().Elem().Kind() != reflect.String {
+			return errors.New("can't be set from the environment")
+		}
+
+		var values []string
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+
+		target.Set(reflect.ValueOf(values))
+	default:
+		return errors.New("can't be set from the environment")
+	}
+
+	return nil
+}
+
+func hasEnvPrefix(overrides map[string]string, prefix string) bool {
+	for name := range overrides {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// envName converts a field name to upper snake case, e.g. CertFile becomes
+// CERT_FILE and AllowLocal becomes ALLOW_LOCAL
+func envName(fieldName string) string {
+	var name strings.Builder
+	for i, r := range fieldName {
+		if i > 0 && unicode.IsUpper(r) {
+			name.WriteByte('_')
+		}
+
+		name.WriteRune(unicode.ToUpper(r))
+	}
+
+	return name.String()
+}
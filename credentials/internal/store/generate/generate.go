@@ -0,0 +1,233 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package generate
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"filippo.io/age"
+	"fmt"
+	"github.com/awnumar/memguard"
+	"golang.org/x/crypto/ssh"
+	"math/big"
+	"strings"
+)
+
+const (
+	DefaultPasswordLength = 32
+	MinPasswordLength     = 8
+	MaxPasswordLength     = 1024
+
+	DefaultWords     = 6
+	MinWords         = 4
+	MaxWords         = 64
+	DefaultSeparator = "-"
+	MaxSeparatorSize = 8
+
+	DefaultBytes = 32
+	MinBytes     = 16
+	MaxBytes     = 4096
+)
+
+type CharacterClass int
+
+const (
+	Lowercase CharacterClass = iota
+	Uppercase
+	Digits
+	Symbols
+)
+
+// the symbols exclude quotes, backslashes and whitespace, so passwords can be
+// pasted into shells and config files as they are
+var characters = map[CharacterClass]string{
+	Lowercase: "abcdefghijklmnopqrstuvwxyz",
+	Uppercase: "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	Digits:    "0123456789",
+	Symbols:   "!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+// Password generates a password of the length containing at least one
+// character of every class, lowercase and uppercase letters and digits are
+// used if no classes are specified
+func Password(length int, classes []CharacterClass) (*memguard.LockedBuffer, error) {
+	if length == 0 {
+		length = DefaultPasswordLength
+	}
+
+	if length < MinPasswordLength || length > MaxPasswordLength {
+		return nil, fmt.Errorf("password length must be between %d and %d", MinPasswordLength, MaxPasswordLength)
+	}
+
+	if len(classes) == 0 {
+		classes = []CharacterClass{Lowercase, Uppercase, Digits}
+	}
+
+	var alphabet strings.Builder
+	var sets []string
+	for _, class := range classes {
+		set, ok := characters[class]
+		if !ok {
+			return nil, fmt.Errorf("unknown character class: %d", class)
+		}
+
+		if strings.Contains(alphabet.String(), set) {
+			continue
+		}
+
+		alphabet.WriteString(set)
+		sets = append(sets, set)
+	}
+
+	chars := alphabet.String()
+
+	password := memguard.NewBuffer(length)
+	value := password.Bytes()
+
+	// rejecting passwords lacking a class keeps the characters uniformly
+	// distributed among the passwords which contain all classes
+	for {
+		for i := range value {
+			n, err := randomIndex(len(chars))
+			if err != nil {
+				password.Destroy()
+				return nil, err
+			}
+
+			value[i] = chars[n]
+		}
+
+		if containsAll(value, sets) {
+			return password, nil
+		}
+	}
+}
+
+func containsAll(value []byte, sets []string) bool {
+	for _, set := range sets {
+		if !bytes.ContainsAny(value, set) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Passphrase generates a passphrase of random words from the BIP39 word list,
+// each word adds 11 bits of entropy
+func Passphrase(words int, separator string) (*memguard.LockedBuffer, error) {
+	if words == 0 {
+		words = DefaultWords
+	}
+
+	if words < MinWords || words > MaxWords {
+		return nil, fmt.Errorf("word count must be between %d and %d", MinWords, MaxWords)
+	}
+
+	if separator == "" {
+		separator = DefaultSeparator
+	} else if len(separator) > MaxSeparatorSize {
+		return nil, fmt.Errorf("separator must not be longer than %d bytes", MaxSeparatorSize)
+	}
+
+	// no BIP39 word is longer than 8 letters, so the value is never copied
+	value := make([]byte, 0, words*(8+len(separator)))
+	for i := 0; i < words; i++ {
+		n, err := randomIndex(len(wordlist))
+		if err != nil {
+			memguard.WipeBytes(value)
+			return nil, err
+		}
+
+		if i > 0 {
+			value = append(value, separator...)
+		}
+
+		value = append(value, wordlist[n]...)
+	}
+
+	return memguard.NewBufferFromBytes(value), nil
+}
+
+// RandomBytes generates a value of raw random bytes
+func RandomBytes(length int) (*memguard.LockedBuffer, error) {
+	if length == 0 {
+		length = DefaultBytes
+	}
+
+	if length < MinBytes || length > MaxBytes {
+		return nil, fmt.Errorf("byte count must be between %d and %d", MinBytes, MaxBytes)
+	}
+
+	return memguard.NewBufferRandom(length), nil
+}
+
+// X25519KeyPair generates an age identity, the identity is returned as the
+// value and the recipient as the public key
+func X25519KeyPair() (*memguard.LockedBuffer, string, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return memguard.NewBufferFromBytes([]byte(identity.String())), identity.Recipient().String(), nil
+}
+
+// Ed25519KeyPair generates an SSH key pair, the private key is returned in
+// the OpenSSH format and the public key in the authorized_keys format
+func Ed25519KeyPair(comment string) (*memguard.LockedBuffer, string, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer memguard.WipeBytes(privateKey)
+
+	block, err := ssh.MarshalPrivateKey(privateKey, comment)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer memguard.WipeBytes(block.Bytes)
+
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey)))
+	if comment != "" {
+		authorizedKey += " " + comment
+	}
+
+	return memguard.NewBufferFromBytes(pem.EncodeToMemory(block)), authorizedKey, nil
+}
+
+func randomIndex(n int) (int, error) {
+	if n <= 0 {
+		return 0, errors.New("empty alphabet")
+	}
+
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(index.Int64()), nil
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package generate
+
+import (
+	"bytes"
+	"filippo.io/age"
+	"golang.org/x/crypto/ssh"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassword(t *testing.T) {
+	password, err := Password(0, nil)
+	require.NoError(t, err)
+	defer password.Destroy()
+
+	assert.Equal(t, DefaultPasswordLength, password.Size())
+	assert.True(t, bytes.ContainsAny(password.Bytes(), characters[Lowercase]))
+	assert.True(t, bytes.ContainsAny(password.Bytes(), characters[Uppercase]))
+	assert.True(t, bytes.ContainsAny(password.Bytes(), characters[Digits]))
+	assert.False(t, bytes.ContainsAny(password.Bytes(), characters[Symbols]))
+}
+
+func TestPassword_Classes(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		password, err := Password(MinPasswordLength, []CharacterClass{Digits, Symbols})
+		require.NoError(t, err)
+
+		for _, c := range password.Bytes() {
+			assert.True(t, strings.ContainsRune(characters[Digits]+characters[Symbols], rune(c)))
+		}
+
+		assert.True(t, bytes.ContainsAny(password.Bytes(), characters[Digits]))
+		assert.True(t, bytes.ContainsAny(password.Bytes(), characters[Symbols]))
+
+		password.Destroy()
+	}
+}
+
+func TestPassword_Invalid(t *testing.T) {
+	_, err := Password(MinPasswordLength-1, nil)
+	assert.Error(t, err)
+
+	_, err = Password(MaxPasswordLength+1, nil)
+	assert.Error(t, err)
+
+	_, err = Password(0, []CharacterClass{42})
+	assert.Error(t, err)
+}
+
+func TestPassphrase(t *testing.T) {
+	assert.Len(t, wordlist, 2048)
+
+	passphrase, err := Passphrase(0, "")
+	require.NoError(t, err)
+	defer passphrase.Destroy()
+
+	words := strings.Split(passphrase.String(), DefaultSeparator)
+	require.Len(t, words, DefaultWords)
+
+	for _, word := range words {
+		assert.Contains(t, wordlist, word)
+	}
+
+	passphrase, err = Passphrase(MinWords, " ")
+	require.NoError(t, err)
+	defer passphrase.Destroy()
+
+	assert.Len(t, strings.Fields(passphrase.String()), MinWords)
+
+	_, err = Passphrase(MinWords-1, "")
+	assert.Error(t, err)
+
+	_, err = Passphrase(0, "123456789")
+	assert.Error(t, err)
+}
+
+func TestRandomBytes(t *testing.T) {
+	value, err := RandomBytes(0)
+	require.NoError(t, err)
+	defer value.Destroy()
+
+	assert.Equal(t, DefaultBytes, value.Size())
+
+	_, err = RandomBytes(MaxBytes + 1)
+	assert.Error(t, err)
+}
+
+func TestX25519KeyPair(t *testing.T) {
+	value, recipient, err := X25519KeyPair()
+	require.NoError(t, err)
+	defer value.Destroy()
+
+	identity, err := age.ParseX25519Identity(value.String())
+	require.NoError(t, err)
+
+	assert.Equal(t, recipient, identity.Recipient().String())
+}
+
+func TestEd25519KeyPair(t *testing.T) {
+	value, authorizedKey, err := Ed25519KeyPair("backup@example")
+	require.NoError(t, err)
+	defer value.Destroy()
+
+	signer, err := ssh.ParsePrivateKey(value.Bytes())
+	require.NoError(t, err)
+
+	publicKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	require.NoError(t, err)
+
+	assert.Equal(t, "backup@example", comment)
+	assert.Equal(t, signer.PublicKey().Marshal(), publicKey.Marshal())
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxLabels is the maximum number of labels of an item
+const MaxLabels = 32
+
+var (
+	keyPattern   = regexp.MustCompile(`^[a-z0-9]([a-z0-9._/-]{0,62}[a-z0-9])?$`)
+	valuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]{0,62}[A-Za-z0-9])?)?$`)
+)
+
+// Validate checks the keys and values of the labels, keys are lowercase and
+// values may be empty
+func Validate(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("too many labels, at most %d are allowed", MaxLabels)
+	}
+
+	for key, value := range labels {
+		if !keyPattern.MatchString(key) {
+			return fmt.Errorf("invalid label key: %q", key)
+		}
+
+		if !valuePattern.MatchString(value) {
+			return fmt.Errorf("invalid value of label %s: %q", key, value)
+		}
+	}
+
+	return nil
+}
+
+type operator int
+
+const (
+	exists operator = iota
+	notExists
+	equals
+	notEquals
+)
+
+type requirement struct {
+	key      string
+	operator operator
+	value    string
+}
+
+func (r requirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.key]
+
+	switch r.operator {
+	case exists:
+		return ok
+	case notExists:
+		return !ok
+	case equals:
+		return ok && value == r.value
+	case notEquals:
+		return !ok || value != r.value
+	}
+
+	return false
+}
+
+// Selector matches labels against all of its requirements
+type Selector []requirement
+
+// Parse parses a comma separated list of requirements, each one is either
+// key=value, key!=value, key (the label is set) or !key (the label isn't set)
+func Parse(selector string) (Selector, error) {
+	if strings.TrimSpace(selector) == "" {
+		return nil, errors.New("selector is empty")
+	}
+
+	var result Selector
+	for _, raw := range strings.Split(selector, ",") {
+		raw = strings.TrimSpace(raw)
+
+		var req requirement
+		if key, value, ok := strings.Cut(raw, "!="); ok {
+			req = requirement{key: key, operator: notEquals, value: value}
+		} else if key, value, ok := strings.Cut(raw, "="); ok {
+			req = requirement{key: key, operator: equals, value: value}
+		} else if key, ok := strings.CutPrefix(raw, "!"); ok {
+			req = requirement{key: key, operator: notExists}
+		} else {
+			req = requirement{key: raw, operator: exists}
+		}
+
+		req.key = strings.TrimSpace(req.key)
+		req.value = strings.TrimSpace(req.value)
+
+		if !keyPattern.MatchString(req.key) {
+			return nil, fmt.Errorf("invalid selector requirement: %q", raw)
+		}
+
+		if !valuePattern.MatchString(req.value) {
+			return nil, fmt.Errorf("invalid selector requirement: %q", raw)
+		}
+
+		result = append(result, req)
+	}
+
+	return result, nil
+}
+
+// Matches returns whether the labels meet all requirements
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		if !req.matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(nil))
+	assert.NoError(t, Validate(map[string]string{"env": "prod", "borg/repo": "host-1.data", "backup": ""}))
+
+	assert.Error(t, Validate(map[string]string{"Env": "prod"}))
+	assert.Error(t, Validate(map[string]string{"": "prod"}))
+	assert.Error(t, Validate(map[string]string{"env": "prod,dev"}))
+	assert.Error(t, Validate(map[string]string{"env": "-prod"}))
+}
+
+func TestParse(t *testing.T) {
+	selector, err := Parse("env=prod, tier!=db,backup,!deprecated")
+	require.NoError(t, err)
+
+	assert.True(t, selector.Matches(map[string]string{"env": "prod", "backup": ""}))
+	assert.True(t, selector.Matches(map[string]string{"env": "prod", "tier": "web", "backup": "daily"}))
+
+	assert.False(t, selector.Matches(map[string]string{"env": "dev", "backup": ""}))
+	assert.False(t, selector.Matches(map[string]string{"env": "prod", "tier": "db", "backup": ""}))
+	assert.False(t, selector.Matches(map[string]string{"env": "prod"}))
+	assert.False(t, selector.Matches(map[string]string{"env": "prod", "backup": "", "deprecated": "true"}))
+	assert.False(t, selector.Matches(nil))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, raw := range []string{"", " ", "env=prod,", "=prod", "!", "env==prod", "Env=prod"} {
+		_, err := Parse(raw)
+		assert.Error(t, err, raw)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package limiter
+
+import (
+	"context"
+	"sync"
+)
+
+// Identity is the kind of identity a call proved
+type Identity int
+
+const (
+	Unauthenticated Identity = iota
+	Admin
+	Client
+	// e.g. a replica or an enrollment token
+	Other
+)
+
+type attemptKey struct{}
+
+// Attempt records whether a call actually authenticated, so that failures
+// are only forgiven after a successful authentication instead of after any
+// call that happened to succeed.
+type Attempt struct {
+	lock     sync.Mutex
+	identity Identity
+	// called once the call authenticated, e.g. to release its reservation
+	onAuthenticated func()
+}
+
+func NewContext(ctx context.Context, onAuthenticated func()) (context.Context, *Attempt) {
+	attempt := &Attempt{onAuthenticated: onAuthenticated}
+
+	return context.WithValue(ctx, attemptKey{}, attempt), attempt
+}
+
+// Authenticated records that the call proved the identity
+func Authenticated(ctx context.Context, identity Identity) {
+	attempt, _ := ctx.Value(attemptKey{}).(*Attempt)
+	if attempt == nil {
+		return
+	}
+
+	attempt.lock.Lock()
+	attempt.identity = identity
+	attempt.lock.Unlock()
+
+	if attempt.onAuthenticated != nil {
+		attempt.onAuthenticated()
+	}
+}
+
+func (a *Attempt) Identity() Identity {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.identity
+}
@@ -0,0 +1,302 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package limiter
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultFreeAttempts = 3
+	DefaultBaseDelay    = time.Second
+	DefaultMaxDelay     = 15 * time.Minute
+)
+
+type Options struct {
+	// number of consecutive failures before any backoff applies
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// number of consecutive admin failures after which the vault is locked,
+	// 0 disables auto-locking
+	AutoLockAfter int
+}
+
+type Stats struct {
+	AuthFailures   uint64
+	AdminFailures  uint64
+	ClientFailures uint64
+	RejectedCalls  uint64
+	AutoLocks      uint64
+	BlockedKeys    int
+}
+
+type entry struct {
+	failures int
+	// attempts which passed the check but whose outcome isn't known yet
+	pending      int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// Limiter keeps track of failed authentication attempts per key (e.g. source
+// address or client ID) and blocks keys with exponentially growing delays.
+type Limiter struct {
+	lock                     sync.Mutex
+	options                  Options
+	entries                  map[string]*entry
+	consecutiveAdminFailures int
+	stats                    Stats
+	// closed and replaced whenever a reservation is released
+	released chan struct{}
+}
+
+func New(options Options) *Limiter {
+	if options.FreeAttempts < 0 {
+		options.FreeAttempts = 0
+	}
+
+	if options.BaseDelay <= 0 {
+		options.BaseDelay = DefaultBaseDelay
+	}
+
+	if options.MaxDelay < options.BaseDelay {
+		options.MaxDelay = DefaultMaxDelay
+	}
+
+	return &Limiter{
+		lock:     sync.Mutex{},
+		options:  options,
+		entries:  make(map[string]*entry),
+		released: make(chan struct{}),
+	}
+}
+
+func (l *Limiter) SetOptions(options Options) {
+	fresh := New(options)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.options = fresh.options
+}
+
+// Reserve checks the keys and reserves an attempt for each of them, it
+// returns the remaining time any of the keys is blocked for. Concurrent
+// attempts count towards the free attempts of a key, so they can't exceed
+// them before their failures are recorded, and wait for earlier ones
+// otherwise. Rejected calls are counted. A successful reservation has to be
+// released once its failure was recorded or the call authenticated.
+func (l *Limiter) Reserve(ctx context.Context, keys ...string) (time.Duration, bool) {
+	for {
+		l.lock.Lock()
+
+		retryAfter, available := l.checkUnsafe(time.Now(), keys)
+		if retryAfter > 0 {
+			l.stats.RejectedCalls++
+			l.lock.Unlock()
+
+			return retryAfter, false
+		}
+
+		if available {
+			for _, key := range keys {
+				e, ok := l.entries[key]
+				if !ok {
+					e = &entry{}
+					l.entries[key] = e
+				}
+
+				e.pending++
+			}
+
+			l.lock.Unlock()
+
+			return 0, true
+		}
+
+		released := l.released
+		l.lock.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return 0, false
+		}
+	}
+}
+
+// checkUnsafe returns the remaining time any of the keys is blocked for and
+// whether all of them have attempts left
+func (l *Limiter) checkUnsafe(now time.Time, keys []string) (time.Duration, bool) {
+	var retryAfter time.Duration
+	available := true
+
+	for _, key := range keys {
+		e, ok := l.entries[key]
+		if !ok {
+			continue
+		}
+
+		if remaining := e.blockedUntil.Sub(now); remaining > retryAfter {
+			retryAfter = remaining
+		}
+
+		// the attempt after the free ones blocks the key if it fails
+		if e.pending >= max(l.options.FreeAttempts-e.failures, 0)+1 {
+			available = false
+		}
+	}
+
+	return retryAfter, available
+}
+
+// Release gives back a reservation, waking calls waiting for it
+func (l *Limiter) Release(keys ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, key := range keys {
+		e, ok := l.entries[key]
+		if !ok {
+			continue
+		}
+
+		e.pending = max(e.pending-1, 0)
+		if e.pending == 0 && e.failures == 0 {
+			delete(l.entries, key)
+		}
+	}
+
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+func (l *Limiter) Failure(keys ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.pruneUnsafe(now)
+
+	l.stats.AuthFailures++
+
+	for _, key := range keys {
+		e, ok := l.entries[key]
+		if !ok {
+			e = &entry{}
+			l.entries[key] = e
+		}
+
+		e.failures++
+		e.lastFailure = now
+
+		if e.failures > l.options.FreeAttempts {
+			e.blockedUntil = now.Add(l.delayUnsafe(e.failures - l.options.FreeAttempts))
+		}
+	}
+}
+
+func (l *Limiter) Success(keys ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, key := range keys {
+		e, ok := l.entries[key]
+		if !ok {
+			continue
+		}
+
+		if e.pending > 0 {
+			// keep the reservations of concurrent attempts
+			*e = entry{pending: e.pending}
+		} else {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// AdminFailure records a failed admin authentication and reports whether the
+// vault should be locked as a consequence.
+func (l *Limiter) AdminFailure() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.stats.AdminFailures++
+	l.consecutiveAdminFailures++
+
+	if l.options.AutoLockAfter > 0 && l.consecutiveAdminFailures >= l.options.AutoLockAfter {
+		l.consecutiveAdminFailures = 0
+		l.stats.AutoLocks++
+
+		return true
+	}
+
+	return false
+}
+
+func (l *Limiter) AdminSuccess() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.consecutiveAdminFailures = 0
+}
+
+func (l *Limiter) ClientFailure() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.stats.ClientFailures++
+}
+
+func (l *Limiter) Stats() Stats {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+
+	stats := l.stats
+	for _, e := range l.entries {
+		if e.blockedUntil.After(now) {
+			stats.BlockedKeys++
+		}
+	}
+
+	return stats
+}
+
+func (l *Limiter) delayUnsafe(exponent int) time.Duration {
+	delay := l.options.BaseDelay
+	for i := 1; i < exponent; i++ {
+		delay *= 2
+		if delay >= l.options.MaxDelay {
+			return l.options.MaxDelay
+		}
+	}
+
+	return delay
+}
+
+// pruneUnsafe forgets keys which haven't failed for a while, so that
+// occasional typos don't add up over time.
+func (l *Limiter) pruneUnsafe(now time.Time) {
+	maps.DeleteFunc(l.entries, func(_ string, e *entry) bool {
+		return e.pending == 0 && now.After(e.blockedUntil) && now.Sub(e.lastFailure) > 2*l.options.MaxDelay
+	})
+}
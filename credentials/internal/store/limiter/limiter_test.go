@@ -0,0 +1,123 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	l := New(Options{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: 4 * time.Minute})
+	ctx := context.Background()
+
+	for range 3 {
+		_, ok := l.Reserve(ctx, "ip:10.0.0.1")
+		assert.True(t, ok) // free attempts and the one blocking the key
+
+		l.Failure("ip:10.0.0.1")
+		l.Release("ip:10.0.0.1")
+	}
+
+	retryAfter, ok := l.Reserve(ctx, "ip:10.0.0.1")
+	assert.False(t, ok)
+	assert.InDelta(t, time.Minute, retryAfter, float64(time.Second))
+
+	l.Failure("ip:10.0.0.1")
+	retryAfter, _ = l.Reserve(ctx, "ip:10.0.0.1")
+	assert.InDelta(t, 2*time.Minute, retryAfter, float64(time.Second))
+
+	// Delay is capped
+	for range 5 {
+		l.Failure("ip:10.0.0.1")
+	}
+
+	retryAfter, _ = l.Reserve(ctx, "ip:10.0.0.1")
+	assert.InDelta(t, 4*time.Minute, retryAfter, float64(time.Second))
+
+	// Other keys aren't affected
+	_, ok = l.Reserve(ctx, "ip:10.0.0.2", "client:abc")
+	assert.True(t, ok)
+	l.Release("ip:10.0.0.2", "client:abc")
+
+	// But any blocked key blocks the call
+	_, ok = l.Reserve(ctx, "ip:10.0.0.2", "ip:10.0.0.1")
+	assert.False(t, ok)
+
+	stats := l.Stats()
+	assert.Equal(t, uint64(9), stats.AuthFailures)
+	assert.Equal(t, uint64(4), stats.RejectedCalls)
+	assert.Equal(t, 1, stats.BlockedKeys)
+
+	l.Success("ip:10.0.0.1")
+	_, ok = l.Reserve(ctx, "ip:10.0.0.1")
+	assert.True(t, ok)
+}
+
+func TestReserve_Concurrent(t *testing.T) {
+	l := New(Options{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Minute})
+
+	// concurrent attempts can't exceed the free attempts before failing
+	for range 3 {
+		_, ok := l.Reserve(context.Background(), "ip:10.0.0.1")
+		require.True(t, ok)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	retryAfter, ok := l.Reserve(ctx, "ip:10.0.0.1")
+	assert.False(t, ok)
+	assert.Zero(t, retryAfter)
+
+	// a waiting attempt continues once an earlier one is released, and is
+	// rejected if that one blocked the key
+	result := make(chan bool)
+	go func() {
+		_, ok := l.Reserve(context.Background(), "ip:10.0.0.1")
+		result <- ok
+	}()
+
+	for range 3 {
+		l.Failure("ip:10.0.0.1")
+		l.Release("ip:10.0.0.1")
+	}
+
+	assert.False(t, <-result)
+}
+func TestAutoLock(t *testing.T) {
+	l := New(Options{AutoLockAfter: 3})
+
+	assert.False(t, l.AdminFailure())
+	assert.False(t, l.AdminFailure())
+
+	l.AdminSuccess()
+
+	assert.False(t, l.AdminFailure())
+	assert.False(t, l.AdminFailure())
+	assert.True(t, l.AdminFailure())
+
+	assert.Equal(t, uint64(1), l.Stats().AutoLocks)
+
+	disabled := New(Options{})
+	for range 10 {
+		assert.False(t, disabled.AdminFailure())
+	}
+}
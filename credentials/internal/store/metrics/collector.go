@@ -0,0 +1,112 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"crypto/x509"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
+)
+
+var (
+	lockedDesc = prometheus.NewDesc(
+		namespace+"_vault_locked",
+		"Whether the vault is locked (1) or unlocked (0).",
+		nil, nil,
+	)
+	itemsDesc = prometheus.NewDesc(
+		namespace+"_vault_items",
+		"Number of items in the vault, 0 while locked.",
+		nil, nil,
+	)
+	authFailuresDesc = prometheus.NewDesc(
+		namespace+"_auth_failures_total",
+		"Number of failed authentication attempts by kind of credentials.",
+		[]string{"kind"}, nil,
+	)
+	rejectedCallsDesc = prometheus.NewDesc(
+		namespace+"_auth_rejected_calls_total",
+		"Number of calls rejected because of too many failed attempts.",
+		nil, nil,
+	)
+	blockedSourcesDesc = prometheus.NewDesc(
+		namespace+"_auth_blocked_sources",
+		"Number of sources currently delayed after failed attempts.",
+		nil, nil,
+	)
+	autoLocksDesc = prometheus.NewDesc(
+		namespace+"_auth_auto_locks_total",
+		"Number of times the vault was locked after failed admin attempts.",
+		nil, nil,
+	)
+	certificateExpiryDesc = prometheus.NewDesc(
+		namespace+"_certificate_expiry_timestamp_seconds",
+		"Expiry of the certificates used by the store as unix timestamp.",
+		[]string{"certificate"}, nil,
+	)
+)
+
+// stateCollector reads the current state on every scrape instead of keeping
+// its own copy of it
+type stateCollector struct {
+	state          *service.State
+	tlsCertificate func() *x509.Certificate
+}
+
+func newStateCollector(state *service.State, tlsCertificate func() *x509.Certificate) prometheus.Collector {
+	return &stateCollector{
+		state:          state,
+		tlsCertificate: tlsCertificate,
+	}
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lockedDesc
+	ch <- itemsDesc
+	ch <- authFailuresDesc
+	ch <- rejectedCallsDesc
+	ch <- blockedSourcesDesc
+	ch <- autoLocksDesc
+	ch <- certificateExpiryDesc
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	locked := 0.0
+	if c.state.IsLocked() {
+		locked = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(lockedDesc, prometheus.GaugeValue, locked)
+	ch <- prometheus.MustNewConstMetric(itemsDesc, prometheus.GaugeValue, float64(c.state.ItemCount()))
+
+	stats := c.state.Limiter().Stats()
+
+	ch <- prometheus.MustNewConstMetric(authFailuresDesc, prometheus.CounterValue, float64(stats.AdminFailures), "admin")
+	ch <- prometheus.MustNewConstMetric(authFailuresDesc, prometheus.CounterValue, float64(stats.ClientFailures), "client")
+	ch <- prometheus.MustNewConstMetric(rejectedCallsDesc, prometheus.CounterValue, float64(stats.RejectedCalls))
+	ch <- prometheus.MustNewConstMetric(blockedSourcesDesc, prometheus.GaugeValue, float64(stats.BlockedKeys))
+	ch <- prometheus.MustNewConstMetric(autoLocksDesc, prometheus.CounterValue, float64(stats.AutoLocks))
+
+	if c.tlsCertificate != nil {
+		if cert := c.tlsCertificate(); cert != nil {
+			ch <- prometheus.MustNewConstMetric(certificateExpiryDesc, prometheus.GaugeValue, float64(cert.NotAfter.Unix()), "tls")
+		}
+	}
+
+	if cert := c.state.Authority().Certificate(); cert != nil {
+		ch <- prometheus.MustNewConstMetric(certificateExpiryDesc, prometheus.GaugeValue, float64(cert.NotAfter.Unix()), "ca")
+	}
+}
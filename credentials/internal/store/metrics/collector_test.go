@@ -0,0 +1,50 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	state := newTestState(t)
+
+	state.Limiter().AdminFailure()
+	state.Limiter().ClientFailure()
+	state.Limiter().ClientFailure()
+
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	m := New(state, func() *x509.Certificate {
+		return &x509.Certificate{NotAfter: notAfter}
+	})
+
+	assert.Equal(t, map[string]float64{"": 1}, gather(t, m.registry, "credstore_vault_locked"))
+	assert.Equal(t, map[string]float64{"": 0}, gather(t, m.registry, "credstore_vault_items"))
+
+	assert.Equal(t, map[string]float64{
+		"kind=admin,":  1,
+		"kind=client,": 2,
+	}, gather(t, m.registry, "credstore_auth_failures_total"))
+
+	// the authority isn't created yet
+	assert.Equal(t, map[string]float64{
+		"certificate=tls,": float64(notAfter.Unix()),
+	}, gather(t, m.registry, "credstore_certificate_expiry_timestamp_seconds"))
+}
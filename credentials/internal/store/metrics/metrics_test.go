@@ -0,0 +1,118 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	readMethod   = "/credentials.CredStore/ReadVaultItem"
+	streamMethod = "/credentials.CredStore/ReadVaultItems"
+)
+
+func newTestState(t *testing.T) *service.State {
+	storagePath := filepath.Join(t.TempDir(), "vault")
+
+	vaultInstance, err := vault.NewVault(&vault.Options{Backend: vault.NewLocalStorageBackend(storagePath)})
+	require.NoError(t, err)
+
+	state, err := service.NewState(&store.Config{StoragePath: storagePath}, vaultInstance, "test", false)
+	require.NoError(t, err)
+
+	t.Cleanup(state.StopWatches)
+
+	return state
+}
+
+// gather returns the values of the metric family by its joined label values
+func gather(t *testing.T, gatherer prometheus.Gatherer, name string) map[string]float64 {
+	families, err := gatherer.Gather()
+	require.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			key := ""
+			for _, label := range metric.GetLabel() {
+				key += label.GetName() + "=" + label.GetValue() + ","
+			}
+
+			switch {
+			case metric.GetCounter() != nil:
+				values[key] = metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				values[key] = metric.GetGauge().GetValue()
+			case metric.GetHistogram() != nil:
+				values[key] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+
+	return values
+}
+
+func TestInterceptors(t *testing.T) {
+	m := New(newTestState(t), nil)
+
+	unary := m.UnaryServerInterceptor()
+	for _, err := range []error{
+		nil,
+		status.Error(codes.Unauthenticated, "nope"),
+		status.Error(codes.Unauthenticated, "nope"),
+		errors.New("not a status"),
+	} {
+		_, _ = unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: readMethod}, func(context.Context, any) (any, error) {
+			return nil, err
+		})
+	}
+
+	stream := m.StreamServerInterceptor()
+	_ = stream(nil, nil, &grpc.StreamServerInfo{FullMethod: streamMethod}, func(any, grpc.ServerStream) error {
+		return status.Error(codes.NotFound, "missing")
+	})
+
+	// one series per method and code, the code being its name
+	assert.Equal(t, map[string]float64{
+		"code=OK,method=" + readMethod + ",":              1,
+		"code=Unauthenticated,method=" + readMethod + ",": 2,
+		"code=Unknown,method=" + readMethod + ",":         1,
+		"code=NotFound,method=" + streamMethod + ",":      1,
+	}, gather(t, m.registry, "credstore_rpc_calls_total"))
+
+	// latencies are only by method
+	assert.Equal(t, map[string]float64{
+		"method=" + readMethod + ",":   4,
+		"method=" + streamMethod + ",": 1,
+	}, gather(t, m.registry, "credstore_rpc_duration_seconds"))
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+// Package redact keeps secrets out of logs and error messages. Sensitive
+// proto fields are marked with the debug_redact option.
+package redact
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"strings"
+)
+
+// Placeholder replaces redacted values
+const Placeholder = "[REDACTED]"
+
+// shorter secrets aren't scrubbed from text, they'd match all over the place
+const minScrubLength = 4
+
+// IsSensitive reports whether the field is marked with debug_redact
+func IsSensitive(fd protoreflect.FieldDescriptor) bool {
+	options, ok := fd.Options().(*descriptorpb.FieldOptions)
+	return ok && options.GetDebugRedact()
+}
+
+// Message returns a copy of the message with the values of all sensitive
+// fields replaced by the placeholder
+func Message(m proto.Message) proto.Message {
+	clone := proto.Clone(m)
+	redactMessage(clone.ProtoReflect())
+
+	return clone
+}
+
+func redactMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if IsSensitive(fd) {
+			switch {
+			case fd.IsList() || fd.IsMap():
+				m.Clear(fd)
+			case fd.Kind() == protoreflect.StringKind:
+				m.Set(fd, protoreflect.ValueOfString(Placeholder))
+			case fd.Kind() == protoreflect.BytesKind:
+				m.Set(fd, protoreflect.ValueOfBytes([]byte(Placeholder)))
+			default:
+				m.Clear(fd)
+			}
+
+			return true
+		}
+
+		forEachMessage(fd, v, redactMessage)
+
+		return true
+	})
+}
+
+// Secrets returns copies of the values of the sensitive fields set in the
+// message, which stay intact when the values in the message are wiped
+func Secrets(m proto.Message) []string {
+	var secrets []string
+	collectSecrets(m.ProtoReflect(), &secrets)
+
+	return secrets
+}
+
+func collectSecrets(m protoreflect.Message, secrets *[]string) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if IsSensitive(fd) && !fd.IsList() && !fd.IsMap() {
+			switch fd.Kind() {
+			case protoreflect.StringKind:
+				*secrets = append(*secrets, strings.Clone(v.String()))
+			case protoreflect.BytesKind:
+				// one byte strings may be static, those can't be wiped
+				*secrets = append(*secrets, strings.Clone(string(v.Bytes())))
+			default:
+			}
+
+			return true
+		}
+
+		forEachMessage(fd, v, func(nested protoreflect.Message) {
+			collectSecrets(nested, secrets)
+		})
+
+		return true
+	})
+}
+
+// forEachMessage calls f for the messages held by the field, be it a single
+// message, a list or the values of a map
+func forEachMessage(fd protoreflect.FieldDescriptor, v protoreflect.Value, f func(protoreflect.Message)) {
+	switch {
+	case fd.IsList():
+		if fd.Message() == nil {
+			return
+		}
+
+		list := v.List()
+		for i := 0; i < list.Len(); i++ {
+			f(list.Get(i).Message())
+		}
+	case fd.IsMap():
+		if fd.MapValue().Message() == nil {
+			return
+		}
+
+		v.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+			f(value.Message())
+			return true
+		})
+	case fd.Message() != nil:
+		f(v.Message())
+	}
+}
+
+// Scrub replaces the secrets in the text with the placeholder
+func Scrub(text string, secrets []string) string {
+	for _, secret := range secrets {
+		if len(secret) >= minScrubLength {
+			text = strings.ReplaceAll(text, secret, Placeholder)
+		}
+	}
+
+	return text
+}
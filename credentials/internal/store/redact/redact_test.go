@@ -0,0 +1,59 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package redact
+
+import (
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage(t *testing.T) {
+	request := &proto.AdminCreation{
+		Credentials: &proto.AdminCredentials{Passphrase: "current-passphrase", Name: "root"},
+		Name:        "alice",
+		Passphrase:  "new-passphrase",
+	}
+
+	redacted := Message(request).(*proto.AdminCreation)
+	assert.Equal(t, Placeholder, redacted.GetCredentials().GetPassphrase())
+	assert.Equal(t, "root", redacted.GetCredentials().GetName())
+	assert.Equal(t, Placeholder, redacted.GetPassphrase())
+	assert.Equal(t, "alice", redacted.GetName())
+
+	// the original is left alone
+	assert.Equal(t, "new-passphrase", request.GetPassphrase())
+
+	value := Message(&proto.ItemValue{Value: []byte("secret value")}).(*proto.ItemValue)
+	assert.Equal(t, []byte(Placeholder), value.GetValue())
+}
+
+func TestSecrets(t *testing.T) {
+	request := &proto.ReplicationRequest{
+		Token: "replication-token",
+		Files: []*proto.ReplicatedFile{{Path: ".identity", Data: []byte("file data")}},
+	}
+
+	assert.ElementsMatch(t, []string{"replication-token", "file data"}, Secrets(request))
+	assert.Empty(t, Secrets(&proto.AdminCredentials{Name: "root"}))
+}
+
+func TestScrub(t *testing.T) {
+	secrets := []string{"hunter22", "abc"}
+
+	assert.Equal(t, "wrong passphrase [REDACTED] for abc", Scrub("wrong passphrase hunter22 for abc", secrets))
+}
@@ -0,0 +1,177 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package replication
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"path"
+	"slices"
+	"strings"
+)
+
+// PromotedPath marks a replica which was promoted, it isn't replicated
+const PromotedPath = ".promoted"
+
+// replicatedDirs hold the files of the vault and the certificate authority,
+// the audit log isn't replicated, every store keeps its own
+var replicatedDirs = []string{"", ".admins", ".ca"}
+
+type File struct {
+	Path     string
+	Checksum string
+	Data     []byte
+	Deleted  bool
+}
+
+// Manifest returns the checksums of the replicated files keyed by path
+func Manifest(backend vault.Backend) (map[string]string, error) {
+	files, err := readFiles(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := make(map[string]string, len(files))
+	for filePath, data := range files {
+		manifest[filePath] = checksum(data)
+	}
+
+	return manifest, nil
+}
+
+// Changes returns the files which differ from the known ones, files which
+// aren't there anymore are returned as deleted
+func Changes(backend vault.Backend, known map[string]string) ([]File, error) {
+	files, err := readFiles(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []File
+	for filePath, data := range files {
+		sum := checksum(data)
+		if known[filePath] != sum {
+			changes = append(changes, File{Path: filePath, Checksum: sum, Data: data})
+		}
+	}
+
+	for filePath := range known {
+		if _, ok := files[filePath]; !ok {
+			changes = append(changes, File{Path: filePath, Deleted: true})
+		}
+	}
+
+	return changes, nil
+}
+
+// Apply writes the changed files. Item values are written before the
+// metadata referencing them and deleted after it, so the vault never sees
+// incomplete items.
+func Apply(backend vault.Backend, files []File) error {
+	for _, file := range files {
+		if !isReplicated(file.Path) {
+			return fmt.Errorf("refusing to replicate file: %s", file.Path)
+		}
+
+		if !file.Deleted && checksum(file.Data) != file.Checksum {
+			return fmt.Errorf("checksum mismatch of replicated file: %s", file.Path)
+		}
+	}
+
+	files = slices.Clone(files)
+	slices.SortStableFunc(files, func(a, b File) int {
+		return applyOrder(a) - applyOrder(b)
+	})
+
+	for _, file := range files {
+		var err error
+		if file.Deleted {
+			_, err = backend.DeleteFile(file.Path)
+		} else {
+			err = backend.WriteFile(file.Path, file.Data)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyOrder(file File) int {
+	isMetadata := strings.HasSuffix(file.Path, ".json")
+
+	switch {
+	case !file.Deleted && !isMetadata:
+		return 0
+	case !file.Deleted:
+		return 1
+	case isMetadata:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func readFiles(backend vault.Backend) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	for _, dir := range replicatedDirs {
+		paths, err := backend.ListFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, filePath := range paths {
+			if !isReplicated(filePath) {
+				continue
+			}
+
+			data, err := backend.ReadFile(filePath)
+			if err != nil {
+				return nil, err
+			} else if data == nil {
+				// deleted in the meantime
+				continue
+			}
+
+			files[filePath] = data
+		}
+	}
+
+	return files, nil
+}
+
+func isReplicated(filePath string) bool {
+	if filePath == "" || path.Clean(filePath) != filePath || path.IsAbs(filePath) || strings.Contains(filePath, "..") {
+		return false
+	}
+
+	dir := path.Dir(filePath)
+	if dir == "." {
+		dir = ""
+	}
+
+	return slices.Contains(replicatedDirs, dir) && filePath != PromotedPath
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package replication
+
+import (
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBackend(t *testing.T) vault.Backend {
+	backend := vault.NewLocalStorageBackend(t.TempDir())
+	assert.NoError(t, backend.Init())
+
+	return backend
+}
+
+func TestChanges(t *testing.T) {
+	primary := newTestBackend(t)
+	replica := newTestBackend(t)
+
+	assert.NoError(t, primary.WriteFile(".identity", []byte("identity")))
+	assert.NoError(t, primary.WriteFile("item.json", []byte("metadata")))
+	assert.NoError(t, primary.WriteFile("item.age", []byte("value")))
+	assert.NoError(t, primary.WriteFile(".admins/keyslots.json", []byte("keyslots")))
+	assert.NoError(t, primary.WriteFile(".audit/log.jsonl", []byte("audit")))
+	assert.NoError(t, primary.WriteFile(PromotedPath, []byte("{}")))
+
+	known, err := Manifest(replica)
+	assert.NoError(t, err)
+
+	changes, err := Changes(primary, known)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 4)
+	assert.NoError(t, Apply(replica, changes))
+
+	data, err := replica.ReadFile(".admins/keyslots.json")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("keyslots"), data)
+
+	data, err = replica.ReadFile(".audit/log.jsonl")
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+
+	known, err = Manifest(replica)
+	assert.NoError(t, err)
+
+	changes, err = Changes(primary, known)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	_, err = primary.DeleteFile("item.json")
+	assert.NoError(t, err)
+	_, err = primary.DeleteFile("item.age")
+	assert.NoError(t, err)
+
+	changes, err = Changes(primary, known)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.NoError(t, Apply(replica, changes))
+
+	data, err = replica.ReadFile("item.json")
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+func TestApply_Rejected(t *testing.T) {
+	backend := newTestBackend(t)
+
+	err := Apply(backend, []File{{Path: "../outside.json", Checksum: checksum([]byte("x")), Data: []byte("x")}})
+	assert.ErrorContains(t, err, "refusing to replicate")
+
+	err = Apply(backend, []File{{Path: PromotedPath, Checksum: checksum([]byte("x")), Data: []byte("x")}})
+	assert.ErrorContains(t, err, "refusing to replicate")
+
+	err = Apply(backend, []File{{Path: "item.json", Checksum: checksum([]byte("x")), Data: []byte("y")}})
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
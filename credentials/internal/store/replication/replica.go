@@ -0,0 +1,261 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package replication
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/awnumar/memguard"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultInterval = 10 * time.Second
+
+	// item values are limited by the receive size of the primary, this
+	// leaves room for their encryption
+	maxFileSize = 64 << 20
+)
+
+type Status struct {
+	// the time the replicated state was taken from the primary
+	LastSyncAt time.Time
+	LastError  error
+}
+
+// Replica pulls the encrypted files of the vault from the primary store,
+// they're never decrypted in transit
+type Replica struct {
+	primary  string
+	interval time.Duration
+	backend  vault.Backend
+	token    *memguard.Enclave
+	conn     *grpc.ClientConn
+	onChange func()
+	lock     sync.Mutex
+	status   Status
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewReplica prepares the replication from the configured primary, onChange
+// is called after changed files were written
+func NewReplica(
+	config *store.ReplicationConfig,
+	token *memguard.Enclave,
+	backend vault.Backend,
+	prod bool,
+	onChange func(),
+) (*Replica, error) {
+	transportCredentials := insecure.NewCredentials()
+	if prod {
+		tlsConfig, err := clientTlsConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		transportCredentials = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.NewClient(
+		config.Primary,
+		grpc.WithTransportCredentials(transportCredentials),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxFileSize)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := DefaultInterval
+	if config.Interval != nil {
+		interval = config.Interval.Duration
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Replica{
+		primary:  config.Primary,
+		interval: interval,
+		backend:  backend,
+		token:    token,
+		conn:     conn,
+		onChange: onChange,
+		lock:     sync.Mutex{},
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func clientTlsConfig(config *store.ReplicationConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if config.CaFile != "" {
+		caBytes, err := os.ReadFile(config.CaFile)
+		if err != nil {
+			return nil, err
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caBytes) {
+			return nil, errors.New("no certificates found in " + config.CaFile)
+		}
+
+		tlsConfig.RootCAs = roots
+	}
+
+	if config.ClientCertFile != "" {
+		clientCert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	return tlsConfig, nil
+}
+
+func (r *Replica) Primary() string {
+	return r.primary
+}
+
+func (r *Replica) Status() Status {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.status
+}
+
+// Start pulls changes from the primary in the configured interval until
+// stopped
+func (r *Replica) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			r.pull()
+
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the replication, waiting for a running pull to finish
+func (r *Replica) Stop() {
+	r.cancel()
+	<-r.done
+
+	_ = r.conn.Close()
+}
+
+func (r *Replica) pull() {
+	startedAt := time.Now()
+
+	ctx, cancel := context.WithTimeout(r.ctx, max(r.interval, time.Minute))
+	defer cancel()
+
+	changed, err := r.Sync(ctx)
+
+	r.lock.Lock()
+	r.status.LastError = err
+	if err == nil {
+		r.status.LastSyncAt = startedAt
+	}
+	r.lock.Unlock()
+
+	if err != nil {
+		if r.ctx.Err() == nil {
+			log.Warn().Err(err).Str("primary", r.primary).Msg("failed to replicate from primary")
+		}
+
+		return
+	}
+
+	if changed > 0 {
+		log.Info().Int("files", changed).Str("primary", r.primary).Msg("replicated changes from primary")
+		r.onChange()
+	}
+}
+
+// Sync pulls the files which changed since the last time and returns their
+// number
+func (r *Replica) Sync(ctx context.Context) (int, error) {
+	manifest, err := Manifest(r.backend)
+	if err != nil {
+		return 0, err
+	}
+
+	token, err := r.token.Open()
+	if err != nil {
+		return 0, err
+	}
+
+	request := &proto.ReplicationRequest{Token: string(token.Bytes())}
+	token.Destroy()
+
+	for filePath, sum := range manifest {
+		request.Files = append(request.Files, &proto.ReplicatedFile{Path: filePath, Checksum: sum})
+	}
+
+	stream, err := proto.NewCredStoreClient(r.conn).Replicate(ctx, request)
+	if err != nil {
+		return 0, err
+	}
+
+	var files []File
+	for {
+		file, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+
+		files = append(files, File{
+			Path:     file.GetPath(),
+			Checksum: file.GetChecksum(),
+			Data:     file.GetData(),
+			Deleted:  file.GetDeleted(),
+		})
+	}
+
+	if err = Apply(r.backend, files); err != nil {
+		return 0, err
+	}
+
+	return len(files), nil
+}
@@ -0,0 +1,96 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package replication
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"github.com/awnumar/memguard"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const minTokenLength = 16
+
+// LoadToken reads the secret replicas authenticate with
+func LoadToken(path string) (*memguard.Enclave, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer memguard.WipeBytes(data)
+
+	token := bytes.TrimSpace(data)
+	if len(token) < minTokenLength {
+		return nil, errors.New("replication token is too short, use at least 16 characters")
+	}
+
+	return memguard.NewEnclave(token), nil
+}
+
+// VerifyToken compares the token in constant time
+func VerifyToken(expected *memguard.Enclave, token string) bool {
+	buffer, err := expected.Open()
+	if err != nil {
+		return false
+	}
+
+	defer buffer.Destroy()
+
+	return subtle.ConstantTimeCompare(buffer.Bytes(), []byte(token)) == 1
+}
+
+type ReplicaInfo struct {
+	Source     string
+	LastSeenAt time.Time
+}
+
+// Replicas keeps track of the replicas pulling from this store
+type Replicas struct {
+	lock sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewReplicas() *Replicas {
+	return &Replicas{lock: sync.Mutex{}, seen: make(map[string]time.Time)}
+}
+
+func (r *Replicas) Seen(source string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.seen[source] = time.Now()
+}
+
+func (r *Replicas) List() []ReplicaInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	result := make([]ReplicaInfo, 0, len(r.seen))
+	for source, lastSeenAt := range r.seen {
+		result = append(result, ReplicaInfo{Source: source, LastSeenAt: lastSeenAt})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Source < result[j].Source
+	})
+
+	return result
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"path"
+)
+
+// methods which only return public information and aren't worth recording
+var unauditedMethods = map[string]bool{
+	proto.CredStore_GetInfo_FullMethodName:      true,
+	proto.CredStore_GetAuthority_FullMethodName: true,
+	proto.CredStore_GetChallenge_FullMethodName: true,
+}
+
+// methods called periodically, only failures are recorded
+var failureAuditedMethods = map[string]bool{
+	proto.CredStore_Replicate_FullMethodName: true,
+}
+
+func recordAuditEntry(ctx context.Context, state *service.State, record *audit.Record, method string, err error) {
+	if err == nil && failureAuditedMethods[method] {
+		return
+	}
+
+	outcome := audit.OutcomeSuccess
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.Unauthenticated, codes.PermissionDenied, codes.ResourceExhausted:
+		outcome = audit.OutcomeDenied
+	default:
+		outcome = audit.OutcomeFailure
+	}
+
+	entry := record.Entry(path.Base(method), access.SourceAddress(ctx), outcome, err)
+	if _, err = state.AuditLog().Append(entry); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("method", method).Msg("failed to write audit log entry")
+	}
+}
+
+func unaryAuditInterceptor(state *service.State) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if unauditedMethods[info.FullMethod] || !isCredStoreMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, record := audit.NewContext(ctx)
+
+		resp, err := handler(ctx, req)
+		recordAuditEntry(ctx, state, record, info.FullMethod, err)
+
+		return resp, err
+	}
+}
+
+func streamAuditInterceptor(state *service.State) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if unauditedMethods[info.FullMethod] || !isCredStoreMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		wrapped := middleware.WrapServerStream(ss)
+
+		var record *audit.Record
+		wrapped.WrappedContext, record = audit.NewContext(ss.Context())
+
+		err := handler(srv, wrapped)
+		recordAuditEntry(wrapped.WrappedContext, state, record, info.FullMethod, err)
+
+		return err
+	}
+}
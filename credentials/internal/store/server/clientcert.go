@@ -0,0 +1,99 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/ca"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// methods which can be called without a client certificate, even if one is
+// required, so that hosts are able to enroll in the first place
+var certlessMethods = map[string]bool{
+	proto.CredStore_GetInfo_FullMethodName:       true,
+	proto.CredStore_GetAuthority_FullMethodName:  true,
+	proto.CredStore_EnrollClient_FullMethodName:  true,
+	proto.CredStore_GetEnrollment_FullMethodName: true,
+}
+
+func peerCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return tlsInfo.State.PeerCertificates[0]
+}
+
+// requireClientCert verifies the client certificate on every call, as the
+// authority may have changed since the handshake, e.g. when it was
+// authenticated by unlocking the vault or a certificate was revoked
+func requireClientCert(ctx context.Context, authority *ca.Authority, method string) error {
+	if certlessMethods[method] || healthMethods[method] {
+		return nil
+	}
+
+	if cert := peerCertificate(ctx); cert != nil {
+		if err := authority.Verify(cert); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("method", method).Msg("rejecting call with invalid client certificate")
+			return status.Error(codes.Unauthenticated, "invalid client certificate")
+		}
+
+		return nil
+	}
+
+	// peers on the Unix socket are authenticated by the kernel instead
+	if access.UnixPeerFromContext(ctx) != nil {
+		return nil
+	}
+
+	log.Ctx(ctx).Warn().Str("method", method).Msg("rejecting call without client certificate")
+	return status.Error(codes.Unauthenticated, "client certificate required")
+}
+
+func unaryClientCertInterceptor(authority *ca.Authority) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := requireClientCert(ctx, authority, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func streamClientCertInterceptor(authority *ca.Authority) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := requireClientCert(ss.Context(), authority, info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
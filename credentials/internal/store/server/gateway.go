@@ -0,0 +1,499 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/metrics"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	protobuf "google.golang.org/protobuf/proto"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// gatewayRoute maps a CredStore method onto an HTTP endpoint. The request
+// message is sent as JSON body, streamed replies are returned as JSON array,
+// except for live streams.
+type gatewayRoute struct {
+	httpMethod string
+	path       string
+	method     string
+}
+
+var gatewayRoutes = []gatewayRoute{
+	{http.MethodGet, "/v1/info", "GetInfo"},
+	{http.MethodPost, "/v1/status", "GetStatus"},
+	{http.MethodPost, "/v1/vault:initialize", "InitializeVault"},
+	{http.MethodPost, "/v1/vault:unlock", "UnlockVault"},
+	{http.MethodPost, "/v1/vault:lock", "LockVault"},
+	{http.MethodPut, "/v1/vault/recovery-recipient", "SetRecoveryRecipient"},
+	{http.MethodPost, "/v1/session", "Login"},
+	{http.MethodDelete, "/v1/session", "Logout"},
+	{http.MethodPost, "/v1/admins", "AddAdmin"},
+	{http.MethodPost, "/v1/admins:remove", "RemoveAdmin"},
+	{http.MethodPost, "/v1/admins:search", "ListAdmins"},
+	{http.MethodPost, "/v1/items", "CreateVaultItem"},
+	{http.MethodPost, "/v1/items:generate", "GenerateVaultItem"},
+	{http.MethodPost, "/v1/items:search", "ListVaultItems"},
+	{http.MethodPost, "/v1/items:delete", "DeleteVaultItems"},
+	{http.MethodPost, "/v1/items:read", "ReadVaultItem"},
+	{http.MethodPost, "/v1/items:batchRead", "ReadVaultItems"},
+	{http.MethodPost, "/v1/items:share", "ShareVaultItem"},
+	{http.MethodPost, "/v1/items:setApproval", "SetItemApproval"},
+	{http.MethodPost, "/v1/vault:watch", "WatchVault"},
+	{http.MethodPost, "/v1/approvals:search", "ListApprovals"},
+	{http.MethodPost, "/v1/approvals:decide", "DecideApproval"},
+	{http.MethodPost, "/v1/clients", "CreateClientCredentials"},
+	{http.MethodPost, "/v1/clients:challenge", "GetChallenge"},
+	{http.MethodPost, "/v1/audit:query", "QueryAuditLog"},
+	{http.MethodPost, "/v1/audit:verify", "VerifyAuditLog"},
+	{http.MethodGet, "/v1/authority", "GetAuthority"},
+	{http.MethodPost, "/v1/authority", "InitAuthority"},
+	{http.MethodPost, "/v1/enrollments/tokens", "CreateEnrollmentToken"},
+	{http.MethodPost, "/v1/enrollments", "EnrollClient"},
+	{http.MethodPost, "/v1/enrollments:get", "GetEnrollment"},
+	{http.MethodPost, "/v1/enrollments:search", "ListEnrollments"},
+	{http.MethodPost, "/v1/enrollments:decide", "DecideEnrollment"},
+	{http.MethodPost, "/v1/enrollments:revoke", "RevokeCertificate"},
+	{http.MethodPost, "/v1/enrollments:renew", "RenewCertificate"},
+	{http.MethodPost, "/v1/replication:pull", "Replicate"},
+	{http.MethodPost, "/v1/replication:promote", "PromoteReplica"},
+}
+
+// gatewayLiveStreams don't end by themselves, their replies are sent as
+// newline delimited JSON as soon as they're available
+var gatewayLiveStreams = []string{"WatchVault"}
+
+// decoding errors quote the offending value, which may well be a secret
+var gatewayDecodeValue = regexp.MustCompile(`(invalid value for [^:]+): .*`)
+
+const (
+	gatewayOpenApiPath = "/openapi.json"
+	gatewayNdjsonType  = "application/x-ndjson"
+)
+
+var (
+	gatewayMarshalOptions   = protojson.MarshalOptions{EmitUnpopulated: true}
+	gatewayUnmarshalOptions = protojson.UnmarshalOptions{}
+)
+
+// gateway calls the CredStore service on behalf of HTTP requests, passing
+// them through the same interceptors as calls made via gRPC
+type gateway struct {
+	server      credStoreServer
+	unary       grpc.UnaryServerInterceptor
+	stream      grpc.StreamServerInterceptor
+	maxBodySize int64
+}
+
+func newGatewayHandler(state *service.State, m *metrics.Metrics) (http.Handler, error) {
+	unaryInterceptors, streamInterceptors := serverInterceptors(state, m)
+
+	maxBodySize := int64(defaultMaxReceiveSize)
+	if transport := state.Config().Transport; transport != nil && transport.MaxReceiveSize != nil {
+		maxBodySize = int64(*transport.MaxReceiveSize)
+	}
+
+	g := &gateway{
+		server:      newCredStoreServer(state),
+		unary:       chainUnaryInterceptors(unaryInterceptors),
+		stream:      chainStreamInterceptors(streamInterceptors),
+		maxBodySize: maxBodySize,
+	}
+
+	openApi, err := GatewayOpenApi(state.StoreInfo().GetVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(http.MethodGet+" "+gatewayOpenApiPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openApi)
+	})
+
+	for _, route := range gatewayRoutes {
+		handler, err := g.handler(route.method)
+		if err != nil {
+			return nil, err
+		}
+
+		mux.HandleFunc(route.httpMethod+" "+route.path, handler)
+	}
+
+	return mux, nil
+}
+
+func (g *gateway) handler(method string) (http.HandlerFunc, error) {
+	fullMethod := "/" + proto.CredStore_ServiceDesc.ServiceName + "/" + method
+
+	for _, desc := range proto.CredStore_ServiceDesc.Methods {
+		if desc.MethodName == method {
+			return g.unaryHandler(fullMethod, desc), nil
+		}
+	}
+
+	for _, desc := range proto.CredStore_ServiceDesc.Streams {
+		if desc.StreamName == method {
+			return g.streamHandler(fullMethod, desc, slices.Contains(gatewayLiveStreams, method)), nil
+		}
+	}
+
+	return nil, errors.New("unknown method for gateway route: " + method)
+}
+
+func (g *gateway) unaryHandler(fullMethod string, desc grpc.MethodDesc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := g.readBody(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, transport := gatewayContext(r, fullMethod)
+
+		reply, err := desc.Handler(g.server, ctx, func(m any) error { return decodeGatewayRequest(body, m) }, g.unary)
+
+		transport.writeHeaders(w)
+
+		if err != nil {
+			writeGatewayError(w, err)
+			return
+		}
+
+		data, err := gatewayMarshalOptions.Marshal(reply.(protobuf.Message))
+		if err != nil {
+			writeGatewayError(w, err)
+			return
+		}
+
+		writeGatewayJson(w, http.StatusOK, data)
+	}
+}
+
+func (g *gateway) streamHandler(fullMethod string, desc grpc.StreamDesc, live bool) http.HandlerFunc {
+	info := &grpc.StreamServerInfo{
+		FullMethod:     fullMethod,
+		IsClientStream: desc.ClientStreams,
+		IsServerStream: desc.ServerStreams,
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := g.readBody(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, transport := gatewayContext(r, fullMethod)
+		stream := &gatewayStream{ctx: ctx, transport: transport, body: body}
+		if live {
+			stream.live = w
+		}
+
+		err := g.stream(g.server, stream, info, desc.Handler)
+
+		if stream.started {
+			// the status was sent along with the first reply already
+			if err != nil {
+				writeGatewayLiveError(w, err)
+			}
+
+			return
+		}
+
+		transport.writeHeaders(w)
+
+		if err != nil {
+			writeGatewayError(w, err)
+			return
+		}
+
+		if live {
+			w.Header().Set("Content-Type", gatewayNdjsonType)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		writeGatewayJson(w, http.StatusOK, []byte("["+strings.Join(stream.replies, ",")+"]"))
+	}
+}
+
+func (g *gateway) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, g.maxBodySize))
+	if err != nil {
+		writeGatewayError(w, status.Errorf(codes.InvalidArgument, "failed to read request: %v", err))
+		return nil, false
+	}
+
+	return body, true
+}
+
+func decodeGatewayRequest(body []byte, m any) error {
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+
+	if err := gatewayUnmarshalOptions.Unmarshal(body, m.(protobuf.Message)); err != nil {
+		message := gatewayDecodeValue.ReplaceAllString(err.Error(), "$1")
+		return status.Errorf(codes.InvalidArgument, "invalid request: %s", message)
+	}
+
+	return nil
+}
+
+// gatewayContext carries what's known about the HTTP client the same way
+// the gRPC transport does, so the interceptors treat both alike
+func gatewayContext(r *http.Request, fullMethod string) (context.Context, *gatewayTransportStream) {
+	p := &peer.Peer{
+		AuthInfo: plainInfo{credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}},
+	}
+
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		p.Addr = addr
+	}
+
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{
+			State:          *r.TLS,
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		}
+	}
+
+	md := metadata.MD{}
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		md.Set("authorization", authorization)
+	}
+
+	if requestId := r.Header.Get(requestIdKey); requestId != "" {
+		md.Set(requestIdKey, requestId)
+	}
+
+	transport := &gatewayTransportStream{method: fullMethod, header: metadata.MD{}}
+
+	ctx := peer.NewContext(r.Context(), p)
+	ctx = metadata.NewIncomingContext(ctx, md)
+	ctx = grpc.NewContextWithServerTransportStream(ctx, transport)
+
+	return ctx, transport
+}
+
+// gatewayTransportStream collects the metadata set by the calls, which is
+// returned as HTTP headers
+type gatewayTransportStream struct {
+	method string
+	header metadata.MD
+}
+
+func (t *gatewayTransportStream) Method() string {
+	return t.method
+}
+
+func (t *gatewayTransportStream) SetHeader(md metadata.MD) error {
+	t.header = metadata.Join(t.header, md)
+	return nil
+}
+
+func (t *gatewayTransportStream) SendHeader(md metadata.MD) error {
+	return t.SetHeader(md)
+}
+
+func (t *gatewayTransportStream) SetTrailer(md metadata.MD) error {
+	return t.SetHeader(md)
+}
+
+func (t *gatewayTransportStream) writeHeaders(w http.ResponseWriter) {
+	for key, values := range t.header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+}
+
+// gatewayStream hands the request to a streaming call and collects the
+// replies, or writes them right away for live streams
+type gatewayStream struct {
+	ctx       context.Context
+	transport *gatewayTransportStream
+	body      []byte
+	received  bool
+	replies   []string
+	live      http.ResponseWriter
+	started   bool
+}
+
+func (s *gatewayStream) SetHeader(md metadata.MD) error {
+	return s.transport.SetHeader(md)
+}
+
+func (s *gatewayStream) SendHeader(md metadata.MD) error {
+	return s.transport.SendHeader(md)
+}
+
+func (s *gatewayStream) SetTrailer(md metadata.MD) {
+	_ = s.transport.SetTrailer(md)
+}
+
+func (s *gatewayStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *gatewayStream) SendMsg(m any) error {
+	data, err := gatewayMarshalOptions.Marshal(m.(protobuf.Message))
+	if err != nil {
+		return err
+	}
+
+	if s.live == nil {
+		s.replies = append(s.replies, string(data))
+		return nil
+	}
+
+	if !s.started {
+		s.started = true
+		s.transport.writeHeaders(s.live)
+		s.live.Header().Set("Content-Type", gatewayNdjsonType)
+		s.live.WriteHeader(http.StatusOK)
+	}
+
+	if _, err = s.live.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	return http.NewResponseController(s.live).Flush()
+}
+
+func (s *gatewayStream) RecvMsg(m any) error {
+	if s.received {
+		return io.EOF
+	}
+
+	s.received = true
+
+	return decodeGatewayRequest(s.body, m)
+}
+
+type gatewayError struct {
+	Code    codes.Code `json:"code"`
+	Status  string     `json:"status"`
+	Message string     `json:"message"`
+}
+
+func writeGatewayError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+
+	data, err := json.Marshal(gatewayError{Code: st.Code(), Status: st.Code().String(), Message: st.Message()})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode gateway error")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeGatewayJson(w, httpStatus(st.Code()), data)
+}
+
+// writeGatewayLiveError ends a live stream with an error line, its status
+// was sent already
+func writeGatewayLiveError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+
+	data, err := json.Marshal(map[string]gatewayError{
+		"error": {Code: st.Code(), Status: st.Code().String(), Message: st.Message()},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode gateway error")
+		return
+	}
+
+	_, _ = w.Write(append(data, '\n'))
+}
+
+func writeGatewayJson(w http.ResponseWriter, statusCode int, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(data)
+}
+
+// httpStatus maps status codes the same way as grpc-gateway
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+
+		return next(ctx, req)
+	}
+}
+
+func chainStreamInterceptors(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(srv any, stream grpc.ServerStream) error {
+				return interceptor(srv, stream, info, inner)
+			}
+		}
+
+		return next(srv, stream)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+)
+
+func TestGatewayRoutes_CoverService(t *testing.T) {
+	routed := make(map[string]bool)
+	for _, route := range gatewayRoutes {
+		assert.False(t, routed[route.method], "method routed twice: %s", route.method)
+		routed[route.method] = true
+	}
+
+	for _, method := range proto.CredStore_ServiceDesc.Methods {
+		assert.True(t, routed[method.MethodName], "method not routed: %s", method.MethodName)
+	}
+
+	for _, stream := range proto.CredStore_ServiceDesc.Streams {
+		assert.True(t, routed[stream.StreamName], "stream not routed: %s", stream.StreamName)
+	}
+
+	g := &gateway{}
+	for _, route := range gatewayRoutes {
+		_, err := g.handler(route.method)
+		assert.NoError(t, err)
+	}
+}
+
+func TestGatewayOpenApi(t *testing.T) {
+	document, err := GatewayOpenApi("1.2.3")
+	assert.NoError(t, err)
+
+	var parsed struct {
+		Info struct {
+			Version string
+		}
+		Paths      map[string]map[string]map[string]any
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any
+			}
+		}
+	}
+
+	assert.NoError(t, json.Unmarshal(document, &parsed))
+	assert.Equal(t, "1.2.3", parsed.Info.Version)
+	assert.Equal(t, "ListVaultItems", parsed.Paths["/v1/items:search"]["post"]["operationId"])
+	assert.NotContains(t, parsed.Paths["/v1/info"]["get"], "requestBody")
+
+	item := parsed.Components.Schemas["ItemCreation"]
+	assert.Equal(t, "#/components/schemas/AdminCredentials", item.Properties["credentials"]["$ref"])
+	assert.Equal(t, "byte", item.Properties["value"]["format"])
+	assert.Equal(t, "int64", item.Properties["ttlSeconds"]["format"])
+}
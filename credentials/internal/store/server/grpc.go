@@ -17,16 +17,27 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/challenge"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/metrics"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/redact"
 	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/watch"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	protobuf "google.golang.org/protobuf/proto"
+	"strconv"
 )
 
 type credStoreServer struct {
@@ -38,58 +49,136 @@ func (serv credStoreServer) GetInfo(_ context.Context, _ *proto.Unit) (*proto.St
 	return serv.state.StoreInfo(), nil
 }
 
-func (serv credStoreServer) UnlockVault(_ context.Context, credentials *proto.AdminCredentials) (*proto.Unit, error) {
-	if err := serv.state.Unlock(credentials); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+func (serv credStoreServer) InitializeVault(ctx context.Context, initialization *proto.VaultInitialization) (*proto.VaultInfo, error) {
+	info, err := serv.state.InitializeVault(ctx, initialization)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return info, nil
+}
+
+func (serv credStoreServer) UnlockVault(ctx context.Context, credentials *proto.AdminCredentials) (*proto.Unit, error) {
+	if err := serv.state.Unlock(ctx, credentials); err != nil {
+		return nil, statusError(err)
 	}
 
 	return &proto.Unit{}, nil
 }
 
-func (serv credStoreServer) LockVault(_ context.Context, _ *proto.Unit) (*proto.Unit, error) {
+func (serv credStoreServer) LockVault(ctx context.Context, _ *proto.Unit) (*proto.Unit, error) {
 	ok := serv.state.Lock()
 	if !ok {
-		log.Debug().Msg("failed to lock vault")
+		log.Ctx(ctx).Debug().Msg("failed to lock vault")
+	}
+
+	return &proto.Unit{}, nil
+}
+
+func (serv credStoreServer) Login(ctx context.Context, request *proto.LoginRequest) (*proto.Session, error) {
+	session, err := serv.state.Login(ctx, request)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return session, nil
+}
+
+func (serv credStoreServer) Logout(ctx context.Context, _ *proto.Unit) (*proto.Unit, error) {
+	if err := serv.state.Logout(ctx); err != nil {
+		return nil, statusError(err)
 	}
 
 	return &proto.Unit{}, nil
 }
 
-func (serv credStoreServer) SetRecoveryRecipient(_ context.Context, recipient *proto.RecoveryRecipient) (*proto.Unit, error) {
-	if err := serv.state.SetRecoveryRecipient(recipient); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+func (serv credStoreServer) AddAdmin(ctx context.Context, creation *proto.AdminCreation) (*proto.AdminInfo, error) {
+	admin, err := serv.state.AddAdmin(ctx, creation)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return admin, nil
+}
+
+func (serv credStoreServer) RemoveAdmin(ctx context.Context, removal *proto.AdminRemoval) (*proto.Unit, error) {
+	if err := serv.state.RemoveAdmin(ctx, removal); err != nil {
+		return nil, statusError(err)
 	}
 
 	return &proto.Unit{}, nil
 }
 
-func (serv credStoreServer) CreateVaultItem(_ context.Context, creation *proto.ItemCreation) (*proto.Item, error) {
-	item, err := serv.state.CreateVaultItem(creation)
+func (serv credStoreServer) ListAdmins(search *proto.AdminSearch, adminStream grpc.ServerStreamingServer[proto.AdminInfo]) error {
+	admins, err := serv.state.ListAdmins(adminStream.Context(), search)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return statusError(err)
+	}
+
+	for _, admin := range admins {
+		if err = adminStream.Send(admin); err != nil {
+			return statusError(err)
+		}
+	}
+
+	return nil
+}
+
+func (serv credStoreServer) SetRecoveryRecipient(ctx context.Context, recipient *proto.RecoveryRecipient) (*proto.Unit, error) {
+	if err := serv.state.SetRecoveryRecipient(ctx, recipient); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &proto.Unit{}, nil
+}
+
+func (serv credStoreServer) CreateVaultItem(ctx context.Context, creation *proto.ItemCreation) (*proto.Item, error) {
+	item, err := serv.state.CreateVaultItem(ctx, creation)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return item, nil
+}
+
+func (serv credStoreServer) GenerateVaultItem(ctx context.Context, generation *proto.ItemGeneration) (*proto.GeneratedItem, error) {
+	item, err := serv.state.GenerateVaultItem(ctx, generation)
+	if err != nil {
+		return nil, statusError(err)
 	}
 
 	return item, nil
 }
 
+// the response headers of ListVaultItems
+const (
+	totalCountKey    = "x-total-count"
+	matchedCountKey  = "x-matched-count"
+	nextPageTokenKey = "x-next-page-token"
+)
+
 func (serv credStoreServer) ListVaultItems(search *proto.ItemSearch, itemStream grpc.ServerStreamingServer[proto.Item]) error {
-	items, err := serv.state.ListVaultItems(search)
+	page, err := serv.state.ListVaultItems(itemStream.Context(), search)
 	if err != nil {
-		return status.Error(codes.Internal, err.Error())
+		return statusError(err)
 	}
 
-	for _, item := range items {
-		err = itemStream.Send(
-			&proto.Item{
-				Id:          item.Id.String(),
-				Description: item.Description,
-				Checksum:    item.Checksum,
-				CreatedAt:   item.ModifiedAt.UnixMilli(),
-			},
-		)
+	header := metadata.Pairs(
+		totalCountKey, strconv.Itoa(page.Total),
+		matchedCountKey, strconv.Itoa(page.Matched),
+	)
 
-		if err != nil {
-			return status.Error(codes.Internal, err.Error())
+	if page.NextPageToken != "" {
+		header.Set(nextPageTokenKey, page.NextPageToken)
+	}
+
+	if err = itemStream.SetHeader(header); err != nil {
+		return statusError(err)
+	}
+
+	for _, item := range page.Items {
+		if err = itemStream.Send(item); err != nil {
+			return statusError(err)
 		}
 	}
 
@@ -97,9 +186,9 @@ func (serv credStoreServer) ListVaultItems(search *proto.ItemSearch, itemStream
 }
 
 func (serv credStoreServer) DeleteVaultItems(deletion *proto.ItemDeletion, itemStream grpc.ServerStreamingServer[proto.Item]) error {
-	deletedIds, err := serv.state.DeleteVaultItems(deletion)
+	deletedIds, err := serv.state.DeleteVaultItems(itemStream.Context(), deletion)
 	if err != nil {
-		return status.Error(codes.Internal, err.Error())
+		return statusError(err)
 	}
 
 	for _, id := range deletedIds {
@@ -108,55 +197,340 @@ func (serv credStoreServer) DeleteVaultItems(deletion *proto.ItemDeletion, itemS
 		})
 
 		if err != nil {
-			return status.Error(codes.Internal, err.Error())
+			return statusError(err)
 		}
 	}
 
 	return nil
 }
 
-func (serv credStoreServer) ReadVaultItem(_ context.Context, request *proto.ItemRequest) (*proto.ItemValue, error) {
-	itemValue, err := serv.state.ReadVaultItem(request)
+func (serv credStoreServer) ReadVaultItem(ctx context.Context, request *proto.ItemRequest) (*proto.ItemValue, error) {
+	itemValue, err := serv.state.ReadVaultItem(ctx, request)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, statusError(err)
 	}
 
 	return itemValue, nil
 }
 
-func (serv credStoreServer) CreateClientCredentials(_ context.Context, creation *proto.ClientCreation) (*proto.ClientCredentials, error) {
-	credentials, err := serv.state.CreateClientCredentials(creation)
+func (serv credStoreServer) ReadVaultItems(request *proto.ItemsRequest, resultStream grpc.ServerStreamingServer[proto.ItemResult]) error {
+	err := serv.state.ReadVaultItems(resultStream.Context(), request, resultStream.Send)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return statusError(err)
+	}
+
+	return nil
+}
+
+func (serv credStoreServer) ShareVaultItem(ctx context.Context, share *proto.ItemShare) (*proto.SharedItem, error) {
+	shared, err := serv.state.ShareVaultItem(ctx, share)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return shared, nil
+}
+
+func (serv credStoreServer) WatchVault(request *proto.WatchRequest, eventStream grpc.ServerStreamingServer[proto.WatchEvent]) error {
+	err := serv.state.WatchVault(eventStream.Context(), request, eventStream.Send)
+	if err != nil {
+		return statusError(err)
+	}
+
+	return nil
+}
+
+func (serv credStoreServer) Replicate(request *proto.ReplicationRequest, fileStream grpc.ServerStreamingServer[proto.ReplicatedFile]) error {
+	err := serv.state.Replicate(fileStream.Context(), request, fileStream.Send)
+	if err != nil {
+		return statusError(err)
+	}
+
+	return nil
+}
+
+func (serv credStoreServer) GetStatus(ctx context.Context, credentials *proto.AdminCredentials) (*proto.StoreStatus, error) {
+	storeStatus, err := serv.state.GetStatus(ctx, credentials)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return storeStatus, nil
+}
+
+func (serv credStoreServer) PromoteReplica(ctx context.Context, credentials *proto.AdminCredentials) (*proto.StoreInfo, error) {
+	info, err := serv.state.PromoteReplica(ctx, credentials)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return info, nil
+}
+
+func (serv credStoreServer) SetItemApproval(ctx context.Context, setting *proto.ItemApprovalSetting) (*proto.Item, error) {
+	item, err := serv.state.SetItemApproval(ctx, setting)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return item, nil
+}
+
+func (serv credStoreServer) ListApprovals(search *proto.ApprovalSearch, approvalStream grpc.ServerStreamingServer[proto.ApprovalRequest]) error {
+	requests, err := serv.state.ListApprovals(approvalStream.Context(), search)
+	if err != nil {
+		return statusError(err)
+	}
+
+	for _, request := range requests {
+		if err = approvalStream.Send(request); err != nil {
+			return statusError(err)
+		}
+	}
+
+	return nil
+}
+
+func (serv credStoreServer) DecideApproval(ctx context.Context, decision *proto.ApprovalDecision) (*proto.ApprovalRequest, error) {
+	request, err := serv.state.DecideApproval(ctx, decision)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return request, nil
+}
+
+func (serv credStoreServer) CreateClientCredentials(ctx context.Context, creation *proto.ClientCreation) (*proto.ClientCredentials, error) {
+	credentials, err := serv.state.CreateClientCredentials(ctx, creation)
+	if err != nil {
+		return nil, statusError(err)
 	}
 
 	return credentials, nil
 }
 
-func NewGrpcServer(state *service.State) *grpc.Server {
+func (serv credStoreServer) GetChallenge(ctx context.Context, request *proto.ChallengeRequest) (*proto.Challenge, error) {
+	challenge, err := serv.state.GetChallenge(ctx, request)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return challenge, nil
+}
+
+func (serv credStoreServer) QueryAuditLog(query *proto.AuditQuery, entryStream grpc.ServerStreamingServer[proto.AuditEntry]) error {
+	entries, err := serv.state.QueryAuditLog(entryStream.Context(), query)
+	if err != nil {
+		return statusError(err)
+	}
+
+	for _, entry := range entries {
+		if err = entryStream.Send(entry); err != nil {
+			return statusError(err)
+		}
+	}
+
+	return nil
+}
+
+func (serv credStoreServer) VerifyAuditLog(ctx context.Context, request *proto.AuditVerificationRequest) (*proto.AuditVerification, error) {
+	verification, err := serv.state.VerifyAuditLog(ctx, request)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return verification, nil
+}
+
+func (serv credStoreServer) InitAuthority(ctx context.Context, creation *proto.AuthorityCreation) (*proto.AuthorityInfo, error) {
+	info, err := serv.state.InitAuthority(ctx, creation)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return info, nil
+}
+
+func (serv credStoreServer) GetAuthority(_ context.Context, _ *proto.Unit) (*proto.AuthorityInfo, error) {
+	return serv.state.AuthorityInfo(), nil
+}
+
+func (serv credStoreServer) CreateEnrollmentToken(ctx context.Context, creation *proto.EnrollmentTokenCreation) (*proto.EnrollmentToken, error) {
+	token, err := serv.state.CreateEnrollmentToken(ctx, creation)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return token, nil
+}
+
+func (serv credStoreServer) EnrollClient(ctx context.Context, request *proto.EnrollmentRequest) (*proto.Enrollment, error) {
+	enrollment, err := serv.state.EnrollClient(ctx, request)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return enrollment, nil
+}
+
+func (serv credStoreServer) GetEnrollment(_ context.Context, query *proto.EnrollmentQuery) (*proto.Enrollment, error) {
+	enrollment, err := serv.state.GetEnrollment(query)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return enrollment, nil
+}
+
+func (serv credStoreServer) ListEnrollments(search *proto.EnrollmentSearch, enrollmentStream grpc.ServerStreamingServer[proto.Enrollment]) error {
+	enrollments, err := serv.state.ListEnrollments(enrollmentStream.Context(), search)
+	if err != nil {
+		return statusError(err)
+	}
+
+	for _, enrollment := range enrollments {
+		if err = enrollmentStream.Send(enrollment); err != nil {
+			return statusError(err)
+		}
+	}
+
+	return nil
+}
+
+func (serv credStoreServer) DecideEnrollment(ctx context.Context, decision *proto.EnrollmentDecision) (*proto.Enrollment, error) {
+	enrollment, err := serv.state.DecideEnrollment(ctx, decision)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return enrollment, nil
+}
+
+func (serv credStoreServer) RevokeCertificate(ctx context.Context, revocation *proto.CertificateRevocation) (*proto.Enrollment, error) {
+	enrollment, err := serv.state.RevokeCertificate(ctx, revocation)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return enrollment, nil
+}
+
+func (serv credStoreServer) RenewCertificate(ctx context.Context, request *proto.RenewalRequest) (*proto.Enrollment, error) {
+	enrollment, err := serv.state.RenewCertificate(peerCertificate(ctx), request)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return enrollment, nil
+}
+
+func NewGrpcServer(state *service.State, m *metrics.Metrics) *grpc.Server {
+	unaryInterceptors, streamInterceptors := serverInterceptors(state, m)
+
+	options := []grpc.ServerOption{
+		grpc.Creds(transportCredentials{}),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+
+	grpcServer := grpc.NewServer(append(options, transportOptions(state.Config().Transport)...)...)
+
+	proto.RegisterCredStoreServer(grpcServer, newCredStoreServer(state))
+
+	registerHealthServer(grpcServer, state)
+
+	if state.Config().Reflection {
+		reflection.Register(grpcServer)
+	}
+
+	return grpcServer
+}
+
+func newCredStoreServer(state *service.State) credStoreServer {
+	return credStoreServer{
+		UnimplementedCredStoreServer: proto.UnimplementedCredStoreServer{},
+		state:                        state,
+	}
+}
+
+// serverInterceptors returns the interceptors applied to all calls, in the
+// order they're run
+func serverInterceptors(state *service.State, m *metrics.Metrics) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
 	logger := log.Logger
 
 	var loggingOpts []logging.Option
-	loggingOpts = append(loggingOpts, logging.WithLogOnEvents(logging.StartCall, logging.FinishCall))
+	loggingOpts = append(loggingOpts, logging.WithLogOnEvents(logging.StartCall, logging.FinishCall, logging.PayloadReceived, logging.PayloadSent))
 
-	grpcServer := grpc.NewServer(
-		grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor(interceptorLogger(logger), loggingOpts...)),
-		grpc.ChainStreamInterceptor(logging.StreamServerInterceptor(interceptorLogger(logger), loggingOpts...)),
-	)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		unaryRequestIdInterceptor,
+		logging.UnaryServerInterceptor(interceptorLogger(logger), loggingOpts...),
+	}
 
-	proto.RegisterCredStoreServer(
-		grpcServer,
-		credStoreServer{
-			UnimplementedCredStoreServer: proto.UnimplementedCredStoreServer{},
-			state:                        state,
-		},
-	)
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		streamRequestIdInterceptor,
+		logging.StreamServerInterceptor(interceptorLogger(logger), loggingOpts...),
+	}
 
-	return grpcServer
+	if m != nil {
+		unaryInterceptors = append(unaryInterceptors, m.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, m.StreamServerInterceptor())
+	}
+
+	unaryInterceptors = append(unaryInterceptors, unaryAuditInterceptor(state), unaryUnixPeerInterceptor, unaryRateLimitInterceptor(state))
+	streamInterceptors = append(streamInterceptors, streamAuditInterceptor(state), streamUnixPeerInterceptor, streamRateLimitInterceptor(state))
+
+	if tlsConfig := state.Config().Tls; state.IsProduction() && tlsConfig != nil && tlsConfig.RequireClientCert {
+		unaryInterceptors = append(unaryInterceptors, unaryClientCertInterceptor(state.Authority()))
+		streamInterceptors = append(streamInterceptors, streamClientCertInterceptor(state.Authority()))
+	}
+
+	// errors are scrubbed right away, so none of the interceptors above see
+	// secrets
+	unaryInterceptors = append(unaryInterceptors, unaryRedactInterceptor)
+	streamInterceptors = append(streamInterceptors, streamRedactInterceptor)
+
+	return unaryInterceptors, streamInterceptors
+}
+
+func statusError(err error) error {
+	var authErr *service.AuthenticationError
+	if errors.As(err, &authErr) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	var permErr *service.PermissionError
+	if errors.As(err, &permErr) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	var replicaErr *service.ReplicaError
+	if errors.As(err, &replicaErr) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if errors.Is(err, vault.ErrNotInitialized) || errors.Is(err, vault.ErrInitialized) || errors.Is(err, vault.ErrIncompatible) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if errors.Is(err, watch.ErrOverflow) || errors.Is(err, challenge.ErrTooManyChallenges) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
 }
 
 func interceptorLogger(l zerolog.Logger) logging.Logger {
 	return logging.LoggerFunc(func(ctx context.Context, level logging.Level, msg string, fields ...any) {
+		if hasPayload(fields) {
+			// payloads are only logged when tracing, without their secrets
+			if zerolog.GlobalLevel() > zerolog.TraceLevel {
+				return
+			}
+
+			fields = redactPayloads(fields)
+			level = logging.LevelDebug
+		}
+
 		l := l.With().Fields(fields).Logger()
 
 		switch level {
@@ -173,3 +547,31 @@ func interceptorLogger(l zerolog.Logger) logging.Logger {
 		}
 	})
 }
+
+func hasPayload(fields []any) bool {
+	for _, field := range fields {
+		if _, ok := field.(protobuf.Message); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func redactPayloads(fields []any) []any {
+	redacted := make([]any, len(fields))
+	for i, field := range fields {
+		redacted[i] = field
+
+		if m, ok := field.(protobuf.Message); ok {
+			data, err := protojson.Marshal(redact.Message(m))
+			if err != nil {
+				redacted[i] = redact.Placeholder
+			} else {
+				redacted[i] = json.RawMessage(data)
+			}
+		}
+	}
+
+	return redacted
+}
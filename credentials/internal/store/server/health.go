@@ -0,0 +1,60 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"strings"
+)
+
+// ClientReadService is the health service name reporting whether clients
+// are able to read items, which isn't the case while the vault is locked
+const ClientReadService = "credstore.ClientRead"
+
+// methods of the health service, which monitoring calls without any
+// credentials
+var healthMethods = map[string]bool{
+	healthpb.Health_Check_FullMethodName: true,
+	healthpb.Health_Watch_FullMethodName: true,
+}
+
+func registerHealthServer(grpcServer *grpc.Server, state *service.State) {
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(proto.CredStore_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	updateClientRead := func(locked bool) {
+		status := healthpb.HealthCheckResponse_SERVING
+		if locked {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		healthServer.SetServingStatus(ClientReadService, status)
+	}
+
+	updateClientRead(state.IsLocked())
+	state.OnLockChange(updateClientRead)
+
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+}
+
+func isCredStoreMethod(method string) bool {
+	return strings.HasPrefix(method, "/"+proto.CredStore_ServiceDesc.ServiceName+"/")
+}
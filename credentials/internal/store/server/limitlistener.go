@@ -0,0 +1,119 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"github.com/rs/zerolog/log"
+	"net"
+	"sync"
+)
+
+// limitListener rejects connections exceeding the maximum number of open
+// connections, in total or from a single source address
+type limitListener struct {
+	net.Listener
+	maxConnections int
+	maxPerSource   int
+
+	lock      sync.Mutex
+	open      int
+	perSource map[string]int
+}
+
+type limitedConn struct {
+	net.Conn
+	release sync.Once
+	owner   *limitListener
+	source  string
+}
+
+// NewLimitListener limits the connections handed out by the listener, a
+// maximum of 0 means unlimited. It has to wrap the listener accepting the
+// TCP connections, so the connections can be tracked until they're closed.
+func NewLimitListener(l net.Listener, maxConnections int, maxPerSource int) net.Listener {
+	if maxConnections <= 0 && maxPerSource <= 0 {
+		return l
+	}
+
+	return &limitListener{
+		Listener:       l,
+		maxConnections: maxConnections,
+		maxPerSource:   maxPerSource,
+		perSource:      make(map[string]int),
+	}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		source := c.RemoteAddr().String()
+		if host, _, err := net.SplitHostPort(source); err == nil {
+			source = host
+		}
+
+		if rule, ok := l.acquire(source); !ok {
+			log.Warn().
+				Str("source", source).
+				Str("rule", rule).
+				Msg("rejecting connection")
+
+			_ = c.Close()
+			continue
+		}
+
+		return &limitedConn{Conn: c, owner: l, source: source}, nil
+	}
+}
+
+func (l *limitListener) acquire(source string) (string, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.maxConnections > 0 && l.open >= l.maxConnections {
+		return "connection limit", false
+	}
+
+	if l.maxPerSource > 0 && l.perSource[source] >= l.maxPerSource {
+		return "connection limit per source", false
+	}
+
+	l.open++
+	l.perSource[source]++
+
+	return "", true
+}
+
+func (l *limitListener) release(source string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.open--
+	if l.perSource[source]--; l.perSource[source] <= 0 {
+		delete(l.perSource, source)
+	}
+}
+
+func (c *limitedConn) Close() error {
+	c.release.Do(func() {
+		c.owner.release(c.source)
+	})
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,181 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const openApiSchemaPrefix = "#/components/schemas/"
+
+// GatewayOpenApi generates the OpenAPI description of the HTTP/JSON gateway
+// from the routes and the protobuf descriptors of the service
+func GatewayOpenApi(version string) ([]byte, error) {
+	service := (&proto.Unit{}).ProtoReflect().Descriptor().ParentFile().Services().ByName("CredStore")
+	if service == nil {
+		return nil, errors.New("CredStore service descriptor not found")
+	}
+
+	b := &openApiBuilder{schemas: make(map[string]any)}
+	b.schemas["Error"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code":    map[string]any{"type": "integer", "format": "int32"},
+			"status":  map[string]any{"type": "string"},
+			"message": map[string]any{"type": "string"},
+		},
+	}
+
+	paths := make(map[string]map[string]any)
+	for _, route := range gatewayRoutes {
+		method := service.Methods().ByName(protoreflect.Name(route.method))
+		if method == nil {
+			return nil, errors.New("unknown method for gateway route: " + route.method)
+		}
+
+		reply := b.messageRef(method.Output())
+		replyType := "application/json"
+		if slices.Contains(gatewayLiveStreams, route.method) {
+			// one message per line
+			replyType = gatewayNdjsonType
+		} else if method.IsStreamingServer() {
+			reply = map[string]any{"type": "array", "items": reply}
+		}
+
+		operation := map[string]any{
+			"operationId": route.method,
+			"tags":        []string{strings.SplitN(strings.TrimPrefix(route.path, "/v1/"), "/", 2)[0]},
+			"security":    []map[string][]string{{"session": {}}, {}},
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "OK",
+					"content":     map[string]any{replyType: map[string]any{"schema": reply}},
+				},
+				"default": map[string]any{
+					"description": "Error",
+					"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": openApiSchemaPrefix + "Error"}}},
+				},
+			},
+		}
+
+		if route.httpMethod != http.MethodGet {
+			operation["requestBody"] = map[string]any{
+				"content": map[string]any{"application/json": map[string]any{"schema": b.messageRef(method.Input())}},
+			}
+		}
+
+		if paths[route.path] == nil {
+			paths[route.path] = make(map[string]any)
+		}
+
+		paths[route.path][strings.ToLower(route.httpMethod)] = operation
+	}
+
+	document := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "credstore",
+			"description": "HTTP/JSON gateway of the CredStore gRPC service",
+			"version":     version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"session": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+
+	return json.MarshalIndent(document, "", "  ")
+}
+
+type openApiBuilder struct {
+	schemas map[string]any
+}
+
+func (b *openApiBuilder) messageRef(message protoreflect.MessageDescriptor) map[string]any {
+	name := strings.TrimPrefix(string(message.FullName()), string(message.ParentFile().Package())+".")
+
+	if _, ok := b.schemas[name]; !ok {
+		// registered up front, so recursive messages terminate
+		b.schemas[name] = nil
+
+		properties := make(map[string]any)
+		fields := message.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			field := fields.Get(i)
+			properties[field.JSONName()] = b.fieldSchema(field)
+		}
+
+		b.schemas[name] = map[string]any{"type": "object", "properties": properties}
+	}
+
+	return map[string]any{"$ref": openApiSchemaPrefix + name}
+}
+
+func (b *openApiBuilder) fieldSchema(field protoreflect.FieldDescriptor) map[string]any {
+	if field.IsMap() {
+		return map[string]any{"type": "object", "additionalProperties": b.valueSchema(field.MapValue())}
+	}
+
+	if field.IsList() {
+		return map[string]any{"type": "array", "items": b.valueSchema(field)}
+	}
+
+	return b.valueSchema(field)
+}
+
+// valueSchema describes values the way protojson encodes them, e.g. 64 bit
+// integers as strings
+func (b *openApiBuilder) valueSchema(field protoreflect.FieldDescriptor) map[string]any {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		var names []string
+		values := field.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return b.messageRef(field.Message())
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
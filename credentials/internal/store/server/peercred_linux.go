@@ -0,0 +1,48 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"golang.org/x/sys/unix"
+	"net"
+)
+
+func readPeerIdentity(conn *net.UnixConn) (access.PeerIdentity, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return access.PeerIdentity{}, err
+	}
+
+	var ucred *unix.Ucred
+	var credErr error
+
+	err = rawConn.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+
+	if err != nil {
+		return access.PeerIdentity{}, err
+	} else if credErr != nil {
+		return access.PeerIdentity{}, credErr
+	}
+
+	return access.PeerIdentity{
+		Uid: ucred.Uid,
+		Gid: ucred.Gid,
+		Pid: ucred.Pid,
+	}, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"net"
+)
+
+// policyListener only hands out connections whose source address is
+// accepted by the access policy
+type policyListener struct {
+	net.Listener
+	policy *access.Policy
+}
+
+func NewPolicyListener(l net.Listener, policy *access.Policy) net.Listener {
+	return &policyListener{Listener: l, policy: policy}
+}
+
+func (p *policyListener) Accept() (net.Conn, error) {
+	for {
+		c, err := p.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		remoteAddr, ok := c.RemoteAddr().(*net.TCPAddr)
+		if !ok {
+			log.Warn().Msgf("rejecting connection from unsupported address: %s", c.RemoteAddr())
+			_ = c.Close()
+			continue
+		}
+
+		var localIP net.IP
+		if localAddr, ok := c.LocalAddr().(*net.TCPAddr); ok {
+			localIP = localAddr.IP
+		}
+
+		decision, err := p.policy.CheckSource(remoteAddr.IP, localIP)
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to check access policy for remote address: %s", remoteAddr.IP)
+			_ = c.Close()
+			continue
+		}
+
+		if !decision.Allowed {
+			log.Warn().
+				Str("source", remoteAddr.IP.String()).
+				Str("rule", decision.Rule).
+				Msg("rejecting connection")
+
+			_ = c.Close()
+			continue
+		}
+
+		log.Debug().
+			Str("source", remoteAddr.IP.String()).
+			Str("rule", decision.Rule).
+			Msg("accepting connection")
+
+		return c, nil
+	}
+}
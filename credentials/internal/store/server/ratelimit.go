@@ -0,0 +1,224 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/limiter"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// methods which don't verify any credentials, so there's nothing to limit
+var unlimitedMethods = map[string]bool{
+	proto.CredStore_GetInfo_FullMethodName:       true,
+	proto.CredStore_LockVault_FullMethodName:     true,
+	proto.CredStore_GetAuthority_FullMethodName:  true,
+	proto.CredStore_GetEnrollment_FullMethodName: true,
+}
+
+// implemented by all requests which may carry client credentials
+type clientRequest interface {
+	GetClient() *proto.ClientCredentials
+	GetKey() *proto.ClientKeyCredentials
+}
+
+// rateLimitedCall reserves an attempt for each of its keys until the call
+// authenticated or its failure was recorded. The calls of a single goroutine
+// use it.
+type rateLimitedCall struct {
+	state    *service.State
+	attempt  *limiter.Attempt
+	keys     []string
+	reserved int
+	released bool
+	source   string
+	clientId string
+	logger   *zerolog.Logger
+}
+
+func newRateLimitedCall(ctx context.Context, state *service.State) (context.Context, *rateLimitedCall) {
+	call := &rateLimitedCall{
+		state:  state,
+		source: access.SourceAddress(ctx),
+		logger: log.Ctx(ctx),
+	}
+
+	call.keys = append(call.keys, "ip:"+call.source)
+
+	ctx, call.attempt = limiter.NewContext(ctx, call.release)
+
+	return ctx, call
+}
+
+// identify adds the client the request claims to be, if any
+func (c *rateLimitedCall) identify(req any) {
+	switch request := req.(type) {
+	case clientRequest:
+		if request.GetClient() != nil {
+			c.clientId = request.GetClient().GetId()
+		} else if request.GetKey() != nil {
+			c.clientId = request.GetKey().GetId()
+		}
+	case *proto.ChallengeRequest:
+		c.clientId = request.GetClientId()
+	}
+
+	if c.clientId != "" {
+		c.keys = append(c.keys, "client:"+c.clientId)
+	}
+}
+
+// check reserves an attempt for the keys which weren't reserved yet
+func (c *rateLimitedCall) check(ctx context.Context, method string) error {
+	if c.released || c.reserved == len(c.keys) {
+		return nil
+	}
+
+	retryAfter, ok := c.state.Limiter().Reserve(ctx, c.keys[c.reserved:]...)
+	if ok {
+		c.reserved = len(c.keys)
+		return nil
+	} else if retryAfter == 0 {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	c.logger.Warn().
+		Str("method", method).
+		Str("source", c.source).
+		Str("client", c.clientId).
+		Dur("retry_after", retryAfter).
+		Msg("rejecting call after too many failed attempts")
+
+	return status.Errorf(codes.ResourceExhausted, "too many failed attempts, retry in %s", retryAfter.Round(time.Second))
+}
+
+// release gives back the reservations, at the latest once the call ended
+func (c *rateLimitedCall) release() {
+	if c.released || c.reserved == 0 {
+		return
+	}
+
+	c.released = true
+	c.state.Limiter().Release(c.keys[:c.reserved]...)
+}
+
+func (c *rateLimitedCall) record(method string, err error) {
+	// the failure is recorded before waiting calls may continue
+	defer c.release()
+
+	lim := c.state.Limiter()
+
+	if status.Code(err) != codes.Unauthenticated {
+		// only a proven identity clears earlier failures, anything else
+		// (e.g. a request that never presented credentials) leaves them be
+		switch c.attempt.Identity() {
+		case limiter.Unauthenticated:
+		case limiter.Admin:
+			lim.Success(c.keys...)
+			lim.AdminSuccess()
+		default:
+			lim.Success(c.keys...)
+		}
+
+		return
+	}
+
+	c.logger.Warn().
+		Str("method", method).
+		Str("source", c.source).
+		Str("client", c.clientId).
+		Msg("authentication failed")
+
+	lim.Failure(c.keys...)
+
+	if c.clientId != "" {
+		lim.ClientFailure()
+	} else if lim.AdminFailure() {
+		c.logger.Warn().Msg("too many consecutive admin authentication failures, locking vault")
+		c.state.Lock()
+	}
+}
+
+func unaryRateLimitInterceptor(state *service.State) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if unlimitedMethods[info.FullMethod] || healthMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		ctx, call := newRateLimitedCall(ctx, state)
+		call.identify(req)
+		if err := call.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		resp, err := handler(ctx, req)
+		call.record(info.FullMethod, err)
+
+		return resp, err
+	}
+}
+
+func streamRateLimitInterceptor(state *service.State) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if unlimitedMethods[info.FullMethod] || healthMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+
+		wrapped := middleware.WrapServerStream(ss)
+
+		var call *rateLimitedCall
+		wrapped.WrappedContext, call = newRateLimitedCall(ss.Context(), state)
+		if err := call.check(wrapped.WrappedContext, info.FullMethod); err != nil {
+			return err
+		}
+
+		limited := &rateLimitedStream{WrappedServerStream: wrapped, call: call, method: info.FullMethod}
+
+		err := handler(srv, limited)
+		call.record(info.FullMethod, err)
+
+		return err
+	}
+}
+
+// rateLimitedStream takes the credentials from the request of a server
+// streaming call, which is only available once the handler receives it
+type rateLimitedStream struct {
+	*middleware.WrappedServerStream
+	call     *rateLimitedCall
+	method   string
+	received bool
+}
+
+func (s *rateLimitedStream) RecvMsg(m any) error {
+	if err := s.WrappedServerStream.RecvMsg(m); err != nil || s.received {
+		return err
+	}
+
+	s.received = true
+	s.call.identify(m)
+
+	return s.call.check(s.Context(), s.method)
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/limiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testPeer = &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}}
+
+func TestRateLimit_OnlyAuthenticationResets(t *testing.T) {
+	state := newTestState(t)
+	state.Limiter().SetOptions(limiter.Options{BaseDelay: time.Minute, MaxDelay: time.Minute})
+
+	ctx := peer.NewContext(context.Background(), testPeer)
+	interceptor := unaryRateLimitInterceptor(state)
+	info := &grpc.UnaryServerInfo{FullMethod: proto.CredStore_ListAdmins_FullMethodName}
+
+	call := func(err error) error {
+		_, callErr := interceptor(ctx, &proto.AdminSearch{}, info, func(context.Context, any) (any, error) {
+			return nil, err
+		})
+
+		return callErr
+	}
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(status.Error(codes.Unauthenticated, "nope"))))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call(nil)))
+
+	// a call without credentials that succeeded anyway doesn't forgive anything
+	_, unauthenticated := newRateLimitedCall(ctx, state)
+	unauthenticated.record(info.FullMethod, nil)
+
+	_, ok := state.Limiter().Reserve(ctx, "ip:10.0.0.1")
+	assert.False(t, ok)
+
+	authCtx, authenticated := newRateLimitedCall(ctx, state)
+	limiter.Authenticated(authCtx, limiter.Admin)
+	authenticated.record(info.FullMethod, nil)
+
+	_, ok = state.Limiter().Reserve(ctx, "ip:10.0.0.1")
+	assert.True(t, ok)
+}
+
+func TestRateLimit_ReleasedOnAuthentication(t *testing.T) {
+	state := newTestState(t)
+	state.Limiter().SetOptions(limiter.Options{FreeAttempts: 0, BaseDelay: time.Minute, MaxDelay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(peer.NewContext(context.Background(), testPeer), time.Second)
+	defer cancel()
+
+	interceptor := unaryRateLimitInterceptor(state)
+	info := &grpc.UnaryServerInfo{FullMethod: proto.CredStore_ListAdmins_FullMethodName}
+
+	// a single attempt is allowed at a time, until the call authenticated
+	_, err := interceptor(ctx, &proto.AdminSearch{}, info, func(ctx context.Context, _ any) (any, error) {
+		limiter.Authenticated(ctx, limiter.Admin)
+
+		return interceptor(ctx, &proto.AdminSearch{}, info, func(context.Context, any) (any, error) {
+			return nil, nil
+		})
+	})
+	assert.NoError(t, err)
+}
+
+type testRecvStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	request *proto.ItemsRequest
+}
+
+func (s *testRecvStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testRecvStream) RecvMsg(m any) error {
+	m.(*proto.ItemsRequest).Credentials = s.request.Credentials
+	return nil
+}
+
+func TestRateLimit_StreamClient(t *testing.T) {
+	state := newTestState(t)
+	state.Limiter().SetOptions(limiter.Options{BaseDelay: time.Minute, MaxDelay: time.Minute})
+	state.Limiter().Failure("client:CC[blocked]")
+
+	stream := &testRecvStream{
+		ctx: peer.NewContext(context.Background(), testPeer),
+		request: &proto.ItemsRequest{Credentials: &proto.ItemsRequest_Client{
+			Client: &proto.ClientCredentials{Id: "CC[blocked]", Secret: "secret"},
+		}},
+	}
+
+	info := &grpc.StreamServerInfo{FullMethod: proto.CredStore_ReadVaultItems_FullMethodName, IsServerStream: true}
+
+	handlerCalled := false
+	err := streamRateLimitInterceptor(state)(nil, stream, info, func(_ any, ss grpc.ServerStream) error {
+		request := &proto.ItemsRequest{}
+		if err := ss.RecvMsg(request); err != nil {
+			return err
+		}
+
+		handlerCalled = true
+		return nil
+	})
+
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.False(t, handlerCalled)
+
+	// client failures aren't counted as admin failures
+	stream.request.GetClient().Id = "CC[other]"
+	err = streamRateLimitInterceptor(state)(nil, stream, info, func(_ any, ss grpc.ServerStream) error {
+		request := &proto.ItemsRequest{}
+		if err := ss.RecvMsg(request); err != nil {
+			return err
+		}
+
+		return status.Error(codes.Unauthenticated, "nope")
+	})
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, uint64(1), state.Limiter().Stats().ClientFailures)
+	assert.Zero(t, state.Limiter().Stats().AdminFailures)
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/awnumar/memguard"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/redact"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+	"unsafe"
+)
+
+// unaryRedactInterceptor scrubs the secrets sent along with the request from
+// the returned error, before it's logged, audited or sent to the client. The
+// secrets are collected up front, as the handler wipes them from the request.
+func unaryRedactInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var secrets []string
+	if m, ok := req.(protobuf.Message); ok {
+		secrets = redact.Secrets(m)
+		defer wipeSecrets(secrets)
+	}
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		err = scrubError(err, secrets)
+	}
+
+	return resp, err
+}
+
+func streamRedactInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	stream := &secretCollectingStream{ServerStream: ss}
+	defer func() {
+		wipeSecrets(stream.secrets)
+	}()
+
+	err := handler(srv, stream)
+	if err != nil {
+		err = scrubError(err, stream.secrets)
+	}
+
+	return err
+}
+
+// secretCollectingStream remembers the secrets of the received messages
+type secretCollectingStream struct {
+	grpc.ServerStream
+	secrets []string
+}
+
+func (s *secretCollectingStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		if msg, ok := m.(protobuf.Message); ok {
+			s.secrets = append(s.secrets, redact.Secrets(msg)...)
+		}
+	}
+
+	return err
+}
+
+// wipeSecrets wipes the copies of the secrets once they're not needed anymore
+func wipeSecrets(secrets []string) {
+	for _, secret := range secrets {
+		memguard.WipeBytes(unsafe.Slice(unsafe.StringData(secret), len(secret)))
+	}
+}
+
+func scrubError(err error, secrets []string) error {
+	if len(secrets) == 0 {
+		return err
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return errors.New(redact.Scrub(err.Error(), secrets))
+	}
+
+	message := redact.Scrub(s.Message(), secrets)
+	if message == s.Message() {
+		return err
+	}
+
+	return status.Error(s.Code(), message)
+}
@@ -0,0 +1,297 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/awnumar/memguard"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/redact"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPassphrase = "passphrase-7f3a9c2d41"
+	testItemValue  = "item-value-5b2e81c7"
+)
+
+type syncBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buffer.String()
+}
+
+// TestRedaction calls every RPC at trace level with all sensitive fields
+// set, none of the secrets may show up in the log or the returned errors
+func TestRedaction(t *testing.T) {
+	output := &syncBuffer{}
+
+	previousLogger, previousLevel := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(output).With().Timestamp().Logger()
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+
+	t.Cleanup(func() {
+		log.Logger = previousLogger
+		zerolog.SetGlobalLevel(previousLevel)
+	})
+
+	conn := newTestConn(t)
+	ctx := context.Background()
+
+	secrets := []string{testPassphrase, testItemValue}
+	var errs []string
+
+	// the calls succeeding with the secrets, responses carry secrets as well
+	admin := &proto.AdminCredentials{Passphrase: testPassphrase}
+
+	_, err := proto.NewCredStoreClient(conn).InitializeVault(ctx, &proto.VaultInitialization{Passphrase: testPassphrase})
+	require.NoError(t, err)
+
+	_, err = proto.NewCredStoreClient(conn).UnlockVault(ctx, admin)
+	require.NoError(t, err)
+
+	item, err := proto.NewCredStoreClient(conn).CreateVaultItem(ctx, &proto.ItemCreation{
+		Credentials: admin,
+		Description: "redaction",
+		Value:       []byte(testItemValue),
+	})
+	require.NoError(t, err)
+
+	value, err := proto.NewCredStoreClient(conn).ReadVaultItem(ctx, &proto.ItemRequest{
+		Credentials: &proto.ItemRequest_Admin{Admin: admin},
+		ItemId:      item.Id,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte(testItemValue), value.Value)
+
+	client, err := proto.NewCredStoreClient(conn).CreateClientCredentials(ctx, &proto.ClientCreation{Credentials: admin})
+	require.NoError(t, err)
+	secrets = append(secrets, client.Secret)
+
+	session, err := proto.NewCredStoreClient(conn).Login(ctx, &proto.LoginRequest{Credentials: admin})
+	require.NoError(t, err)
+	secrets = append(secrets, session.Token)
+
+	// every RPC, the vault is locked last
+	service := (&proto.Unit{}).ProtoReflect().Descriptor().ParentFile().Services().ByName("CredStore")
+
+	var methods []protoreflect.MethodDescriptor
+	for i := 0; i < service.Methods().Len(); i++ {
+		methods = append(methods, service.Methods().Get(i))
+	}
+
+	slices.SortStableFunc(methods, func(a, b protoreflect.MethodDescriptor) int {
+		return boolOrder(a.Name() == "LockVault") - boolOrder(b.Name() == "LockVault")
+	})
+
+	for _, method := range methods {
+		request := newTestMessage(t, method.Input())
+		fillSecrets(request.ProtoReflect())
+
+		fullMethod := "/" + proto.CredStore_ServiceDesc.ServiceName + "/" + string(method.Name())
+
+		callCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		if method.IsStreamingServer() {
+			err = callStream(callCtx, conn, fullMethod, request, method.Output())
+		} else {
+			err = conn.Invoke(callCtx, fullMethod, request, newTestMessage(t, method.Output()))
+		}
+
+		cancel()
+
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	logged := output.String()
+	assert.Contains(t, logged, "request received")
+	assert.Contains(t, logged, redact.Placeholder)
+
+	for _, secret := range secrets {
+		encoded := base64.StdEncoding.EncodeToString([]byte(secret))
+		assert.False(t, strings.Contains(logged, secret) || strings.Contains(logged, encoded), "secret was logged: %s", secret)
+
+		for _, message := range errs {
+			assert.NotContains(t, message, secret)
+		}
+	}
+}
+
+func newTestConn(t *testing.T) *grpc.ClientConn {
+	state := newTestState(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	grpcServer := NewGrpcServer(state, nil)
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		grpcServer.Stop()
+	})
+
+	return conn
+}
+
+func newTestState(t *testing.T) *service.State {
+	dir := t.TempDir()
+
+	tokenFile := filepath.Join(dir, "replication.token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte(testPassphrase), 0o600))
+
+	config := &store.Config{
+		StoragePath:   filepath.Join(dir, "vault"),
+		ListenAddress: "127.0.0.1:0",
+		Replication:   &store.ReplicationConfig{TokenFile: tokenFile},
+	}
+
+	vaultInstance, err := vault.NewVault(&vault.Options{Backend: vault.NewLocalStorageBackend(config.StoragePath)})
+	require.NoError(t, err)
+
+	state, err := service.NewState(config, vaultInstance, "test", false)
+	require.NoError(t, err)
+
+	t.Cleanup(state.StopWatches)
+
+	return state
+}
+
+func newTestMessage(t *testing.T, descriptor protoreflect.MessageDescriptor) protoreflect.ProtoMessage {
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(descriptor.FullName())
+	require.NoError(t, err)
+
+	return messageType.New().Interface()
+}
+
+func callStream(ctx context.Context, conn *grpc.ClientConn, fullMethod string, request any, output protoreflect.MessageDescriptor) error {
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fullMethod)
+	if err != nil {
+		return err
+	}
+
+	if err = stream.SendMsg(request); err != nil {
+		return err
+	}
+
+	if err = stream.CloseSend(); err != nil {
+		return err
+	}
+
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(output.FullName())
+	if err != nil {
+		return err
+	}
+
+	for {
+		if err = stream.RecvMsg(messageType.New().Interface()); err != nil {
+			return err
+		}
+	}
+}
+
+// fillSecrets sets all sensitive fields, creating the messages holding them
+func fillSecrets(m protoreflect.Message) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if oneof := fd.ContainingOneof(); oneof != nil && m.WhichOneof(oneof) != nil {
+			continue
+		}
+
+		switch {
+		case redact.IsSensitive(fd) && fd.Kind() == protoreflect.StringKind:
+			m.Set(fd, protoreflect.ValueOfString(testPassphrase))
+		case redact.IsSensitive(fd) && fd.Kind() == protoreflect.BytesKind:
+			m.Set(fd, protoreflect.ValueOfBytes([]byte(testItemValue)))
+		case fd.IsList() && fd.Message() != nil:
+			list := m.Mutable(fd).List()
+			element := list.NewElement()
+			fillSecrets(element.Message())
+			list.Append(element)
+		case fd.Message() != nil && !fd.IsMap():
+			fillSecrets(m.Mutable(fd).Message())
+		}
+	}
+}
+
+func boolOrder(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func TestUnaryRedactInterceptor_WipedRequest(t *testing.T) {
+	request := &proto.AdminCredentials{Passphrase: strings.Clone(testPassphrase)}
+
+	_, err := unaryRedactInterceptor(context.Background(), request, &grpc.UnaryServerInfo{}, func(_ context.Context, req any) (any, error) {
+		passphrase := strings.Clone(req.(*proto.AdminCredentials).Passphrase)
+
+		// like the service does once it's done with the passphrase
+		memguard.WipeBytes(unsafe.Slice(unsafe.StringData(request.Passphrase), len(request.Passphrase)))
+
+		return nil, status.Error(codes.Unauthenticated, "wrong passphrase: "+passphrase)
+	})
+
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.NotContains(t, err.Error(), testPassphrase)
+	assert.Contains(t, err.Error(), redact.Placeholder)
+}
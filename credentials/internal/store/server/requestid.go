@@ -0,0 +1,86 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"github.com/google/uuid"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// requestIdKey is the metadata key of the request ID, it's returned in
+	// the trailers of each call
+	requestIdKey    = "x-request-id"
+	requestIdField  = "request_id"
+	maxRequestIdLen = 64
+)
+
+func unaryRequestIdInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, requestId := withRequestId(ctx)
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(requestIdKey, requestId))
+
+	return handler(ctx, req)
+}
+
+func streamRequestIdInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	wrapped := middleware.WrapServerStream(ss)
+
+	var requestId string
+	wrapped.WrappedContext, requestId = withRequestId(ss.Context())
+	wrapped.SetTrailer(metadata.Pairs(requestIdKey, requestId))
+
+	return handler(srv, wrapped)
+}
+
+// withRequestId attaches the request ID to all log lines of the call, a
+// well-formed ID sent by the client (e.g. by a proxy) is kept
+func withRequestId(ctx context.Context) (context.Context, string) {
+	requestId := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIdKey); len(ids) > 0 && isValidRequestId(ids[0]) {
+			requestId = ids[0]
+		}
+	}
+
+	if requestId == "" {
+		requestId = uuid.NewString()
+	}
+
+	ctx = logging.InjectFields(ctx, logging.Fields{requestIdField, requestId})
+	ctx = log.Logger.With().Str(requestIdField, requestId).Logger().WithContext(ctx)
+
+	return ctx, requestId
+}
+
+func isValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLen {
+		return false
+	}
+
+	for _, r := range requestId {
+		isAlnum := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !isAlnum && r != '-' && r != '_' && r != '.' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,38 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"google.golang.org/grpc/metadata"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRequestId(t *testing.T) {
+	_, generated := withRequestId(context.Background())
+	assert.Len(t, generated, 36)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIdKey, "proxy-1234"))
+	_, kept := withRequestId(ctx)
+	assert.Equal(t, "proxy-1234", kept)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIdKey, "bad id\n"))
+	_, replaced := withRequestId(ctx)
+	assert.NotEqual(t, "bad id\n", replaced)
+	assert.Len(t, replaced, 36)
+}
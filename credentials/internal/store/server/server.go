@@ -16,65 +16,353 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
 	"github.com/vemilyus/borg-collective/credentials/internal/store/cert"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/metrics"
 	"github.com/vemilyus/borg-collective/credentials/internal/store/service"
 	"google.golang.org/grpc"
 	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultMetricsAddress  = "127.0.0.1:9464"
+	defaultMetricsPath     = "/metrics"
+	DefaultShutdownTimeout = 10 * time.Second
 )
 
 type Server struct {
 	*grpc.Server
-	net.Listener
+	listeners       []net.Listener
+	metricsServer   *http.Server
+	metricsListener net.Listener
+	gatewayServer   *http.Server
+	gatewayListener net.Listener
 }
 
-func NewServer(state *service.State) (*Server, error) {
-	server := &Server{
-		Server: NewGrpcServer(state),
-	}
+// NewServer creates the server and its listeners, the activated listeners
+// (e.g. passed by systemd socket activation) are used instead of listening on
+// the configured addresses
+func NewServer(state *service.State, activated []net.Listener) (*Server, error) {
+	server := &Server{}
 
 	config := state.Config()
 
-	var listener net.Listener
-	var err error
+	tcpListener, unixListener, err := splitActivatedListeners(activated)
+	if err != nil {
+		return nil, err
+	}
+
+	var certReloader *cert.X509KeyPairReloader
+	var tlsConfig *tls.Config
 
 	if state.IsProduction() {
 		if config.Tls == nil {
 			return nil, errors.New("TLS configuration is not set")
 		}
 
-		var certReloader *cert.X509KeyPairReloader
 		certReloader, err = cert.NewX509KeyPairReloader(config.Tls.CertFile, config.Tls.KeyFile)
 		if err != nil {
 			return nil, errors.New("Failed to load TLS certificate: " + err.Error())
 		}
 
-		tlsConfig := &tls.Config{
-			GetCertificate: certReloader.GetCertificate,
-			NextProtos:     []string{"h2"},
+		state.SetTlsCertificate(certReloader.Leaf)
+
+		tlsConfig = &tls.Config{
+			GetCertificate:        certReloader.GetCertificate,
+			NextProtos:            []string{"h2"},
+			ClientAuth:            tls.RequestClientCert,
+			VerifyPeerCertificate: state.Authority().VerifyPeerCertificate,
 		}
+	}
 
-		listener, err = tls.Listen("tcp", config.ListenAddress, tlsConfig)
-		listener = NewSecureListener(listener)
-	} else {
-		listener, err = net.Listen("tcp", config.ListenAddress)
+	listener := tcpListener
+	if listener == nil {
+		if listener, err = net.Listen("tcp", config.ListenAddress); err != nil {
+			return nil, err
+		}
 	}
 
-	if err != nil {
-		return nil, err
+	server.listeners = append(server.listeners, wrapTcpListener(listener, state, tlsConfig))
+
+	var m *metrics.Metrics
+	if config.Metrics != nil {
+		var tlsCertificate func() *x509.Certificate
+		if certReloader != nil {
+			tlsCertificate = certReloader.Leaf
+		}
+
+		m = metrics.New(state, tlsCertificate)
+
+		if err = server.listenMetrics(config.Metrics, m); err != nil {
+			server.closeListeners()
+			return nil, err
+		}
 	}
 
-	server.Listener = listener
+	server.Server = NewGrpcServer(state, m)
+
+	if config.Gateway != nil {
+		if err = server.listenGateway(state, m, tlsConfig); err != nil {
+			server.closeListeners()
+			return nil, err
+		}
+	}
+
+	if unixListener != nil {
+		if config.UnixSocket == nil {
+			server.closeListeners()
+			return nil, errors.New("received a unix socket, but the unix socket isn't configured")
+		}
+
+		server.listeners = append(server.listeners, WrapUnixListener(unixListener, state.UnixPolicy()))
+	} else if config.UnixSocket != nil {
+		listener, err = NewUnixListener(config.UnixSocket, state.UnixPolicy())
+		if err != nil {
+			server.closeListeners()
+			return nil, err
+		}
+
+		server.listeners = append(server.listeners, listener)
+	}
 
 	return server, nil
 }
 
+// wrapTcpListener applies the connection limits, TLS and the access policy
+func wrapTcpListener(listener net.Listener, state *service.State, tlsConfig *tls.Config) net.Listener {
+	// connections are limited before TLS, so they're counted until closed
+	if transport := state.Config().Transport; transport != nil {
+		listener = NewLimitListener(listener, transport.MaxConnections, transport.MaxConnectionsPerSource)
+	}
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	return NewPolicyListener(listener, state.AccessPolicy())
+}
+
+func splitActivatedListeners(activated []net.Listener) (net.Listener, *net.UnixListener, error) {
+	var tcpListener net.Listener
+	var unixListener *net.UnixListener
+
+	for _, listener := range activated {
+		if listener == nil {
+			// file descriptors which aren't sockets
+			continue
+		}
+
+		switch l := listener.(type) {
+		case *net.TCPListener:
+			if tcpListener != nil {
+				return nil, nil, errors.New("received more than one TCP socket")
+			}
+
+			tcpListener = l
+		case *net.UnixListener:
+			if unixListener != nil {
+				return nil, nil, errors.New("received more than one unix socket")
+			}
+
+			unixListener = l
+		default:
+			return nil, nil, fmt.Errorf("received unsupported socket: %s", listener.Addr())
+		}
+	}
+
+	return tcpListener, unixListener, nil
+}
+
+func (s *Server) listenMetrics(config *store.MetricsConfig, m *metrics.Metrics) error {
+	address := config.ListenAddress
+	if address == "" {
+		address = defaultMetricsAddress
+	}
+
+	path := config.Path
+	if path == "" {
+		path = defaultMetricsPath
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, m.Handler())
+
+	s.metricsListener = listener
+	s.metricsServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return nil
+}
+
+func (s *Server) listenGateway(state *service.State, m *metrics.Metrics, tlsConfig *tls.Config) error {
+	handler, err := newGatewayHandler(state, m)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", state.Config().Gateway.ListenAddress)
+	if err != nil {
+		return err
+	}
+
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	s.gatewayListener = wrapTcpListener(listener, state, tlsConfig)
+	s.gatewayServer = &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return nil
+}
+
+func (s *Server) Addresses() []string {
+	var addresses []string
+	for _, listener := range s.listeners {
+		addresses = append(addresses, listener.Addr().String())
+	}
+
+	return addresses
+}
+
+// MetricsAddress returns the address metrics are served on, nil if disabled
+func (s *Server) MetricsAddress() net.Addr {
+	if s.metricsListener == nil {
+		return nil
+	}
+
+	return s.metricsListener.Addr()
+}
+
+// GatewayAddress returns the address of the HTTP/JSON gateway, nil if
+// disabled
+func (s *Server) GatewayAddress() net.Addr {
+	if s.gatewayListener == nil {
+		return nil
+	}
+
+	return s.gatewayListener.Addr()
+}
+
+// Serve serves on all listeners until one of them fails
 func (s *Server) Serve() error {
-	return s.Server.Serve(s.Listener)
+	errs := make(chan error, len(s.listeners)+2)
+
+	for _, listener := range s.listeners {
+		go func() {
+			errs <- s.Server.Serve(listener)
+		}()
+	}
+
+	if s.metricsListener != nil {
+		go func() {
+			errs <- s.metricsServer.Serve(s.metricsListener)
+		}()
+	}
+
+	if s.gatewayListener != nil {
+		go func() {
+			errs <- s.gatewayServer.Serve(s.gatewayListener)
+		}()
+	}
+
+	return <-errs
+}
+
+// Shutdown stops accepting connections and waits for in-flight calls to
+// complete, calls still running after the timeout are cancelled. It returns
+// false if calls had to be cancelled.
+func (s *Server) Shutdown(timeout time.Duration) bool {
+	defer s.closeListeners()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	gatewayStopped := make(chan bool, 1)
+	go func() {
+		gatewayStopped <- s.shutdownGateway(ctx)
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(stopped)
+	}()
+
+	graceful := true
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		graceful = false
+		s.Server.Stop()
+		<-stopped
+	}
+
+	if !<-gatewayStopped {
+		graceful = false
+	}
+
+	if s.metricsServer != nil {
+		_ = s.metricsServer.Close()
+	}
+
+	return graceful
+}
+
+func (s *Server) shutdownGateway(ctx context.Context) bool {
+	if s.gatewayServer == nil {
+		return true
+	}
+
+	if err := s.gatewayServer.Shutdown(ctx); err != nil {
+		_ = s.gatewayServer.Close()
+		return false
+	}
+
+	return true
 }
 
 func (s *Server) Close() {
-	defer func() { _ = s.Listener.Close() }()
+	defer s.closeListeners()
 	s.Server.GracefulStop()
+
+	if s.gatewayServer != nil {
+		_ = s.gatewayServer.Close()
+	}
+
+	if s.metricsServer != nil {
+		_ = s.metricsServer.Close()
+	}
+}
+
+func (s *Server) closeListeners() {
+	for _, listener := range s.listeners {
+		_ = listener.Close()
+	}
+
+	if s.metricsListener != nil {
+		_ = s.metricsListener.Close()
+	}
+
+	if s.gatewayListener != nil {
+		_ = s.gatewayListener.Close()
+	}
 }
@@ -0,0 +1,144 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"net"
+	"time"
+)
+
+const (
+	defaultKeepaliveMinTime     = 30 * time.Second
+	defaultKeepaliveTime        = 2 * time.Hour
+	defaultKeepaliveTimeout     = 20 * time.Second
+	defaultMaxReceiveSize       = 4 << 20
+	defaultMaxSendSize          = 16 << 20
+	defaultMaxConcurrentStreams = 100
+	defaultHandshakeTimeout     = 10 * time.Second
+)
+
+// transportOptions applies the transport settings, falling back to the
+// defaults for everything which isn't configured
+func transportOptions(config *store.TransportConfig) []grpc.ServerOption {
+	if config == nil {
+		config = &store.TransportConfig{}
+	}
+
+	enforcement := keepalive.EnforcementPolicy{
+		MinTime:             durationOr(config.KeepaliveMinTime, defaultKeepaliveMinTime),
+		PermitWithoutStream: true,
+	}
+
+	if config.KeepaliveWithoutCalls != nil {
+		enforcement.PermitWithoutStream = *config.KeepaliveWithoutCalls
+	}
+
+	params := keepalive.ServerParameters{
+		Time:    durationOr(config.KeepaliveTime, defaultKeepaliveTime),
+		Timeout: durationOr(config.KeepaliveTimeout, defaultKeepaliveTimeout),
+	}
+
+	if config.MaxConnectionIdle != nil {
+		params.MaxConnectionIdle = config.MaxConnectionIdle.Duration
+	}
+
+	maxReceiveSize := defaultMaxReceiveSize
+	if config.MaxReceiveSize != nil {
+		maxReceiveSize = *config.MaxReceiveSize
+	}
+
+	maxSendSize := defaultMaxSendSize
+	if config.MaxSendSize != nil {
+		maxSendSize = *config.MaxSendSize
+	}
+
+	maxConcurrentStreams := uint32(defaultMaxConcurrentStreams)
+	if config.MaxConcurrentStreams != nil {
+		maxConcurrentStreams = *config.MaxConcurrentStreams
+	}
+
+	return []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(enforcement),
+		grpc.KeepaliveParams(params),
+		grpc.MaxRecvMsgSize(maxReceiveSize),
+		grpc.MaxSendMsgSize(maxSendSize),
+		grpc.MaxConcurrentStreams(maxConcurrentStreams),
+		grpc.ConnectionTimeout(durationOr(config.HandshakeTimeout, defaultHandshakeTimeout)),
+	}
+}
+
+func durationOr(d *store.Duration, fallback time.Duration) time.Duration {
+	if d == nil {
+		return fallback
+	}
+
+	return d.Duration
+}
+
+// transportCredentials exposes what's known about the peer of a connection
+// to the calls made over it. TLS is already handled by the listener, so the
+// handshake only needs to complete here.
+type transportCredentials struct{}
+
+type plainInfo struct {
+	credentials.CommonAuthInfo
+}
+
+func (plainInfo) AuthType() string {
+	return "insecure"
+}
+
+func (transportCredentials) ClientHandshake(context.Context, string, net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("client handshake is not supported")
+}
+
+func (transportCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	switch c := conn.(type) {
+	case *tls.Conn:
+		if err := c.Handshake(); err != nil {
+			return nil, nil, err
+		}
+
+		return c, credentials.TLSInfo{
+			State:          c.ConnectionState(),
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		}, nil
+	case *unixPeerConn:
+		return c.UnixConn, c.peer, nil
+	default:
+		return conn, plainInfo{credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}, nil
+	}
+}
+
+func (transportCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "credstore"}
+}
+
+func (t transportCredentials) Clone() credentials.TransportCredentials {
+	return t
+}
+
+//goland:noinspection GoDeprecation
+func (transportCredentials) OverrideServerName(string) error {
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"errors"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"net"
+	"os"
+)
+
+const defaultSocketMode = 0660
+
+// unixPeerConn carries the resolved peer of a connection on the Unix socket
+// until the transport credentials attach it to the calls
+type unixPeerConn struct {
+	*net.UnixConn
+	peer *access.UnixPeer
+}
+
+// unixListener only hands out connections of local peers which are mapped
+// by the Unix socket policy
+type unixListener struct {
+	*net.UnixListener
+	policy *access.UnixPolicy
+}
+
+func NewUnixListener(config *store.UnixSocketConfig, policy *access.UnixPolicy) (net.Listener, error) {
+	if config.Path == "" {
+		return nil, errors.New("unix socket path is not set")
+	}
+
+	// a socket file left behind by a previous run would prevent listening
+	if stat, err := os.Lstat(config.Path); err == nil && stat.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(config.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: config.Path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+
+	mode := os.FileMode(defaultSocketMode)
+	if config.Mode != nil {
+		mode = os.FileMode(*config.Mode)
+	}
+
+	if err = os.Chmod(config.Path, mode); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	return WrapUnixListener(l, policy), nil
+}
+
+// WrapUnixListener applies the Unix socket policy to a listener which was
+// created elsewhere, e.g. by systemd socket activation
+func WrapUnixListener(l *net.UnixListener, policy *access.UnixPolicy) net.Listener {
+	return &unixListener{UnixListener: l, policy: policy}
+}
+
+func (u *unixListener) Accept() (net.Conn, error) {
+	for {
+		c, err := u.AcceptUnix()
+		if err != nil {
+			return nil, err
+		}
+
+		identity, err := readPeerIdentity(c)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to read peer credentials")
+			_ = c.Close()
+			continue
+		}
+
+		peer, decision := u.policy.Resolve(identity)
+		if !decision.Allowed {
+			log.Warn().
+				Uint32("uid", identity.Uid).
+				Uint32("gid", identity.Gid).
+				Int32("pid", identity.Pid).
+				Str("rule", decision.Rule).
+				Msg("rejecting connection")
+
+			_ = c.Close()
+			continue
+		}
+
+		log.Debug().
+			Uint32("uid", identity.Uid).
+			Uint32("gid", identity.Gid).
+			Int32("pid", identity.Pid).
+			Str("rule", decision.Rule).
+			Msg("accepting connection")
+
+		return &unixPeerConn{UnixConn: c, peer: peer}, nil
+	}
+}
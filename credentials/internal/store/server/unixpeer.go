@@ -0,0 +1,67 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// methods which peers on the Unix socket may call without being allowed to
+// call admin operations
+var unixPeerMethods = map[string]bool{
+	proto.CredStore_GetInfo_FullMethodName:        true,
+	proto.CredStore_GetAuthority_FullMethodName:   true,
+	proto.CredStore_GetChallenge_FullMethodName:   true,
+	proto.CredStore_ReadVaultItem_FullMethodName:  true,
+	proto.CredStore_ReadVaultItems_FullMethodName: true,
+}
+
+func checkUnixPeer(ctx context.Context, method string) error {
+	peer := access.UnixPeerFromContext(ctx)
+	if peer == nil || peer.AllowAdmin || unixPeerMethods[method] || healthMethods[method] {
+		return nil
+	}
+
+	log.Ctx(ctx).Warn().
+		Str("method", method).
+		Uint32("uid", peer.Uid).
+		Str("rule", peer.Rule).
+		Msg("rejecting admin call from unix socket peer")
+
+	return status.Error(codes.PermissionDenied, "admin calls are not permitted for this peer")
+}
+
+func unaryUnixPeerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := checkUnixPeer(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func streamUnixPeerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := checkUnixPeer(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"slices"
+)
+
+func (s *State) AddAdmin(ctx context.Context, request *proto.AdminCreation) (*proto.AdminInfo, error) {
+	actor, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	audit.SetTarget(ctx, adminRequesterPrefix+request.Name)
+
+	admin, err := s.vault.AddAdmin(request.Name, roleFromProto(request.Role), request.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().
+		Str("admin", actor).
+		Str("name", admin.Name).
+		Str("role", string(admin.Role)).
+		Msg("admin added")
+
+	return adminToProto(*admin), nil
+}
+
+func (s *State) RemoveAdmin(ctx context.Context, request *proto.AdminRemoval) error {
+	actor, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return err
+	}
+
+	if err = s.checkWritable(); err != nil {
+		return err
+	}
+
+	audit.SetTarget(ctx, adminRequesterPrefix+request.Name)
+
+	if err = s.vault.RemoveAdmin(request.Name); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("admin", actor).Str("name", request.Name).Msg("admin removed")
+
+	return nil
+}
+
+func (s *State) ListAdmins(ctx context.Context, request *proto.AdminSearch) ([]*proto.AdminInfo, error) {
+	_, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	admins := s.vault.Admins()
+
+	result := make([]*proto.AdminInfo, 0, len(admins))
+	for _, admin := range admins {
+		result = append(result, adminToProto(admin))
+	}
+
+	return result, nil
+}
+
+// roleScopes returns the session scopes available to an admin with the role
+func roleScopes(role vault.Role) []string {
+	switch role {
+	case vault.RoleAdmin:
+		return session.AllScopes
+	case vault.RoleManager:
+		return []string{session.ScopeRead, session.ScopeReveal, session.ScopeWrite}
+	default:
+		return []string{session.ScopeRead}
+	}
+}
+
+func roleGrants(role vault.Role, scope string) bool {
+	return slices.Contains(roleScopes(role), scope)
+}
+
+func roleFromProto(role proto.AdminRole) vault.Role {
+	switch role {
+	case proto.AdminRole_ADMIN:
+		return vault.RoleAdmin
+	case proto.AdminRole_MANAGER:
+		return vault.RoleManager
+	default:
+		return vault.RoleAuditor
+	}
+}
+
+func adminToProto(admin vault.Admin) *proto.AdminInfo {
+	var role proto.AdminRole
+	switch admin.Role {
+	case vault.RoleAdmin:
+		role = proto.AdminRole_ADMIN
+	case vault.RoleManager:
+		role = proto.AdminRole_MANAGER
+	default:
+		role = proto.AdminRole_AUDITOR
+	}
+
+	var createdAt int64
+	if !admin.CreatedAt.IsZero() {
+		createdAt = admin.CreatedAt.UnixMilli()
+	}
+
+	return &proto.AdminInfo{
+		Name:      admin.Name,
+		Role:      role,
+		CreatedAt: createdAt,
+	}
+}
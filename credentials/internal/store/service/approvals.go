@@ -0,0 +1,187 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/approval"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/watch"
+)
+
+const (
+	adminRequesterPrefix  = "admin:"
+	clientRequesterPrefix = "client:"
+)
+
+func (s *State) SetItemApproval(ctx context.Context, request *proto.ItemApprovalSetting) (*proto.Item, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	itemId, err := uuid.Parse(request.ItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.AddItems(ctx, itemId.String())
+
+	item, err := s.vault.SetItemRequiresApproval(itemId, request.RequiresApproval)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().
+		Str("admin", admin).
+		Str("item", item.Id.String()).
+		Bool("requires_approval", item.RequiresApproval).
+		Msg("vault item approval setting changed")
+
+	s.publishItem(watch.ItemUpdated, *item)
+
+	return itemToProto(*item), nil
+}
+
+func (s *State) ListApprovals(ctx context.Context, request *proto.ApprovalSearch) ([]*proto.ApprovalRequest, error) {
+	_, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := s.approvals.Requests(request.IncludeDecided)
+
+	result := make([]*proto.ApprovalRequest, 0, len(requests))
+	for _, r := range requests {
+		result = append(result, s.approvalToProto(r))
+	}
+
+	return result, nil
+}
+
+func (s *State) DecideApproval(ctx context.Context, request *proto.ApprovalDecision) (*proto.ApprovalRequest, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(request.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetTarget(ctx, "approval:"+id.String())
+
+	decided, err := s.approvals.Decide(id, adminRequesterPrefix+admin, request.Approve)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.AddItems(ctx, decided.ItemId.String())
+
+	return s.approvalToProto(*decided), nil
+}
+
+// redeemApproval returns nil if the requester may read the item right away,
+// otherwise the pending approval request is returned.
+func (s *State) redeemApproval(itemId uuid.UUID, requester string) (*proto.ApprovalRequest, error) {
+	item, err := s.vault.ItemMetadata(itemId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !item.RequiresApproval {
+		return nil, nil
+	}
+
+	request, ok := s.approvals.Redeem(itemId, requester)
+	if ok {
+		return nil, nil
+	}
+
+	return s.approvalToProto(*request), nil
+}
+
+func (s *State) approvalToProto(request approval.Request) *proto.ApprovalRequest {
+	var status proto.ApprovalStatus
+	switch request.Status {
+	case approval.StatusApproved:
+		status = proto.ApprovalStatus_APPROVAL_APPROVED
+	case approval.StatusDenied:
+		status = proto.ApprovalStatus_APPROVAL_DENIED
+	case approval.StatusConsumed:
+		status = proto.ApprovalStatus_APPROVAL_CONSUMED
+	case approval.StatusExpired:
+		status = proto.ApprovalStatus_APPROVAL_EXPIRED
+	default:
+		status = proto.ApprovalStatus_APPROVAL_PENDING
+	}
+
+	var description string
+	if item, err := s.vault.ItemMetadata(request.ItemId); err == nil {
+		description = item.Description
+	}
+
+	return &proto.ApprovalRequest{
+		Id:              request.Id.String(),
+		ItemId:          request.ItemId.String(),
+		ItemDescription: description,
+		Requester:       request.Requester,
+		Status:          status,
+		CreatedAt:       request.CreatedAt.UnixMilli(),
+		ExpiresAt:       request.ExpiresAt.UnixMilli(),
+		DecidedBy:       request.DecidedBy,
+	}
+}
+
+func logApprovalEvent(event approval.Event) {
+	log.Info().
+		Str("request", event.Request.Id.String()).
+		Str("item", event.Request.ItemId.String()).
+		Str("requester", event.Request.Requester).
+		Str("decided_by", event.Request.DecidedBy).
+		Msgf("approval %s", event.Type)
+}
+
+func approvalOptions(config *store.ApprovalConfig) approval.Options {
+	options := approval.Options{
+		RequestTtl:  approval.DefaultRequestTtl,
+		FetchWindow: approval.DefaultFetchWindow,
+	}
+
+	if config == nil {
+		return options
+	}
+
+	if config.RequestTtl != nil {
+		options.RequestTtl = config.RequestTtl.Duration
+	}
+
+	if config.FetchWindow != nil {
+		options.FetchWindow = config.FetchWindow.Duration
+	}
+
+	return options
+}
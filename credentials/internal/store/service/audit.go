@@ -0,0 +1,122 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"time"
+)
+
+func (s *State) QueryAuditLog(ctx context.Context, request *proto.AuditQuery) ([]*proto.AuditEntry, error) {
+	_, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := audit.Filter{
+		Operation: request.Operation,
+		Actor:     request.Actor,
+		ItemId:    request.ItemId,
+		Limit:     int(request.Limit),
+	}
+
+	if request.Since > 0 {
+		filter.Since = time.UnixMilli(request.Since)
+	}
+
+	if request.Until > 0 {
+		filter.Until = time.UnixMilli(request.Until)
+	}
+
+	for _, outcome := range request.Outcomes {
+		filter.Outcomes = append(filter.Outcomes, outcomeFromProto(outcome))
+	}
+
+	entries, err := s.audit.Query(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*proto.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, auditEntryToProto(entry))
+	}
+
+	return result, nil
+}
+
+func (s *State) VerifyAuditLog(ctx context.Context, request *proto.AuditVerificationRequest) (*proto.AuditVerification, error) {
+	_, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	verification, err := s.audit.Verify()
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.AuditVerification{
+		Valid:       verification.Valid,
+		Entries:     verification.Entries,
+		HeadSeq:     verification.HeadSeq,
+		HeadHash:    verification.HeadHash,
+		InvalidSeq:  verification.InvalidSeq,
+		Problem:     verification.Problem,
+		AnchoredSeq: verification.AnchoredSeq,
+		Gaps:        verification.Gaps,
+	}, nil
+}
+
+func outcomeFromProto(outcome proto.AuditOutcome) audit.Outcome {
+	switch outcome {
+	case proto.AuditOutcome_AUDIT_DENIED:
+		return audit.OutcomeDenied
+	case proto.AuditOutcome_AUDIT_FAILURE:
+		return audit.OutcomeFailure
+	default:
+		return audit.OutcomeSuccess
+	}
+}
+
+func auditEntryToProto(entry audit.Entry) *proto.AuditEntry {
+	var outcome proto.AuditOutcome
+	switch entry.Outcome {
+	case audit.OutcomeDenied:
+		outcome = proto.AuditOutcome_AUDIT_DENIED
+	case audit.OutcomeFailure:
+		outcome = proto.AuditOutcome_AUDIT_FAILURE
+	default:
+		outcome = proto.AuditOutcome_AUDIT_SUCCESS
+	}
+
+	return &proto.AuditEntry{
+		Seq:       entry.Seq,
+		Timestamp: entry.Timestamp.UnixMilli(),
+		Operation: entry.Operation,
+		Actor:     entry.Actor,
+		Source:    entry.Source,
+		ItemIds:   entry.ItemIds,
+		Target:    entry.Target,
+		Outcome:   outcome,
+		Error:     entry.Error,
+		PrevHash:  entry.PrevHash,
+		Hash:      entry.Hash,
+	}
+}
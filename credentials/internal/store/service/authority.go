@@ -0,0 +1,255 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/ca"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/limiter"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/watch"
+	"time"
+)
+
+const (
+	defaultAuthorityValidity = 10 * 365 * 24 * time.Hour
+	defaultTokenTtl          = 24 * time.Hour
+)
+
+func (s *State) InitAuthority(ctx context.Context, request *proto.AuthorityCreation) (*proto.AuthorityInfo, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	validity := defaultAuthorityValidity
+	if request.ValidityDays > 0 {
+		validity = time.Duration(request.ValidityDays) * 24 * time.Hour
+	}
+
+	if err = s.authority.Init(request.CommonName, validity); err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("admin", admin).Str("common_name", request.CommonName).Msg("certificate authority initialized")
+
+	return s.AuthorityInfo(), nil
+}
+
+func (s *State) AuthorityInfo() *proto.AuthorityInfo {
+	cert := s.authority.Certificate()
+	if cert == nil {
+		return &proto.AuthorityInfo{IsInitialized: false}
+	}
+
+	return &proto.AuthorityInfo{
+		IsInitialized: true,
+		Certificate:   encodeCertificate(cert),
+		ExpiresAt:     cert.NotAfter.UnixMilli(),
+	}
+}
+
+func (s *State) CreateEnrollmentToken(ctx context.Context, request *proto.EnrollmentTokenCreation) (*proto.EnrollmentToken, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	ttl := defaultTokenTtl
+	if request.TtlSeconds > 0 {
+		ttl = time.Duration(request.TtlSeconds) * time.Second
+	}
+
+	token, expiresAt, err := s.authority.CreateToken(request.CommonName, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("admin", admin).Str("common_name", request.CommonName).Msg("enrollment token created")
+
+	return &proto.EnrollmentToken{
+		Token:     token,
+		ExpiresAt: expiresAt.UnixMilli(),
+	}, nil
+}
+
+func (s *State) EnrollClient(ctx context.Context, request *proto.EnrollmentRequest) (*proto.Enrollment, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	enrollment, err := s.authority.Enroll(request.Csr, request.Token)
+	if errors.Is(err, ca.ErrInvalidToken) {
+		return nil, authenticationError(err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if request.Token != "" {
+		limiter.Authenticated(ctx, limiter.Other)
+	}
+
+	return enrollmentToProto(enrollment), nil
+}
+
+func (s *State) GetEnrollment(request *proto.EnrollmentQuery) (*proto.Enrollment, error) {
+	id, err := uuid.Parse(request.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment, err := s.authority.Enrollment(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return enrollmentToProto(enrollment), nil
+}
+
+func (s *State) ListEnrollments(ctx context.Context, request *proto.EnrollmentSearch) ([]*proto.Enrollment, error) {
+	_, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollments := s.authority.Enrollments(request.IncludeIssued)
+
+	result := make([]*proto.Enrollment, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		result = append(result, enrollmentToProto(&enrollment))
+	}
+
+	return result, nil
+}
+
+func (s *State) DecideEnrollment(ctx context.Context, request *proto.EnrollmentDecision) (*proto.Enrollment, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(request.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetTarget(ctx, "enrollment:"+id.String())
+
+	enrollment, err := s.authority.Decide(id, request.Approve)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().
+		Str("admin", admin).
+		Str("enrollment", id.String()).
+		Bool("approved", request.Approve).
+		Msg("enrollment decided")
+
+	if enrollment.Status == ca.EnrollmentApproved {
+		s.publishClient(watch.ClientCreated, enrollment.CommonName)
+	}
+
+	return enrollmentToProto(enrollment), nil
+}
+
+func (s *State) RevokeCertificate(ctx context.Context, request *proto.CertificateRevocation) (*proto.Enrollment, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	audit.SetTarget(ctx, "certificate:"+request.Serial)
+
+	enrollment, err := s.authority.Revoke(request.Serial)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("admin", admin).Str("serial", request.Serial).Msg("certificate revoked")
+
+	s.publishClient(watch.ClientRevoked, enrollment.CommonName)
+
+	return enrollmentToProto(enrollment), nil
+}
+
+func (s *State) RenewCertificate(peer *x509.Certificate, request *proto.RenewalRequest) (*proto.Enrollment, error) {
+	if peer == nil {
+		return nil, errors.New("renewal requires a client certificate")
+	}
+
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	enrollment, err := s.authority.Renew(peer, request.Csr)
+	if err != nil {
+		return nil, err
+	}
+
+	return enrollmentToProto(enrollment), nil
+}
+
+func enrollmentToProto(enrollment *ca.Enrollment) *proto.Enrollment {
+	var status proto.EnrollmentStatus
+	switch enrollment.Status {
+	case ca.EnrollmentApproved:
+		status = proto.EnrollmentStatus_APPROVED
+	case ca.EnrollmentDenied:
+		status = proto.EnrollmentStatus_DENIED
+	case ca.EnrollmentRevoked:
+		status = proto.EnrollmentStatus_REVOKED
+	default:
+		status = proto.EnrollmentStatus_PENDING
+	}
+
+	return &proto.Enrollment{
+		Id:          enrollment.Id.String(),
+		CommonName:  enrollment.CommonName,
+		Status:      status,
+		Certificate: enrollment.Certificate,
+		Serial:      enrollment.Serial,
+		CreatedAt:   enrollment.CreatedAt.UnixMilli(),
+		ExpiresAt:   enrollment.ExpiresAt.UnixMilli(),
+	}
+}
+
+func encodeCertificate(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
@@ -16,26 +16,56 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"github.com/awnumar/memguard"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/watch"
+	"google.golang.org/grpc/peer"
+	"net"
+	"strings"
 	"unsafe"
 )
 
-func (s *State) CreateClientCredentials(request *proto.ClientCreation) (*proto.ClientCredentials, error) {
-	err := s.vault.VerifyPassphrase(request.GetCredentials().Passphrase)
+// clientKeyPrefix marks client items holding a public key instead of a
+// secret, these can only authenticate by signing a challenge
+const clientKeyPrefix = "ed25519:"
+
+// clientItemPrefix marks the descriptions of items holding client
+// credentials
+const clientItemPrefix = "CC["
+
+func (s *State) CreateClientCredentials(ctx context.Context, request *proto.ClientCreation) (*proto.ClientCredentials, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeWrite)
 	if err != nil {
 		return nil, err
 	}
 
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	if len(request.PublicKey) > 0 {
+		return s.createClientKey(ctx, admin, request)
+	}
+
 	randStr := rand.Text()
 
 	secretBuffer := memguard.NewBufferFromBytes(*(*[]byte)(unsafe.Pointer(&randStr)))
 	defer secretBuffer.Destroy()
 
-	item, err := s.vault.CreateItem("CC[" + request.Description + "]")
+	item, err := s.vault.CreateItem(clientItemPrefix + request.Description + "]")
 	if err != nil {
 		return nil, err
 	}
@@ -51,30 +81,178 @@ func (s *State) CreateClientCredentials(request *proto.ClientCreation) (*proto.C
 	}
 	defer secret.Destroy()
 
+	audit.SetTarget(ctx, clientRequesterPrefix+item.Id.String())
+
+	log.Ctx(ctx).Info().Str("admin", admin).Str("client", item.Id.String()).Msg("client credentials created")
+
+	s.publishClient(watch.ClientCreated, item.Id.String())
+
 	return &proto.ClientCredentials{
 		Id:     item.Id.String(),
 		Secret: string(secret.Bytes()),
 	}, nil
 }
 
-func (s *State) verifyClientCredentials(credentials *proto.ClientCredentials) error {
-	defer memguard.WipeBytes(*(*[]byte)(unsafe.Pointer(&credentials.Secret)))
+func (s *State) createClientKey(ctx context.Context, admin string, request *proto.ClientCreation) (*proto.ClientCredentials, error) {
+	if len(request.PublicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 public key")
+	}
+
+	value := memguard.NewBufferFromBytes([]byte(clientKeyPrefix + base64.StdEncoding.EncodeToString(request.PublicKey)))
+	defer value.Destroy()
+
+	item, err := s.vault.CreateItem(clientItemPrefix + request.Description + "]")
+	if err != nil {
+		return nil, err
+	}
 
-	itemId, err := uuid.Parse(credentials.Id)
+	err = s.vault.SetItemValue(item.Id, value)
 	if err != nil {
-		return errors.New("client credentials mismatch")
+		_ = s.vault.DeleteItem(item.Id)
+		return nil, err
+	}
+
+	audit.SetTarget(ctx, clientRequesterPrefix+item.Id.String())
+
+	log.Ctx(ctx).Info().Str("admin", admin).Str("client", item.Id.String()).Msg("client key registered")
+
+	s.publishClient(watch.ClientCreated, item.Id.String())
+
+	return &proto.ClientCredentials{Id: item.Id.String()}, nil
+}
+
+func (s *State) GetChallenge(ctx context.Context, request *proto.ChallengeRequest) (*proto.Challenge, error) {
+	if s.vault.IsLocked() {
+		return nil, errors.New("vault is locked")
 	}
 
-	item, err := s.vault.GetItem(itemId)
+	if _, err := uuid.Parse(request.ClientId); err != nil {
+		return nil, err
+	}
+
+	issued, err := s.challenges.Issue(request.ClientId, access.SourceAddress(ctx))
 	if err != nil {
-		return errors.New("client credentials mismatch")
+		log.Ctx(ctx).Warn().Err(err).Str("client", request.ClientId).Msg("rejecting challenge request")
+		return nil, err
+	}
+
+	return &proto.Challenge{
+		Nonce:     issued.Nonce,
+		ExpiresAt: issued.ExpiresAt.UnixMilli(),
+	}, nil
+}
+
+// checkClientSource applies the client's source restrictions of the access
+// policy, which can only be done once the client is authenticated.
+func (s *State) checkClientSource(ctx context.Context, clientId string) error {
+	// the unix socket policy applies to local peers instead
+	if access.UnixPeerFromContext(ctx) != nil {
+		return nil
+	}
+
+	var remoteIP net.IP
+	if p, ok := peer.FromContext(ctx); ok {
+		if tcpAddr, ok := p.Addr.(*net.TCPAddr); ok {
+			remoteIP = tcpAddr.IP
+		}
+	}
+
+	decision := s.access.CheckClient(clientId, remoteIP)
+	if !decision.Allowed {
+		log.Ctx(ctx).Warn().
+			Str("client", clientId).
+			Str("source", remoteIP.String()).
+			Str("rule", decision.Rule).
+			Msg("rejecting client")
+
+		return permissionError(errors.New("client may not connect from this address"))
+	}
+
+	return nil
+}
+
+func (s *State) verifyClientCredentials(credentials *proto.ClientCredentials) error {
+	defer memguard.WipeBytes(*(*[]byte)(unsafe.Pointer(&credentials.Secret)))
+
+	if s.vault.IsLocked() {
+		return errors.New("vault is locked")
+	}
+
+	item, err := s.clientItemValue(credentials.Id)
+	if err != nil || item == nil {
+		return authenticationError(errors.New("client credentials mismatch"))
 	}
 
 	defer item.Destroy()
 
-	if credentials.Secret != item.String() {
-		return errors.New("client credentials mismatch")
+	// key clients never authenticate with the stored value itself
+	if bytes.HasPrefix(item.Bytes(), []byte(clientKeyPrefix)) {
+		return authenticationError(errors.New("client credentials mismatch"))
+	}
+
+	if subtle.ConstantTimeCompare([]byte(credentials.Secret), item.Bytes()) != 1 {
+		return authenticationError(errors.New("client credentials mismatch"))
 	}
 
 	return nil
 }
+
+// verifyClientKey checks the signature of a nonce previously issued by
+// GetChallenge against the public key registered for the client.
+func (s *State) verifyClientKey(credentials *proto.ClientKeyCredentials) error {
+	if s.vault.IsLocked() {
+		return errors.New("vault is locked")
+	}
+
+	if !s.challenges.Redeem(credentials.Id, credentials.Nonce) {
+		return authenticationError(errors.New("invalid or expired challenge"))
+	}
+
+	item, err := s.clientItemValue(credentials.Id)
+	if err != nil || item == nil {
+		return authenticationError(errors.New("client signature mismatch"))
+	}
+
+	defer item.Destroy()
+
+	encoded, ok := bytes.CutPrefix(item.Bytes(), []byte(clientKeyPrefix))
+	if !ok {
+		return authenticationError(errors.New("client signature mismatch"))
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return authenticationError(errors.New("client signature mismatch"))
+	}
+
+	if !ed25519.Verify(publicKey, credentials.Nonce, credentials.Signature) {
+		return authenticationError(errors.New("client signature mismatch"))
+	}
+
+	return nil
+}
+
+// clientItemValue reads the stored credential of a client, without counting
+// it as a read of the item. Only client items are read at all, so guessing
+// credentials for ordinary items neither consumes nor deletes them.
+func (s *State) clientItemValue(clientId string) (*memguard.LockedBuffer, error) {
+	itemId, err := uuid.Parse(clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := s.vault.ItemMetadata(itemId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isClientItem(*item) {
+		return nil, errors.New("not a client: " + clientId)
+	}
+
+	return s.vault.PeekItem(itemId)
+}
+
+func isClientItem(item vault.Item) bool {
+	return strings.HasPrefix(item.Description, clientItemPrefix)
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"github.com/awnumar/memguard"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyClient_LimitedItemIsntRead(t *testing.T) {
+	state := newTestState(t)
+
+	item, err := state.createItem("one-time password", nil, false, 2, 0, memguard.NewBufferFromBytes([]byte("one-time value")))
+	require.NoError(t, err)
+
+	// not even the correct value authenticates an ordinary item
+	for _, secret := range []string{"wrong secret", "one-time value"} {
+		err = state.verifyClientCredentials(&proto.ClientCredentials{Id: item.Id.String(), Secret: strings.Clone(secret)})
+		assert.IsType(t, &AuthenticationError{}, err)
+	}
+
+	challenge, err := state.challenges.Issue(item.Id.String(), "10.0.0.1")
+	require.NoError(t, err)
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	err = state.verifyClientKey(&proto.ClientKeyCredentials{
+		Id:        item.Id.String(),
+		Nonce:     challenge.Nonce,
+		Signature: ed25519.Sign(privateKey, challenge.Nonce),
+	})
+	assert.IsType(t, &AuthenticationError{}, err)
+
+	metadata, err := state.vault.ItemMetadata(item.Id)
+	require.NoError(t, err)
+	assert.Zero(t, metadata.Reads)
+
+	client, err := state.CreateClientCredentials(context.Background(), &proto.ClientCreation{
+		Credentials: &proto.AdminCredentials{Passphrase: passphrase()},
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, state.verifyClientCredentials(client))
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/replication"
+)
+
+// ValidateConfig checks the configuration including the settings which are
+// resolved against the system, e.g. network rules and unix users.
+func ValidateConfig(config *store.Config, prod bool) error {
+	errs := []error{config.Validate(prod)}
+	if _, err := access.NewPolicy(config.Access, prod); err != nil {
+		errs = append(errs, fmt.Errorf("Access: %v", err))
+	}
+
+	if config.UnixSocket != nil {
+		if _, err := access.NewUnixPolicy(config.UnixSocket); err != nil {
+			errs = append(errs, fmt.Errorf("UnixSocket: %v", err))
+		}
+	}
+
+	if config.Replication != nil && config.Replication.TokenFile != "" {
+		if _, err := replication.LoadToken(config.Replication.TokenFile); err != nil {
+			errs = append(errs, fmt.Errorf("Replication.TokenFile: %v", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Reload applies the settings which can be changed at runtime: the access
+// policies, rate limits (including auto-locking) and approval timers. Other
+// changed settings are reported and only take effect after a restart. Nothing
+// is applied if the configuration is invalid.
+func (s *State) Reload(config *store.Config) error {
+	if err := ValidateConfig(config, s.isProduction); err != nil {
+		return err
+	}
+
+	current := s.Config()
+
+	if err := s.access.Update(config.Access, s.isProduction); err != nil {
+		return err
+	}
+
+	if s.unixPolicy != nil && config.UnixSocket != nil {
+		if err := s.unixPolicy.Update(config.UnixSocket); err != nil {
+			return err
+		}
+	}
+
+	s.limiter.SetOptions(limiterOptions(config.RateLimit))
+	s.approvals.SetOptions(approvalOptions(config.Approval))
+
+	for _, key := range current.RestartRequired(config) {
+		log.Warn().Str("setting", key).Msg("changed setting requires a restart")
+	}
+
+	s.config.Store(withRestartSettings(config, current))
+
+	return nil
+}
+
+// withRestartSettings returns a copy of the config which keeps the settings
+// requiring a restart at the values currently in effect
+func withRestartSettings(config *store.Config, current *store.Config) *store.Config {
+	applied := *config
+	applied.StoragePath = current.StoragePath
+	applied.ListenAddress = current.ListenAddress
+	applied.Tls = current.Tls
+	applied.Metrics = current.Metrics
+	applied.Reflection = current.Reflection
+	applied.Transport = current.Transport
+	applied.Gateway = current.Gateway
+	applied.Replication = current.Replication
+	applied.Logging = current.Logging
+	applied.UnixSocket = current.UnixSocket
+
+	if current.UnixSocket != nil && config.UnixSocket != nil {
+		unixSocket := *config.UnixSocket
+		unixSocket.Path = current.UnixSocket.Path
+		unixSocket.Mode = current.UnixSocket.Mode
+
+		applied.UnixSocket = &unixSocket
+	}
+
+	return &applied
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+// AuthenticationError marks failures caused by invalid or missing
+// credentials, as opposed to any other failure while handling a request.
+type AuthenticationError struct {
+	err error
+}
+
+func (e *AuthenticationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *AuthenticationError) Unwrap() error {
+	return e.err
+}
+
+func authenticationError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &AuthenticationError{err: err}
+}
+
+// PermissionError marks requests by an authenticated admin whose role or
+// session doesn't grant access to the requested operation.
+type PermissionError struct {
+	err error
+}
+
+func (e *PermissionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.err
+}
+
+func permissionError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PermissionError{err: err}
+}
+
+// ReplicaError marks requests which would change the vault of a replica,
+// changes can only be made on the primary.
+type ReplicaError struct {
+	err error
+}
+
+func (e *ReplicaError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ReplicaError) Unwrap() error {
+	return e.err
+}
+
+func replicaError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &ReplicaError{err: err}
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/awnumar/memguard"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/generate"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/watch"
+)
+
+func (s *State) GenerateVaultItem(ctx context.Context, request *proto.ItemGeneration) (*proto.GeneratedItem, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	value, publicKey, err := generateValue(request)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := s.createItem(request.Description, request.Labels, request.RequiresApproval, request.MaxReads, request.TtlSeconds, value)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.AddItems(ctx, item.Id.String())
+
+	log.Ctx(ctx).Info().Str("admin", admin).Str("item", item.Id.String()).Msg("vault item generated")
+
+	s.publishItem(watch.ItemCreated, *item)
+
+	return &proto.GeneratedItem{
+		Item:      itemToProto(*item),
+		PublicKey: publicKey,
+	}, nil
+}
+
+func generateValue(request *proto.ItemGeneration) (*memguard.LockedBuffer, string, error) {
+	switch policy := request.Policy.(type) {
+	case *proto.ItemGeneration_Password:
+		var classes []generate.CharacterClass
+		for _, class := range policy.Password.GetCharacterClasses() {
+			classes = append(classes, generate.CharacterClass(class))
+		}
+
+		value, err := generate.Password(int(policy.Password.GetLength()), classes)
+		return value, "", err
+	case *proto.ItemGeneration_Passphrase:
+		value, err := generate.Passphrase(int(policy.Passphrase.GetWords()), policy.Passphrase.GetSeparator())
+		return value, "", err
+	case *proto.ItemGeneration_RandomBytes:
+		value, err := generate.RandomBytes(int(policy.RandomBytes.GetLength()))
+		return value, "", err
+	case *proto.ItemGeneration_KeyPair:
+		switch policy.KeyPair.GetType() {
+		case proto.KeyType_KEY_TYPE_X25519:
+			return generate.X25519KeyPair()
+		case proto.KeyType_KEY_TYPE_ED25519:
+			return generate.Ed25519KeyPair(request.Description)
+		default:
+			return nil, "", errors.New("unknown key type")
+		}
+	default:
+		return nil, "", errors.New("generation policy is required")
+	}
+}
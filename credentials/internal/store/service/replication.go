@@ -0,0 +1,268 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/limiter"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/replication"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/watch"
+	"google.golang.org/grpc/peer"
+	"net"
+	"reflect"
+	"time"
+)
+
+const replicaActor = "replica"
+
+type promotion struct {
+	PromotedAt time.Time `json:"promoted_at"`
+	Primary    string    `json:"primary"`
+	Admin      string    `json:"admin"`
+}
+
+func (s *State) initReplication(config *store.ReplicationConfig) error {
+	token, err := replication.LoadToken(config.TokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to load replication token: %v", err)
+	}
+
+	s.replicationToken = token
+	s.replicas = replication.NewReplicas()
+
+	marker, err := s.vault.Options().Backend.ReadFile(replication.PromotedPath)
+	if err != nil {
+		return err
+	}
+
+	s.promoted.Store(marker != nil)
+
+	if config.Primary == "" {
+		return nil
+	} else if marker != nil {
+		log.Warn().
+			Str("primary", config.Primary).
+			Msg("store was promoted, not replicating from the configured primary anymore")
+
+		return nil
+	}
+
+	replica, err := replication.NewReplica(config, token, s.vault.Options().Backend, s.isProduction, s.reloadReplicated)
+	if err != nil {
+		return fmt.Errorf("failed to set up replication: %v", err)
+	}
+
+	s.replica.Store(replica)
+
+	return nil
+}
+
+// StartReplication starts pulling changes from the primary if this store
+// is a replica
+func (s *State) StartReplication() {
+	if replica := s.replica.Load(); replica != nil {
+		log.Info().Str("primary", replica.Primary()).Msg("replicating from primary")
+		replica.Start()
+	}
+}
+
+func (s *State) StopReplication() {
+	if replica := s.replica.Load(); replica != nil {
+		replica.Stop()
+	}
+}
+
+func (s *State) IsReplica() bool {
+	return s.replica.Load() != nil
+}
+
+// Replicate sends the files which differ from the ones the replica has
+func (s *State) Replicate(ctx context.Context, request *proto.ReplicationRequest, send func(*proto.ReplicatedFile) error) error {
+	audit.SetActor(ctx, replicaActor)
+
+	if s.replicationToken == nil {
+		return permissionError(errors.New("replication is not enabled"))
+	}
+
+	if !replication.VerifyToken(s.replicationToken, request.Token) {
+		return authenticationError(errors.New("invalid replication token"))
+	}
+
+	limiter.Authenticated(ctx, limiter.Other)
+
+	known := make(map[string]string, len(request.Files))
+	for _, file := range request.Files {
+		known[file.Path] = file.Checksum
+	}
+
+	changes, err := replication.Changes(s.vault.Options().Backend, known)
+	if err != nil {
+		return err
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		source := p.Addr.String()
+		if tcpAddr, ok := p.Addr.(*net.TCPAddr); ok {
+			source = tcpAddr.IP.String()
+		}
+
+		s.replicas.Seen(source)
+	}
+
+	for _, change := range changes {
+		err = send(&proto.ReplicatedFile{
+			Path:     change.Path,
+			Checksum: change.Checksum,
+			Data:     change.Data,
+			Deleted:  change.Deleted,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PromoteReplica stops the replication and makes this store the primary,
+// which is remembered across restarts
+func (s *State) PromoteReplica(ctx context.Context, request *proto.AdminCredentials) (*proto.StoreInfo, error) {
+	admin, err := s.authenticateAdmin(ctx, request, session.ScopeAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	replica := s.replica.Load()
+	if replica == nil {
+		return nil, errors.New("store is not a replica")
+	}
+
+	marker, err := json.Marshal(promotion{PromotedAt: time.Now(), Primary: replica.Primary(), Admin: admin})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.vault.Options().Backend.WriteFile(replication.PromotedPath, marker); err != nil {
+		return nil, err
+	}
+
+	if !s.replica.CompareAndSwap(replica, nil) {
+		return nil, errors.New("store is being promoted already")
+	}
+
+	replica.Stop()
+	s.promoted.Store(true)
+
+	log.Ctx(ctx).Warn().
+		Str("admin", admin).
+		Str("primary", replica.Primary()).
+		Msg("replica promoted, make sure the former primary doesn't serve anymore")
+
+	return s.StoreInfo(), nil
+}
+
+// checkWritable rejects changes to the vault of a replica, they'd be
+// overwritten by the next pull
+func (s *State) checkWritable() error {
+	if s.IsReplica() {
+		return replicaError(errors.New("store is a replica, changes can only be made on the primary"))
+	}
+
+	return nil
+}
+
+// reloadReplicated applies the files written by the replication and
+// publishes the resulting item changes
+func (s *State) reloadReplicated() {
+	before := make(map[string]vault.Item)
+	for _, item := range s.vault.Items() {
+		before[item.Id.String()] = item
+	}
+
+	if err := s.vault.Reload(); err != nil {
+		log.Error().Err(err).Msg("failed to reload replicated vault")
+	}
+
+	if err := s.authority.Reload(); err != nil {
+		log.Error().Err(err).Msg("failed to reload replicated certificate authority")
+	}
+
+	// nothing is known about the items while locked
+	if s.vault.IsLocked() {
+		return
+	}
+
+	for _, item := range s.vault.Items() {
+		previous, ok := before[item.Id.String()]
+		delete(before, item.Id.String())
+
+		if !ok {
+			s.publishItem(watch.ItemCreated, item)
+		} else if !reflect.DeepEqual(previous, item) {
+			s.publishItem(watch.ItemUpdated, item)
+		}
+	}
+
+	for _, item := range before {
+		s.publishItem(watch.ItemDeleted, item)
+	}
+}
+
+func (s *State) replicationStatus() *proto.ReplicationStatus {
+	if s.replicationToken == nil {
+		return nil
+	}
+
+	result := &proto.ReplicationStatus{
+		Role:     proto.ReplicationRole_REPLICATION_PRIMARY,
+		Promoted: s.promoted.Load(),
+	}
+
+	for _, replica := range s.replicas.List() {
+		result.Replicas = append(result.Replicas, &proto.ReplicaInfo{
+			Source:     replica.Source,
+			LastSeenAt: replica.LastSeenAt.UnixMilli(),
+		})
+	}
+
+	if replica := s.replica.Load(); replica != nil {
+		status := replica.Status()
+
+		result.Role = proto.ReplicationRole_REPLICATION_REPLICA
+		result.Primary = replica.Primary()
+
+		if !status.LastSyncAt.IsZero() {
+			result.LastSyncAt = status.LastSyncAt.UnixMilli()
+			result.LagMillis = time.Since(status.LastSyncAt).Milliseconds()
+		}
+
+		if status.LastError != nil {
+			result.LastError = status.LastError.Error()
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,141 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/labels"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ItemPage is a page of the vault items matching a search
+type ItemPage struct {
+	Items []*proto.Item
+	// the number of items in the vault
+	Total int
+	// the number of items matching the search, across all pages
+	Matched int
+	// empty if this is the last page
+	NextPageToken string
+}
+
+// searchItems filters and sorts the items, returning the requested page.
+// Items with equal sort keys are ordered by ID, so pages are stable as long
+// as the vault isn't changed.
+func searchItems(items []vault.Item, search *proto.ItemSearch) (*ItemPage, error) {
+	offset := 0
+	if search.PageToken != "" {
+		parsed, err := strconv.Atoi(search.PageToken)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid page token: %s", search.PageToken)
+		}
+
+		offset = parsed
+	}
+
+	query := strings.ToLower(strings.TrimSpace(search.Query))
+
+	var selector labels.Selector
+	if search.Selector != "" {
+		parsed, err := labels.Parse(search.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		selector = parsed
+	}
+
+	matched := make([]vault.Item, 0, len(items))
+	for _, item := range items {
+		if matchesItem(item, query, search.ClientItems) && selector.Matches(item.Labels) {
+			matched = append(matched, item)
+		}
+	}
+
+	slices.SortFunc(matched, func(a, b vault.Item) int {
+		var result int
+		switch search.Sort {
+		case proto.ItemSort_ITEM_SORT_MODIFIED:
+			result = a.ModifiedAt.Compare(b.ModifiedAt)
+			if result == 0 {
+				result = cmp.Compare(a.Id.String(), b.Id.String())
+			}
+		default:
+			result = compareItems(a, b)
+		}
+
+		if search.Descending {
+			return -result
+		}
+
+		return result
+	})
+
+	page := &ItemPage{
+		Total:   len(items),
+		Matched: len(matched),
+	}
+
+	end := len(matched)
+	if search.PageSize > 0 && offset+int(search.PageSize) < end {
+		end = offset + int(search.PageSize)
+		page.NextPageToken = strconv.Itoa(end)
+	}
+
+	if offset < end {
+		page.Items = make([]*proto.Item, 0, end-offset)
+		for _, item := range matched[offset:end] {
+			page.Items = append(page.Items, itemToProto(item))
+		}
+	}
+
+	return page, nil
+}
+
+// compareItems orders items by description, ignoring case, then by ID
+func compareItems(a, b vault.Item) int {
+	result := cmp.Compare(strings.ToLower(a.Description), strings.ToLower(b.Description))
+	if result == 0 {
+		result = cmp.Compare(a.Id.String(), b.Id.String())
+	}
+
+	return result
+}
+
+func matchesItem(item vault.Item, query string, clientItems proto.ClientItemFilter) bool {
+	switch clientItems {
+	case proto.ClientItemFilter_CLIENT_ITEMS_EXCLUDE:
+		if isClientItem(item) {
+			return false
+		}
+	case proto.ClientItemFilter_CLIENT_ITEMS_ONLY:
+		if !isClientItem(item) {
+			return false
+		}
+	}
+
+	if query == "" {
+		return true
+	}
+
+	return strings.Contains(strings.ToLower(item.Description), query) ||
+		strings.HasPrefix(item.Id.String(), query)
+}
@@ -0,0 +1,110 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testItems() []vault.Item {
+	now := time.Now()
+
+	return []vault.Item{
+		{Id: uuid.New(), Description: "database password", ModifiedAt: now.Add(-time.Hour), Labels: map[string]string{"env": "prod"}},
+		{Id: uuid.New(), Description: "API token", ModifiedAt: now, Labels: map[string]string{"env": "dev"}},
+		{Id: uuid.New(), Description: clientItemPrefix + "backup]", ModifiedAt: now.Add(-2 * time.Hour)},
+		{Id: uuid.New(), Description: "Backup passphrase", ModifiedAt: now.Add(-3 * time.Hour), Labels: map[string]string{"env": "prod", "backup": ""}},
+	}
+}
+
+func descriptions(page *ItemPage) []string {
+	var result []string
+	for _, item := range page.Items {
+		result = append(result, item.Description)
+	}
+
+	return result
+}
+
+func TestSearchItems_Sort(t *testing.T) {
+	items := testItems()
+
+	page, err := searchItems(items, &proto.ItemSearch{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"API token", "Backup passphrase", "CC[backup]", "database password"}, descriptions(page))
+
+	page, err = searchItems(items, &proto.ItemSearch{Sort: proto.ItemSort_ITEM_SORT_MODIFIED, Descending: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"API token", "database password", "CC[backup]", "Backup passphrase"}, descriptions(page))
+}
+
+func TestSearchItems_Filter(t *testing.T) {
+	items := testItems()
+
+	page, err := searchItems(items, &proto.ItemSearch{Query: "BACKUP"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Backup passphrase", "CC[backup]"}, descriptions(page))
+	assert.Equal(t, 4, page.Total)
+	assert.Equal(t, 2, page.Matched)
+
+	page, err = searchItems(items, &proto.ItemSearch{Query: "backup", ClientItems: proto.ClientItemFilter_CLIENT_ITEMS_EXCLUDE})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Backup passphrase"}, descriptions(page))
+
+	page, err = searchItems(items, &proto.ItemSearch{ClientItems: proto.ClientItemFilter_CLIENT_ITEMS_ONLY})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CC[backup]"}, descriptions(page))
+
+	page, err = searchItems(items, &proto.ItemSearch{Query: items[0].Id.String()[:8]})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"database password"}, descriptions(page))
+
+	page, err = searchItems(items, &proto.ItemSearch{Selector: "env=prod,!backup"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"database password"}, descriptions(page))
+
+	_, err = searchItems(items, &proto.ItemSearch{Selector: "env=="})
+	assert.Error(t, err)
+}
+
+func TestSearchItems_Pages(t *testing.T) {
+	items := testItems()
+
+	page, err := searchItems(items, &proto.ItemSearch{PageSize: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"API token", "Backup passphrase", "CC[backup]"}, descriptions(page))
+	assert.Equal(t, "3", page.NextPageToken)
+
+	page, err = searchItems(items, &proto.ItemSearch{PageSize: 3, PageToken: page.NextPageToken})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"database password"}, descriptions(page))
+	assert.Empty(t, page.NextPageToken)
+	assert.Equal(t, 4, page.Matched)
+
+	page, err = searchItems(items, &proto.ItemSearch{PageToken: "10"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	_, err = searchItems(items, &proto.ItemSearch{PageToken: "nope"})
+	assert.Error(t, err)
+}
@@ -16,29 +16,159 @@
 package service
 
 import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"filippo.io/age"
+	"github.com/awnumar/memguard"
+	"github.com/rs/zerolog/log"
 	"github.com/vemilyus/borg-collective/credentials/internal/proto"
 	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/approval"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/ca"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/challenge"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/limiter"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/replication"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
 	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/watch"
+	"sync/atomic"
+	"time"
 )
 
 type State struct {
-	config       *store.Config
-	vault        *vault.Vault
-	version      string
-	isProduction bool
+	config     atomic.Pointer[store.Config]
+	vault      *vault.Vault
+	authority  *ca.Authority
+	sessions   *session.Manager
+	limiter    *limiter.Limiter
+	approvals  *approval.Registry
+	challenges *challenge.Registry
+	access     *access.Policy
+	audit      *audit.Log
+	unixPolicy *access.UnixPolicy
+	watches    *watch.Hub
+	// set if replication is configured
+	replicationToken *memguard.Enclave
+	replicas         *replication.Replicas
+	replica          atomic.Pointer[replication.Replica]
+	promoted         atomic.Bool
+	version          string
+	isProduction     bool
+	lockHooks        []func(locked bool)
+	startedAt        time.Time
+	lastUnlock       atomic.Pointer[unlockRecord]
+	// nil if the store doesn't use TLS
+	tlsCertificate func() *x509.Certificate
 }
 
-func NewState(config *store.Config, vault *vault.Vault, version string, prod bool) *State {
-	return &State{
-		config:       config,
+func NewState(config *store.Config, vault *vault.Vault, version string, prod bool) (*State, error) {
+	authority, err := ca.NewAuthority(vault)
+	if err != nil {
+		return nil, err
+	}
+
+	accessPolicy, err := access.NewPolicy(config.Access, prod)
+	if err != nil {
+		return nil, err
+	}
+
+	var unixPolicy *access.UnixPolicy
+	if config.UnixSocket != nil {
+		if unixPolicy, err = access.NewUnixPolicy(config.UnixSocket); err != nil {
+			return nil, err
+		}
+	}
+
+	auditLog, err := audit.Open(vault.Options().Backend, vault)
+	if err != nil {
+		return nil, err
+	}
+
+	approvals := approval.NewRegistry(approvalOptions(config.Approval))
+	approvals.OnEvent(logApprovalEvent)
+
+	state := &State{
 		vault:        vault,
+		authority:    authority,
+		sessions:     session.NewManager(),
+		limiter:      limiter.New(limiterOptions(config.RateLimit)),
+		approvals:    approvals,
+		challenges:   challenge.NewRegistry(challenge.DefaultTtl),
+		access:       accessPolicy,
+		audit:        auditLog,
+		unixPolicy:   unixPolicy,
+		watches:      watch.NewHub(),
 		version:      version,
 		isProduction: prod,
+		startedAt:    time.Now(),
+	}
+
+	state.config.Store(config)
+	state.OnLockChange(state.publishLockChange)
+
+	if config.Replication != nil {
+		if err = state.initReplication(config.Replication); err != nil {
+			return nil, err
+		}
 	}
+
+	return state, nil
 }
 
 func (s *State) Config() *store.Config {
-	return s.config
+	return s.config.Load()
+}
+
+func (s *State) Authority() *ca.Authority {
+	return s.authority
+}
+
+func (s *State) Limiter() *limiter.Limiter {
+	return s.limiter
+}
+
+func (s *State) AccessPolicy() *access.Policy {
+	return s.access
+}
+
+func (s *State) AuditLog() *audit.Log {
+	return s.audit
+}
+
+func (s *State) UnixPolicy() *access.UnixPolicy {
+	return s.unixPolicy
+}
+
+func (s *State) IsLocked() bool {
+	return s.vault.IsLocked()
+}
+
+// ItemCount returns the number of items in the vault, 0 while locked
+func (s *State) ItemCount() int {
+	return len(s.vault.Items())
+}
+
+// OnLockChange registers a hook called whenever the vault is locked or
+// unlocked, hooks have to be registered before serving any requests.
+func (s *State) OnLockChange(hook func(locked bool)) {
+	s.lockHooks = append(s.lockHooks, hook)
+}
+
+func (s *State) notifyLockChange() {
+	locked := s.vault.IsLocked()
+
+	// the certificate authority can only be authenticated while unlocked
+	if !locked {
+		if err := s.authority.Reload(); err != nil {
+			log.Error().Err(err).Msg("failed to authenticate certificate authority, no client certificate is trusted")
+		}
+	}
+	for _, hook := range s.lockHooks {
+		hook(locked)
+	}
 }
 
 func (s *State) IsProduction() bool {
@@ -47,14 +177,104 @@ func (s *State) IsProduction() bool {
 
 func (s *State) StoreInfo() *proto.StoreInfo {
 	return &proto.StoreInfo{
-		Version:       s.version,
-		IsVaultLocked: s.vault.IsLocked(),
-		IsProduction:  s.IsProduction(),
+		Version:            s.version,
+		IsVaultLocked:      s.vault.IsLocked(),
+		IsProduction:       s.IsProduction(),
+		IsVaultInitialized: s.isInitialized(),
+		IsReplica:          s.IsReplica(),
 	}
 }
 
-func (s *State) Unlock(request *proto.AdminCredentials) error {
-	return s.vault.Unlock(request.Passphrase)
+func (s *State) isInitialized() bool {
+	initialized, err := s.vault.HasIdentity()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to check for the vault identity")
+	}
+
+	return initialized
+}
+
+func (s *State) Unlock(ctx context.Context, request *proto.AdminCredentials) error {
+	audit.SetActor(ctx, adminActor(request.GetName()))
+
+	// a replica only uses the vault of its primary
+	if s.IsReplica() {
+		if created, err := s.vault.HasIdentity(); err != nil || !created {
+			return errors.New("the vault wasn't replicated from the primary yet")
+		}
+	}
+
+	admin, err := s.vault.UnlockAs(request.GetName(), request.GetPassphrase())
+	if errors.Is(err, vault.ErrNotInitialized) || errors.Is(err, vault.ErrIncompatible) {
+		return err
+	} else if err != nil {
+		return authenticationError(err)
+	}
+
+	limiter.Authenticated(ctx, limiter.Admin)
+
+	log.Ctx(ctx).Info().Str("admin", admin.Name).Msg("vault unlocked")
+
+	// replicas receive the marker from their primary
+	if !s.IsReplica() {
+		if marker, err := s.vault.CreateMarker(); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to write vault marker")
+		} else if marker != nil {
+			log.Ctx(ctx).Info().Str("vault", marker.Id.String()).Msg("vault marker created for existing vault")
+		}
+	}
+
+	s.lastUnlock.Store(&unlockRecord{at: time.Now(), admin: admin.Name})
+
+	s.notifyLockChange()
+
+	return nil
+}
+
+// InitializeVault creates the vault, unlocking the vault never creates one so
+// a wrong or missing storage path can't go unnoticed
+func (s *State) InitializeVault(ctx context.Context, request *proto.VaultInitialization) (*proto.VaultInfo, error) {
+	audit.SetActor(ctx, adminActor(""))
+
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	if request.GetPassphrase() == "" {
+		return nil, errors.New("passphrase must not be empty")
+	}
+
+	var recipient *age.X25519Recipient
+	if request.GetRecoveryRecipient() != "" {
+		var err error
+		if recipient, err = age.ParseX25519Recipient(request.GetRecoveryRecipient()); err != nil {
+			return nil, err
+		}
+	}
+
+	marker, err := s.vault.Initialize(request.GetPassphrase(), recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().
+		Str("vault", marker.Id.String()).
+		Bool("recovery_recipient", recipient != nil).
+		Msg("vault initialized")
+
+	s.lastUnlock.Store(&unlockRecord{at: time.Now(), admin: vault.RootAdmin})
+	s.notifyLockChange()
+
+	return markerToProto(marker), nil
+}
+
+func markerToProto(marker *vault.Marker) *proto.VaultInfo {
+	return &proto.VaultInfo{
+		Id:            marker.Id.String(),
+		Secure:        marker.Secure,
+		FormatVersion: uint32(marker.FormatVersion),
+		CreatedAt:     marker.CreatedAt.UnixMilli(),
+	}
 }
 
 func (s *State) Lock() bool {
@@ -63,5 +283,39 @@ func (s *State) Lock() bool {
 		return false
 	}
 
+	s.sessions.Reset()
+	s.approvals.Reset()
+	s.challenges.Reset()
+
+	s.notifyLockChange()
+
 	return true
 }
+
+func limiterOptions(config *store.RateLimitConfig) limiter.Options {
+	options := limiter.Options{
+		FreeAttempts: limiter.DefaultFreeAttempts,
+		BaseDelay:    limiter.DefaultBaseDelay,
+		MaxDelay:     limiter.DefaultMaxDelay,
+	}
+
+	if config == nil {
+		return options
+	}
+
+	if config.FreeAttempts != nil {
+		options.FreeAttempts = *config.FreeAttempts
+	}
+
+	if config.BaseDelay != nil {
+		options.BaseDelay = config.BaseDelay.Duration
+	}
+
+	if config.MaxDelay != nil {
+		options.MaxDelay = config.MaxDelay.Duration
+	}
+
+	options.AutoLockAfter = config.AutoLockAfter
+
+	return options
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPassphrase = "passphrase-4c19e07a5d"
+
+// the vault wipes the passphrases it's given, which must not be constants
+func passphrase() string {
+	return strings.Clone(testPassphrase)
+}
+
+// newTestState returns the state of an initialized and unlocked store
+func newTestState(t *testing.T) *State {
+	storagePath := filepath.Join(t.TempDir(), "vault")
+
+	vaultInstance, err := vault.NewVault(&vault.Options{Backend: vault.NewLocalStorageBackend(storagePath)})
+	require.NoError(t, err)
+
+	state, err := NewState(&store.Config{StoragePath: storagePath}, vaultInstance, "test", false)
+	require.NoError(t, err)
+
+	t.Cleanup(state.StopWatches)
+
+	ctx := context.Background()
+
+	_, err = state.InitializeVault(ctx, &proto.VaultInitialization{Passphrase: passphrase()})
+	require.NoError(t, err)
+	require.NoError(t, state.Unlock(ctx, &proto.AdminCredentials{Passphrase: passphrase()}))
+
+	return state
+}
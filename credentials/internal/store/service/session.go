@@ -0,0 +1,168 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/limiter"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"time"
+)
+
+const (
+	sessionMetadataKey = "authorization"
+	sessionTokenPrefix = "Bearer "
+)
+
+func (s *State) Login(ctx context.Context, request *proto.LoginRequest) (*proto.Session, error) {
+	if s.vault.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	credentials := request.GetCredentials()
+	audit.SetActor(ctx, adminActor(credentials.GetName()))
+
+	admin, err := s.vault.VerifyAdmin(credentials.GetName(), credentials.GetPassphrase())
+	if err != nil {
+		return nil, authenticationError(err)
+	}
+
+	limiter.Authenticated(ctx, limiter.Admin)
+
+	scopes := request.Scopes
+	if len(scopes) == 0 {
+		scopes = roleScopes(admin.Role)
+	}
+
+	for _, scope := range scopes {
+		if !roleGrants(admin.Role, scope) {
+			return nil, permissionError(fmt.Errorf("role %s doesn't grant scope: %s", admin.Role, scope))
+		}
+	}
+
+	token, claims, err := s.sessions.Issue(admin.Name, scopes, time.Duration(request.TtlSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().Str("admin", admin.Name).Strs("scopes", claims.Scopes).Msg("session started")
+
+	return &proto.Session{
+		Token:     token,
+		ExpiresAt: claims.ExpiresAt.UnixMilli(),
+		Scopes:    claims.Scopes,
+		Admin:     claims.Admin,
+	}, nil
+}
+
+func (s *State) Logout(ctx context.Context) error {
+	claims, err := s.sessionFromContext(ctx)
+	if err != nil {
+		return authenticationError(err)
+	}
+
+	audit.SetActor(ctx, adminActor(claims.Admin))
+	limiter.Authenticated(ctx, limiter.Admin)
+
+	s.sessions.Revoke(claims)
+
+	return nil
+}
+
+// authenticateAdmin verifies the passphrase if one is part of the request,
+// otherwise the session token sent along as metadata has to grant the scope.
+// Returns the name of the acting admin.
+func (s *State) authenticateAdmin(ctx context.Context, credentials *proto.AdminCredentials, scope string) (string, error) {
+	if s.vault.IsLocked() {
+		return "", errors.New("vault is locked")
+	}
+
+	if credentials.GetPassphrase() != "" {
+		audit.SetActor(ctx, adminActor(credentials.GetName()))
+
+		admin, err := s.vault.VerifyAdmin(credentials.GetName(), credentials.GetPassphrase())
+		if err != nil {
+			return "", authenticationError(err)
+		}
+
+		limiter.Authenticated(ctx, limiter.Admin)
+
+		if !roleGrants(admin.Role, scope) {
+			return "", permissionError(fmt.Errorf("role %s doesn't grant scope: %s", admin.Role, scope))
+		}
+
+		return admin.Name, nil
+	}
+
+	claims, err := s.sessionFromContext(ctx)
+	if err != nil {
+		return "", authenticationError(err)
+	}
+
+	audit.SetActor(ctx, adminActor(claims.Admin))
+
+	// sessions of removed admins end immediately
+	admin := s.vault.Admin(claims.Admin)
+	if admin == nil {
+		return "", authenticationError(errors.New("admin not found: " + claims.Admin))
+	}
+
+	limiter.Authenticated(ctx, limiter.Admin)
+
+	if !claims.HasScope(scope) {
+		return "", permissionError(errors.New("session is not authorized for: " + scope))
+	}
+
+	// the scopes were granted by the role the admin had when the session
+	// started, a downgrade applies to running sessions as well
+	if !roleGrants(admin.Role, scope) {
+		return "", permissionError(fmt.Errorf("role %s doesn't grant scope: %s", admin.Role, scope))
+	}
+
+	return claims.Admin, nil
+}
+
+// adminActor identifies an admin in the audit log, the root admin may be
+// addressed without a name.
+func adminActor(name string) string {
+	if name == "" {
+		name = vault.RootAdmin
+	}
+
+	return adminRequesterPrefix + name
+}
+
+func (s *State) sessionFromContext(ctx context.Context) (*session.Claims, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, errors.New("no credentials provided")
+	}
+
+	values := md.Get(sessionMetadataKey)
+	if len(values) == 0 || !strings.HasPrefix(values[0], sessionTokenPrefix) {
+		return nil, errors.New("no credentials provided")
+	}
+
+	return s.sessions.Verify(strings.TrimPrefix(values[0], sessionTokenPrefix))
+}
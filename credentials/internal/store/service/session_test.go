@@ -0,0 +1,76 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"google.golang.org/grpc/metadata"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticateAdmin_AuditorCantReveal(t *testing.T) {
+	state := newTestState(t)
+	ctx := context.Background()
+
+	_, err := state.vault.AddAdmin("auditor", vault.RoleAuditor, passphrase())
+	require.NoError(t, err)
+
+	credentials := func() *proto.AdminCredentials {
+		return &proto.AdminCredentials{Name: "auditor", Passphrase: passphrase()}
+	}
+
+	_, err = state.authenticateAdmin(ctx, credentials(), session.ScopeRead)
+	assert.NoError(t, err)
+
+	_, err = state.authenticateReader(ctx, credentials(), nil, nil, session.ScopeReveal)
+	assert.IsType(t, &PermissionError{}, err)
+
+	_, err = state.Login(ctx, &proto.LoginRequest{Credentials: credentials(), Scopes: []string{session.ScopeReveal}})
+	assert.IsType(t, &PermissionError{}, err)
+}
+
+func TestAuthenticateAdmin_RoleDowngrade(t *testing.T) {
+	state := newTestState(t)
+
+	_, err := state.vault.AddAdmin("manager", vault.RoleManager, passphrase())
+	require.NoError(t, err)
+
+	started, err := state.Login(context.Background(), &proto.LoginRequest{
+		Credentials: &proto.AdminCredentials{Name: "manager", Passphrase: passphrase()},
+	})
+	require.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(sessionMetadataKey, sessionTokenPrefix+started.Token))
+
+	_, err = state.authenticateAdmin(ctx, nil, session.ScopeReveal)
+	require.NoError(t, err)
+
+	require.NoError(t, state.vault.RemoveAdmin("manager"))
+	_, err = state.vault.AddAdmin("manager", vault.RoleAuditor, passphrase())
+	require.NoError(t, err)
+
+	_, err = state.authenticateAdmin(ctx, nil, session.ScopeReveal)
+	assert.IsType(t, &PermissionError{}, err)
+
+	_, err = state.authenticateAdmin(ctx, nil, session.ScopeRead)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"github.com/awnumar/memguard"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"golang.org/x/crypto/ssh"
+	"strings"
+)
+
+const shareRecipientPrefix = "recipient:"
+
+// ShareVaultItem reads the item like ReadVaultItem does, so approvals and
+// read limits apply, and returns its value encrypted to the recipient
+func (s *State) ShareVaultItem(ctx context.Context, request *proto.ItemShare) (*proto.SharedItem, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeReveal)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, name, err := parseShareRecipient(request.GetRecipient())
+	if err != nil {
+		return nil, err
+	}
+
+	audit.SetTarget(ctx, shareRecipientPrefix+name)
+
+	value, err := s.readItem(ctx, adminRequesterPrefix+admin, request.GetItemId())
+	if err != nil {
+		return nil, err
+	} else if value.Approval != nil {
+		return &proto.SharedItem{Approval: value.Approval}, nil
+	}
+
+	plaintext := memguard.NewBufferFromBytes(value.Value)
+	defer plaintext.Destroy()
+
+	var ciphertext bytes.Buffer
+	w, err := age.Encrypt(&ciphertext, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(plaintext.Bytes()); err != nil {
+		return nil, err
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	log.Ctx(ctx).Info().
+		Str("admin", admin).
+		Str("item", request.GetItemId()).
+		Str("recipient", name).
+		Msg("vault item shared")
+
+	return &proto.SharedItem{Ciphertext: ciphertext.Bytes()}, nil
+}
+
+// parseShareRecipient parses an age or SSH recipient and returns it with the
+// name it's audited as, SSH keys are identified by their fingerprint
+func parseShareRecipient(raw string) (age.Recipient, string, error) {
+	raw = strings.TrimSpace(raw)
+
+	if strings.HasPrefix(raw, "age1") {
+		recipient, err := age.ParseX25519Recipient(raw)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return recipient, recipient.String(), nil
+	}
+
+	if strings.HasPrefix(raw, "ssh-") {
+		recipient, err := agessh.ParseRecipient(raw)
+		if err != nil {
+			return nil, "", err
+		}
+
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(raw))
+		if err != nil {
+			return nil, "", err
+		}
+
+		return recipient, ssh.FingerprintSHA256(publicKey), nil
+	}
+
+	return nil, "", errors.New("recipient must be an age recipient or an SSH public key")
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"filippo.io/age"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc/metadata"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseShareRecipient(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	_, name, err := parseShareRecipient(" " + identity.Recipient().String() + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, identity.Recipient().String(), name)
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	require.NoError(t, err)
+
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey))) + " someone@example"
+
+	_, name, err = parseShareRecipient(authorizedKey)
+	require.NoError(t, err)
+	assert.Equal(t, ssh.FingerprintSHA256(sshPublicKey), name)
+}
+
+func TestParseShareRecipient_Invalid(t *testing.T) {
+	for _, raw := range []string{"", "age1invalid", "ssh-ed25519 invalid", "AGE-SECRET-KEY-1ABC"} {
+		_, _, err := parseShareRecipient(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestShareVaultItem(t *testing.T) {
+	state := newTestState(t)
+
+	item, err := state.CreateVaultItem(context.Background(), &proto.ItemCreation{
+		Credentials: &proto.AdminCredentials{Passphrase: passphrase()},
+		Description: "shared",
+		Value:       []byte("shared value"),
+	})
+	require.NoError(t, err)
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	ctx, record := audit.NewContext(context.Background())
+
+	shared, err := state.ShareVaultItem(ctx, &proto.ItemShare{
+		Credentials: &proto.AdminCredentials{Passphrase: passphrase()},
+		ItemId:      item.GetId(),
+		Recipient:   identity.Recipient().String(),
+	})
+	require.NoError(t, err)
+	assert.Nil(t, shared.GetApproval())
+
+	reader, err := age.Decrypt(bytes.NewReader(shared.GetCiphertext()), identity)
+	require.NoError(t, err)
+
+	plaintext, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "shared value", string(plaintext))
+
+	entry := record.Entry("ShareVaultItem", "", audit.OutcomeSuccess, nil)
+	assert.Equal(t, shareRecipientPrefix+identity.Recipient().String(), entry.Target)
+	assert.Equal(t, []string{item.GetId()}, entry.ItemIds)
+}
+
+func TestShareVaultItem_Approval(t *testing.T) {
+	state := newTestState(t)
+	ctx := context.Background()
+
+	item, err := state.CreateVaultItem(ctx, &proto.ItemCreation{
+		Credentials:      &proto.AdminCredentials{Passphrase: passphrase()},
+		Description:      "shared",
+		Value:            []byte("shared value"),
+		RequiresApproval: true,
+	})
+	require.NoError(t, err)
+
+	_, err = state.vault.AddAdmin("approver", vault.RoleAdmin, passphrase())
+	require.NoError(t, err)
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	share := func() *proto.SharedItem {
+		shared, err := state.ShareVaultItem(ctx, &proto.ItemShare{
+			Credentials: &proto.AdminCredentials{Passphrase: passphrase()},
+			ItemId:      item.GetId(),
+			Recipient:   identity.Recipient().String(),
+		})
+		require.NoError(t, err)
+
+		return shared
+	}
+
+	pending := share()
+	require.NotNil(t, pending.GetApproval())
+	assert.Empty(t, pending.GetCiphertext())
+
+	_, err = state.DecideApproval(ctx, &proto.ApprovalDecision{
+		Credentials: &proto.AdminCredentials{Name: "approver", Passphrase: passphrase()},
+		Id:          pending.GetApproval().GetId(),
+		Approve:     true,
+	})
+	require.NoError(t, err)
+
+	shared := share()
+	assert.Nil(t, shared.GetApproval())
+
+	reader, err := age.Decrypt(bytes.NewReader(shared.GetCiphertext()), identity)
+	require.NoError(t, err)
+
+	plaintext, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "shared value", string(plaintext))
+}
+
+func TestShareVaultItem_RequiresReveal(t *testing.T) {
+	state := newTestState(t)
+
+	item, err := state.CreateVaultItem(context.Background(), &proto.ItemCreation{
+		Credentials: &proto.AdminCredentials{Passphrase: passphrase()},
+		Description: "shared",
+		Value:       []byte("shared value"),
+	})
+	require.NoError(t, err)
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	started, err := state.Login(context.Background(), &proto.LoginRequest{
+		Credentials: &proto.AdminCredentials{Passphrase: passphrase()},
+		Scopes:      []string{session.ScopeRead},
+	})
+	require.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(sessionMetadataKey, sessionTokenPrefix+started.Token))
+
+	_, err = state.ShareVaultItem(ctx, &proto.ItemShare{
+		ItemId:    item.GetId(),
+		Recipient: identity.Recipient().String(),
+	})
+	assert.IsType(t, &PermissionError{}, err)
+}
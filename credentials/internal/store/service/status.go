@@ -0,0 +1,134 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+type unlockRecord struct {
+	at    time.Time
+	admin string
+}
+
+// SetTlsCertificate registers the source of the certificate served by the
+// store, it has to be set before serving any requests
+func (s *State) SetTlsCertificate(certificate func() *x509.Certificate) {
+	s.tlsCertificate = certificate
+}
+
+func (s *State) GetStatus(ctx context.Context, request *proto.AdminCredentials) (*proto.StoreStatus, error) {
+	_, err := s.authenticateAdmin(ctx, request, session.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	stats := s.limiter.Stats()
+
+	status := &proto.StoreStatus{
+		Info:               s.StoreInfo(),
+		StartedAt:          s.startedAt.UnixMilli(),
+		UptimeMillis:       now.Sub(s.startedAt).Milliseconds(),
+		VaultFormatVersion: vault.FormatVersion,
+		PendingApprovals:   uint32(len(s.approvals.Requests(false))),
+		AuthCounters: &proto.AuthCounters{
+			Failures:       stats.AuthFailures,
+			AdminFailures:  stats.AdminFailures,
+			ClientFailures: stats.ClientFailures,
+			RejectedCalls:  stats.RejectedCalls,
+			AutoLocks:      stats.AutoLocks,
+			BlockedSources: uint32(stats.BlockedKeys),
+		},
+		Replication: s.replicationStatus(),
+	}
+
+	for _, item := range s.vault.Items() {
+		if isClientItem(item) {
+			status.ClientCount++
+		} else {
+			status.ItemCount++
+		}
+	}
+
+	if status.StorageBytes, err = storageSize(s.Config().StoragePath); err != nil {
+		return nil, err
+	}
+
+	if unlock := s.lastUnlock.Load(); unlock != nil {
+		status.LastUnlockAt = unlock.at.UnixMilli()
+		status.LastUnlockBy = unlock.admin
+	}
+
+	marker, err := s.vault.Marker()
+	if err != nil {
+		return nil, err
+	} else if marker != nil {
+		status.VaultId = marker.Id.String()
+	}
+
+	if recipient := s.vault.RecoveryRecipient(); recipient != nil {
+		status.RecoveryRecipientFingerprints = append(status.RecoveryRecipientFingerprints, fingerprint(recipient.String()))
+	}
+
+	if s.tlsCertificate != nil {
+		if certificate := s.tlsCertificate(); certificate != nil {
+			status.TlsCertificate = &proto.TlsCertificateInfo{
+				Subject:  certificate.Subject.String(),
+				NotAfter: certificate.NotAfter.UnixMilli(),
+			}
+		}
+	}
+
+	return status, nil
+}
+
+func storageSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		size += uint64(info.Size())
+
+		return nil
+	})
+
+	return size, err
+}
+
+// fingerprint identifies a public key without showing it in full
+func fingerprint(publicKey string) string {
+	sum := sha256.Sum256([]byte(publicKey))
+
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
@@ -16,20 +16,35 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"filippo.io/age"
 	"github.com/awnumar/memguard"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/audit"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/labels"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/limiter"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
 	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/watch"
+	"slices"
+	"strings"
+	"time"
 )
 
-func (s *State) SetRecoveryRecipient(request *proto.RecoveryRecipient) error {
-	err := s.vault.VerifyPassphrase(request.GetCredentials().Passphrase)
+func (s *State) SetRecoveryRecipient(ctx context.Context, request *proto.RecoveryRecipient) error {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeAdmin)
 	if err != nil {
 		return err
 	}
 
+	if err = s.checkWritable(); err != nil {
+		return err
+	}
+
 	var recipient *age.X25519Recipient
 	recipient, err = age.ParseX25519Recipient(request.Recipient)
 	if err != nil {
@@ -41,53 +56,112 @@ func (s *State) SetRecoveryRecipient(request *proto.RecoveryRecipient) error {
 		return err
 	}
 
+	log.Ctx(ctx).Info().Str("admin", admin).Msg("recovery recipient changed")
+
 	return nil
 }
 
-func (s *State) CreateVaultItem(request *proto.ItemCreation) (*proto.Item, error) {
-	err := s.vault.VerifyPassphrase(request.GetCredentials().Passphrase)
+func (s *State) CreateVaultItem(ctx context.Context, request *proto.ItemCreation) (*proto.Item, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeWrite)
 	if err != nil {
 		return nil, err
 	}
 
-	item, err := s.vault.CreateItem(request.Description)
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	item, err := s.createItem(request.Description, request.Labels, request.RequiresApproval, request.MaxReads, request.TtlSeconds, memguard.NewBufferFromBytes(request.GetValue()))
 	if err != nil {
 		return nil, err
 	}
 
-	itemValue := memguard.NewBufferFromBytes(request.GetValue())
+	audit.AddItems(ctx, item.Id.String())
+
+	log.Ctx(ctx).Info().Str("admin", admin).Str("item", item.Id.String()).Msg("vault item created")
+
+	s.publishItem(watch.ItemCreated, *item)
 
-	err = s.vault.SetItemValue(item.Id, itemValue)
+	return itemToProto(*item), nil
+}
+
+// createItem creates an item with the value, which is destroyed, the item is
+// deleted again if any of its settings fails to apply
+func (s *State) createItem(description string, itemLabels map[string]string, requiresApproval bool, maxReads uint32, ttlSeconds int64, value *memguard.LockedBuffer) (*vault.Item, error) {
+	defer value.Destroy()
+
+	if err := labels.Validate(itemLabels); err != nil {
+		return nil, err
+	}
+
+	item, err := s.vault.CreateItem(description)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(itemLabels) > 0 {
+		_, err = s.vault.SetItemLabels(item.Id, itemLabels)
+		if err != nil {
+			_ = s.vault.DeleteItem(item.Id)
+			return nil, err
+		}
+	}
+
+	if maxReads > 0 || ttlSeconds > 0 {
+		var expiresAt *time.Time
+		if ttlSeconds > 0 {
+			deadline := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+			expiresAt = &deadline
+		}
+
+		_, err = s.vault.SetItemReadLimit(item.Id, int(maxReads), expiresAt)
+		if err != nil {
+			_ = s.vault.DeleteItem(item.Id)
+			return nil, err
+		}
+	}
+
+	if requiresApproval {
+		_, err = s.vault.SetItemRequiresApproval(item.Id, true)
+		if err != nil {
+			_ = s.vault.DeleteItem(item.Id)
+			return nil, err
+		}
+	}
+
+	err = s.vault.SetItemValue(item.Id, value)
 	if err != nil {
 		_ = s.vault.DeleteItem(item.Id)
 		return nil, err
 	}
 
-	return &proto.Item{
-		Id:          item.Id.String(),
-		Description: item.Description,
-		Checksum:    item.Checksum,
-		CreatedAt:   item.ModifiedAt.UnixMilli(),
-	}, nil
+	return s.vault.ItemMetadata(item.Id)
 }
 
-func (s *State) ListVaultItems(request *proto.ItemSearch) ([]vault.Item, error) {
-	err := s.vault.VerifyPassphrase(request.GetCredentials().Passphrase)
+func (s *State) ListVaultItems(ctx context.Context, request *proto.ItemSearch) (*ItemPage, error) {
+	_, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeRead)
 	if err != nil {
 		return nil, err
 	}
 
-	items := s.vault.Items()
+	// expired items are purged by the primary
+	if !s.IsReplica() {
+		s.publishDeleted(s.vault.PurgeExpiredItems())
+	}
 
-	return items, nil
+	return searchItems(s.vault.Items(), request)
 }
 
-func (s *State) DeleteVaultItems(request *proto.ItemDeletion) ([]uuid.UUID, error) {
-	err := s.vault.VerifyPassphrase(request.GetCredentials().Passphrase)
+func (s *State) DeleteVaultItems(ctx context.Context, request *proto.ItemDeletion) ([]uuid.UUID, error) {
+	admin, err := s.authenticateAdmin(ctx, request.GetCredentials(), session.ScopeWrite)
 	if err != nil {
 		return nil, err
 	}
 
+	if err = s.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	deletedItemIds := make([]uuid.UUID, 0, len(request.Id))
 
 	for _, idRaw := range request.Id {
@@ -96,33 +170,153 @@ func (s *State) DeleteVaultItems(request *proto.ItemDeletion) ([]uuid.UUID, erro
 			return nil, err
 		}
 
+		audit.AddItems(ctx, id.String())
+
+		// unknown items are skipped by the deletion
+		item, metadataErr := s.vault.ItemMetadata(id)
+
 		err = s.vault.DeleteItem(id)
 		if err != nil {
 			return nil, err
 		}
 
+		log.Ctx(ctx).Info().Str("admin", admin).Str("item", id.String()).Msg("vault item deleted")
+
+		if metadataErr == nil {
+			s.publishItem(watch.ItemDeleted, *item)
+			if isClientItem(*item) {
+				s.publishClient(watch.ClientRevoked, id.String())
+			}
+		}
+
 		deletedItemIds = append(deletedItemIds, id)
 	}
 
 	return deletedItemIds, nil
 }
 
-func (s *State) ReadVaultItem(request *proto.ItemRequest) (*proto.ItemValue, error) {
-	var err error
-	if request.GetAdmin() != nil {
-		err = s.vault.VerifyPassphrase(request.GetAdmin().GetPassphrase())
-	} else if request.GetClient() != nil {
-		err = s.verifyClientCredentials(request.GetClient())
-	} else {
-		err = errors.New("invalid request: no credentials provided")
+func (s *State) ReadVaultItem(ctx context.Context, request *proto.ItemRequest) (*proto.ItemValue, error) {
+	requester, err := s.authenticateReader(ctx, request.GetAdmin(), request.GetClient(), request.GetKey(), session.ScopeReveal)
+	if err != nil {
+		return nil, err
 	}
 
-	itemId, err := uuid.Parse(request.GetItemId())
+	return s.readItem(ctx, requester, request.GetItemId())
+}
+
+// ReadVaultItems reads the requested items with a single authentication,
+// failing to read an item is reported in its result instead of failing the
+// whole request. The items matching the selector follow the listed ones.
+func (s *State) ReadVaultItems(ctx context.Context, request *proto.ItemsRequest, send func(*proto.ItemResult) error) error {
+	requester, err := s.authenticateReader(ctx, request.GetAdmin(), request.GetClient(), request.GetKey(), session.ScopeReveal)
+	if err != nil {
+		return err
+	}
+
+	itemIds := request.ItemIds
+	if request.Selector != "" {
+		selector, err := labels.Parse(request.Selector)
+		if err != nil {
+			return err
+		}
+
+		itemIds = append(slices.Clone(itemIds), s.selectItems(ctx, selector, itemIds)...)
+	}
+
+	for _, rawId := range itemIds {
+		result := &proto.ItemResult{ItemId: rawId}
+
+		value, err := s.readItem(ctx, requester, rawId)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Value = value
+		}
+
+		if err = send(result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// selectItems returns the IDs of the items matching the selector, ordered by
+// description, skipping client items, the listed items and the items the
+// unix socket peer may not read
+func (s *State) selectItems(ctx context.Context, selector labels.Selector, listed []string) []string {
+	unixPeer := access.UnixPeerFromContext(ctx)
+
+	var matched []vault.Item
+	for _, item := range s.vault.Items() {
+		if isClientItem(item) || !selector.Matches(item.Labels) {
+			continue
+		}
+
+		if unixPeer != nil && !unixPeer.MayRead(item.Id.String()) {
+			continue
+		}
+
+		if slices.ContainsFunc(listed, func(rawId string) bool { return strings.EqualFold(rawId, item.Id.String()) }) {
+			continue
+		}
+
+		matched = append(matched, item)
+	}
+
+	slices.SortFunc(matched, compareItems)
+
+	itemIds := make([]string, 0, len(matched))
+	for _, item := range matched {
+		itemIds = append(itemIds, item.Id.String())
+	}
+
+	return itemIds
+}
+
+func (s *State) readItem(ctx context.Context, requester string, rawId string) (*proto.ItemValue, error) {
+	unixPeer := access.UnixPeerFromContext(ctx)
+
+	itemId, err := uuid.Parse(rawId)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.AddItems(ctx, itemId.String())
+
+	if unixPeer != nil && !unixPeer.MayRead(itemId.String()) {
+		log.Ctx(ctx).Warn().
+			Uint32("uid", unixPeer.Uid).
+			Str("item", itemId.String()).
+			Str("rule", unixPeer.Rule).
+			Msg("rejecting read from unix socket peer")
+
+		return nil, permissionError(errors.New("item may not be read by this peer"))
+	}
+
+	pending, err := s.redeemApproval(itemId, requester)
+	if err != nil {
+		return nil, err
+	} else if pending != nil {
+		return &proto.ItemValue{Approval: pending}, nil
+	}
+
+	item, err := s.vault.ItemMetadata(itemId)
 	if err != nil {
 		return nil, err
 	}
 
+	// the reads would be counted by this store only
+	if item.IsLimited() && s.IsReplica() {
+		return nil, replicaError(errors.New("item has a read limit, read it from the primary"))
+	}
+
 	value, err := s.vault.GetItem(itemId)
+	if item.IsLimited() {
+		// the read counted towards the limit or the item expired
+		s.publishReadLimit(itemId)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -134,3 +328,86 @@ func (s *State) ReadVaultItem(request *proto.ItemRequest) (*proto.ItemValue, err
 
 	return &proto.ItemValue{Value: valueBytes}, nil
 }
+
+func (s *State) publishReadLimit(itemId uuid.UUID) {
+	item, err := s.vault.ItemMetadata(itemId)
+	if err != nil {
+		s.publishDeleted([]uuid.UUID{itemId})
+	} else {
+		s.publishItem(watch.ItemUpdated, *item)
+	}
+}
+
+// authenticateReader authenticates callers allowed to read items, clients by
+// their secret, key or unix socket identity and admins, and returns the
+// requester
+func (s *State) authenticateReader(
+	ctx context.Context,
+	admin *proto.AdminCredentials,
+	client *proto.ClientCredentials,
+	key *proto.ClientKeyCredentials,
+	adminScope string,
+) (string, error) {
+	unixPeer := access.UnixPeerFromContext(ctx)
+
+	var requester string
+	var err error
+	if client != nil {
+		requester = clientRequesterPrefix + client.GetId()
+		audit.SetActor(ctx, requester)
+
+		err = s.verifyClientCredentials(client)
+		if err == nil {
+			limiter.Authenticated(ctx, limiter.Client)
+			err = s.checkClientSource(ctx, client.GetId())
+		}
+	} else if key != nil {
+		requester = clientRequesterPrefix + key.GetId()
+		audit.SetActor(ctx, requester)
+
+		err = s.verifyClientKey(key)
+		if err == nil {
+			limiter.Authenticated(ctx, limiter.Client)
+			err = s.checkClientSource(ctx, key.GetId())
+		}
+	} else if admin == nil && unixPeer != nil && unixPeer.ClientId != "" {
+		// the kernel already vouched for the identity of the peer
+		requester = clientRequesterPrefix + unixPeer.ClientId
+		audit.SetActor(ctx, requester)
+		limiter.Authenticated(ctx, limiter.Client)
+	} else if unixPeer != nil && !unixPeer.AllowAdmin {
+		err = permissionError(errors.New("admin calls are not permitted for this peer"))
+	} else {
+		var name string
+		name, err = s.authenticateAdmin(ctx, admin, adminScope)
+		requester = adminRequesterPrefix + name
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return requester, nil
+}
+
+func itemToProto(item vault.Item) *proto.Item {
+	result := &proto.Item{
+		Id:               item.Id.String(),
+		Description:      item.Description,
+		Checksum:         item.Checksum,
+		CreatedAt:        item.ModifiedAt.UnixMilli(),
+		RequiresApproval: item.RequiresApproval,
+		Labels:           item.Labels,
+	}
+
+	if item.MaxReads > 0 {
+		result.MaxReads = uint32(item.MaxReads)
+		result.RemainingReads = uint32(item.RemainingReads())
+	}
+
+	if item.ExpiresAt != nil {
+		result.ExpiresAt = item.ExpiresAt.UnixMilli()
+	}
+
+	return result
+}
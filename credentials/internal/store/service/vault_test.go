@@ -0,0 +1,96 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadVaultItems_Selector(t *testing.T) {
+	state := newTestState(t)
+	ctx := context.Background()
+
+	createItem := func(description string, value string, labels map[string]string) string {
+		item, err := state.CreateVaultItem(ctx, &proto.ItemCreation{
+			Credentials: &proto.AdminCredentials{Passphrase: passphrase()},
+			Description: description,
+			Value:       []byte(value),
+			Labels:      labels,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, labels, item.GetLabels())
+
+		return item.GetId()
+	}
+
+	repoB := createItem("repository b", "value b", map[string]string{"host": "backup-1", "kind": "repository"})
+	repoA := createItem("repository a", "value a", map[string]string{"host": "backup-1", "kind": "repository"})
+	createItem("repository c", "value c", map[string]string{"host": "backup-2", "kind": "repository"})
+	sshKey := createItem("ssh key", "value d", map[string]string{"host": "backup-1"})
+
+	_, err := state.CreateClientCredentials(ctx, &proto.ClientCreation{
+		Credentials: &proto.AdminCredentials{Passphrase: passphrase()},
+	})
+	require.NoError(t, err)
+
+	read := func(request *proto.ItemsRequest) ([]string, map[string]string) {
+		request.Credentials = &proto.ItemsRequest_Admin{Admin: &proto.AdminCredentials{Passphrase: passphrase()}}
+
+		var ids []string
+		values := make(map[string]string)
+		err := state.ReadVaultItems(ctx, request, func(result *proto.ItemResult) error {
+			assert.Empty(t, result.GetError())
+
+			ids = append(ids, result.GetItemId())
+			values[result.GetItemId()] = string(result.GetValue().GetValue())
+
+			return nil
+		})
+		require.NoError(t, err)
+
+		return ids, values
+	}
+
+	// the listed items come first, followed by the others sorted by description
+	ids, values := read(&proto.ItemsRequest{ItemIds: []string{sshKey, repoB}, Selector: "host=backup-1,kind=repository"})
+	assert.Equal(t, []string{sshKey, repoB, repoA}, ids)
+	assert.Equal(t, "value a", values[repoA])
+	assert.Equal(t, "value b", values[repoB])
+	assert.Equal(t, "value d", values[sshKey])
+
+	// client credential items never match
+	ids, _ = read(&proto.ItemsRequest{Selector: "!kind"})
+	assert.Equal(t, []string{sshKey}, ids)
+
+	err = state.ReadVaultItems(ctx, &proto.ItemsRequest{
+		Credentials: &proto.ItemsRequest_Admin{Admin: &proto.AdminCredentials{Passphrase: passphrase()}},
+		Selector:    "host==backup-1",
+	}, func(*proto.ItemResult) error { return nil })
+	assert.Error(t, err)
+
+	_, err = state.CreateVaultItem(ctx, &proto.ItemCreation{
+		Credentials: &proto.AdminCredentials{Passphrase: passphrase()},
+		Description: "invalid",
+		Value:       []byte("value"),
+		Labels:      map[string]string{"Host": "backup-1"},
+	})
+	assert.Error(t, err)
+}
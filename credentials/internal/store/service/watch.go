@@ -0,0 +1,185 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/vemilyus/borg-collective/credentials/internal/proto"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/access"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/session"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/watch"
+	"slices"
+	"strings"
+)
+
+// WatchVault sends the vault changes visible to the requester until the
+// context is done or the watch ends
+func (s *State) WatchVault(ctx context.Context, request *proto.WatchRequest, send func(*proto.WatchEvent) error) error {
+	// the events don't carry any values
+	requester, err := s.authenticateReader(ctx, request.GetAdmin(), request.GetClient(), request.GetKey(), session.ScopeRead)
+	if err != nil {
+		return err
+	}
+
+	filter := watchFilter{unixPeer: access.UnixPeerFromContext(ctx)}
+	filter.clientId, filter.isClient = strings.CutPrefix(requester, clientRequesterPrefix)
+
+	for _, rawId := range request.ItemIds {
+		id, err := uuid.Parse(rawId)
+		if err != nil {
+			return err
+		}
+
+		filter.itemIds = append(filter.itemIds, id.String())
+	}
+
+	watcher, missed, resetToken := s.watches.Watch(request.ResumeToken)
+	defer watcher.Close()
+
+	log.Ctx(ctx).Debug().Str("requester", requester).Msg("vault watch started")
+
+	if resetToken != "" {
+		err = send(&proto.WatchEvent{Type: proto.WatchEventType_WATCH_RESET, ResumeToken: resetToken})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, event := range missed {
+		if err = filter.send(event, send); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events():
+			if !ok {
+				return watcher.Err()
+			}
+
+			if err = filter.send(event, send); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StopWatches ends all running watches
+func (s *State) StopWatches() {
+	s.watches.Close()
+}
+
+func (s *State) publishItem(eventType watch.EventType, item vault.Item) {
+	s.watches.Publish(watch.Event{Type: eventType, Item: &item})
+}
+
+func (s *State) publishDeleted(ids []uuid.UUID) {
+	for _, id := range ids {
+		s.watches.Publish(watch.Event{Type: watch.ItemDeleted, Item: &vault.Item{Id: id}})
+	}
+}
+
+func (s *State) publishClient(eventType watch.EventType, clientId string) {
+	s.watches.Publish(watch.Event{Type: eventType, ClientId: clientId})
+}
+
+func (s *State) publishLockChange(locked bool) {
+	if locked {
+		s.watches.Publish(watch.Event{Type: watch.VaultLocked})
+	} else {
+		s.watches.Publish(watch.Event{Type: watch.VaultUnlocked})
+	}
+}
+
+// watchFilter limits the events to what the requester may know about,
+// clients only learn which items changed and about changes to themselves
+type watchFilter struct {
+	isClient bool
+	clientId string
+	unixPeer *access.UnixPeer
+	itemIds  []string
+}
+
+func (f watchFilter) send(event watch.Event, send func(*proto.WatchEvent) error) error {
+	result := &proto.WatchEvent{
+		ResumeToken: event.Token,
+		Timestamp:   event.At.UnixMilli(),
+	}
+
+	switch event.Type {
+	case watch.ItemCreated, watch.ItemUpdated, watch.ItemDeleted:
+		if !f.mayWatch(*event.Item) {
+			return nil
+		}
+
+		if f.isClient || event.Item.ModifiedAt.IsZero() {
+			// the metadata of expired items isn't known anymore
+			result.Item = &proto.Item{Id: event.Item.Id.String(), Checksum: event.Item.Checksum}
+		} else {
+			result.Item = itemToProto(*event.Item)
+		}
+	case watch.ClientCreated, watch.ClientRevoked:
+		if len(f.itemIds) > 0 || (f.isClient && f.clientId != event.ClientId) {
+			return nil
+		}
+
+		result.ClientId = event.ClientId
+	}
+
+	result.Type = watchEventTypeToProto(event.Type)
+
+	return send(result)
+}
+
+func (f watchFilter) mayWatch(item vault.Item) bool {
+	id := item.Id.String()
+
+	if len(f.itemIds) > 0 && !slices.Contains(f.itemIds, id) {
+		return false
+	}
+
+	if f.unixPeer != nil && !f.unixPeer.MayRead(id) {
+		return false
+	}
+
+	// client credentials are only visible to admins
+	return !f.isClient || !isClientItem(item)
+}
+
+func watchEventTypeToProto(eventType watch.EventType) proto.WatchEventType {
+	switch eventType {
+	case watch.ItemUpdated:
+		return proto.WatchEventType_WATCH_ITEM_UPDATED
+	case watch.ItemDeleted:
+		return proto.WatchEventType_WATCH_ITEM_DELETED
+	case watch.VaultLocked:
+		return proto.WatchEventType_WATCH_VAULT_LOCKED
+	case watch.VaultUnlocked:
+		return proto.WatchEventType_WATCH_VAULT_UNLOCKED
+	case watch.ClientCreated:
+		return proto.WatchEventType_WATCH_CLIENT_CREATED
+	case watch.ClientRevoked:
+		return proto.WatchEventType_WATCH_CLIENT_REVOKED
+	default:
+		return proto.WatchEventType_WATCH_ITEM_CREATED
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/awnumar/memguard"
+	"github.com/google/uuid"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ScopeRead = "read"
+	// reading item values, as opposed to their metadata
+	ScopeReveal = "reveal"
+	ScopeWrite  = "write"
+	ScopeAdmin  = "admin"
+
+	DefaultTtl = 15 * time.Minute
+	MaxTtl     = 12 * time.Hour
+)
+
+var AllScopes = []string{ScopeRead, ScopeReveal, ScopeWrite, ScopeAdmin}
+
+type Claims struct {
+	Id        string    `json:"id"`
+	Admin     string    `json:"admin"`
+	Scopes    []string  `json:"scopes"`
+	IssuedAt  time.Time `json:"iat"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// HasScope reports whether the claims grant the requested scope. The admin
+// scope implies every other scope.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope) || slices.Contains(c.Scopes, ScopeAdmin)
+}
+
+// Manager issues and verifies session tokens. Tokens are signed with a
+// random key that only lives in memory, so all sessions end when the key is
+// reset (e.g. when the vault gets locked) or the process exits.
+type Manager struct {
+	lock       sync.RWMutex
+	signingKey *memguard.Enclave
+	revoked    map[string]time.Time
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		lock:       sync.RWMutex{},
+		signingKey: memguard.NewEnclaveRandom(32),
+		revoked:    make(map[string]time.Time),
+	}
+}
+
+func (m *Manager) Issue(admin string, scopes []string, ttl time.Duration) (string, *Claims, error) {
+	if len(scopes) == 0 {
+		scopes = AllScopes
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(AllScopes, scope) {
+			return "", nil, errors.New("unknown scope: " + scope)
+		}
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTtl
+	} else if ttl > MaxTtl {
+		ttl = MaxTtl
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		Id:        uuid.NewString(),
+		Admin:     admin,
+		Scopes:    scopes,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	signature, err := m.signUnsafe(payload)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	return token, claims, nil
+}
+
+func (m *Manager) Verify(token string) (*Claims, error) {
+	rawPayload, rawSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errors.New("invalid session token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(rawPayload)
+	if err != nil {
+		return nil, errors.New("invalid session token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(rawSignature)
+	if err != nil {
+		return nil, errors.New("invalid session token")
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	expected, err := m.signUnsafe(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal(expected, signature) {
+		return nil, errors.New("invalid session token")
+	}
+
+	var claims Claims
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("invalid session token")
+	}
+
+	if time.Now().After(claims.ExpiresAt) {
+		return nil, errors.New("session expired")
+	}
+
+	if _, ok = m.revoked[claims.Id]; ok {
+		return nil, errors.New("session revoked")
+	}
+
+	return &claims, nil
+}
+
+func (m *Manager) Revoke(claims *Claims) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now()
+	maps.DeleteFunc(m.revoked, func(_ string, expiresAt time.Time) bool {
+		return now.After(expiresAt)
+	})
+
+	m.revoked[claims.Id] = claims.ExpiresAt
+}
+
+// Reset replaces the signing key, which invalidates all issued tokens.
+func (m *Manager) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.signingKey = memguard.NewEnclaveRandom(32)
+	m.revoked = make(map[string]time.Time)
+}
+
+func (m *Manager) signUnsafe(payload []byte) ([]byte, error) {
+	key, err := m.signingKey.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer key.Destroy()
+
+	h := hmac.New(sha256.New, key.Bytes())
+	h.Write(payload)
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	manager := NewManager()
+
+	token, claims, err := manager.Issue("root", []string{ScopeRead}, time.Minute)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	verified, err := manager.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Id, verified.Id)
+	assert.Equal(t, "root", verified.Admin)
+	assert.True(t, verified.HasScope(ScopeRead))
+	assert.False(t, verified.HasScope(ScopeWrite))
+
+	// Admin scope implies all others
+	token, _, err = manager.Issue("root", []string{ScopeAdmin}, time.Minute)
+	assert.NoError(t, err)
+
+	verified, err = manager.Verify(token)
+	assert.NoError(t, err)
+	assert.True(t, verified.HasScope(ScopeWrite))
+}
+
+func TestIssue_UnknownScope(t *testing.T) {
+	manager := NewManager()
+
+	_, _, err := manager.Issue("root", []string{"everything"}, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	manager := NewManager()
+
+	token, _, err := manager.Issue("root", []string{ScopeRead}, time.Minute)
+	assert.NoError(t, err)
+
+	payload, signature, _ := strings.Cut(token, ".")
+
+	_, err = manager.Verify(payload + "x." + signature)
+	assert.Error(t, err)
+
+	_, err = manager.Verify(payload)
+	assert.Error(t, err)
+
+	// Tokens from another manager are not accepted
+	other := NewManager()
+	_, err = other.Verify(token)
+	assert.Error(t, err)
+}
+
+func TestVerify_Expired(t *testing.T) {
+	manager := NewManager()
+
+	token, _, err := manager.Issue("root", nil, time.Millisecond)
+	assert.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = manager.Verify(token)
+	assert.Error(t, err)
+}
+
+func TestRevokeAndReset(t *testing.T) {
+	manager := NewManager()
+
+	token, claims, err := manager.Issue("root", nil, time.Minute)
+	assert.NoError(t, err)
+
+	manager.Revoke(claims)
+
+	_, err = manager.Verify(token)
+	assert.Error(t, err)
+
+	token, _, err = manager.Issue("root", nil, time.Minute)
+	assert.NoError(t, err)
+
+	manager.Reset()
+
+	_, err = manager.Verify(token)
+	assert.Error(t, err)
+}
@@ -0,0 +1,462 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/awnumar/memguard"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+const (
+	keyslotsPath = ".admins/keyslots.json"
+
+	// RootAdmin is the name of the admin holding the passphrase the vault
+	// was created with. It always has the admin role and can't be removed.
+	RootAdmin = "root"
+)
+
+type Role string
+
+const (
+	// RoleAdmin grants full access, including managing other admins
+	RoleAdmin Role = "admin"
+	// RoleManager may read, create and delete items and client credentials
+	RoleManager Role = "manager"
+	// RoleAuditor may only list items and read the audit log, never item values
+	RoleAuditor Role = "auditor"
+)
+
+var adminNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+func ParseRole(role string) (Role, error) {
+	switch r := Role(strings.ToLower(role)); r {
+	case RoleAdmin, RoleManager, RoleAuditor:
+		return r, nil
+	default:
+		return "", errors.New("unknown role: " + role)
+	}
+}
+
+type Admin struct {
+	Name      string    `json:"name"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// keyslot holds a copy of the identity key, wrapped with a key derived from
+// the passphrase of a single admin.
+type keyslot struct {
+	Admin
+	Salt       []byte `json:"salt"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// UnlockAs unlocks the vault using the passphrase of the named admin. An
+// empty name refers to the root admin.
+func (v *Vault) UnlockAs(name string, passphrase string) (*Admin, error) {
+	if name == "" || name == RootAdmin {
+		if err := v.Unlock(passphrase); err != nil {
+			return nil, err
+		}
+
+		return rootAdmin(), nil
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if !v.IsLocked() {
+		return v.verifyAdminUnsafe(name, passphrase)
+	}
+
+	if err := v.checkMarkerUnsafe(); err != nil {
+		return nil, err
+	}
+
+	// the keyslots can only be authenticated once the vault is unlocked
+	slots, err := readKeyslots(v.backend(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to read admin keyslots")
+		return nil, errors.New("failed to verify passphrase")
+	}
+
+	slot, ok := slots[name]
+	if !ok {
+		log.Info().Str("admin", name).Msg("unknown admin")
+		return nil, errors.New("failed to verify passphrase")
+	}
+
+	rawKey, err := slot.unwrap(v.Options().Secure, passphrase)
+	if err != nil {
+		log.Info().Str("admin", name).Msg("incorrect passphrase specified")
+		return nil, errors.New("failed to verify passphrase")
+	}
+
+	if err = v.unlockUnsafe(rawKey, false); err != nil {
+		return nil, err
+	}
+
+	verified, ok := v.keyslots[name]
+	if !ok || verified.Role != slot.Role {
+		log.Error().Str("admin", name).Msg("admin keyslot failed verification")
+
+		v.identityKey = nil
+		v.metadataHmacSecret = nil
+		v.primaryRecipient = nil
+		v.items = nil
+		v.keyslots = nil
+
+		return nil, errors.New("failed to verify passphrase")
+	}
+
+	return &verified.Admin, nil
+}
+
+// VerifyAdmin checks the passphrase of the named admin against the unlocked
+// vault. An empty name refers to the root admin.
+func (v *Vault) VerifyAdmin(name string, passphrase string) (*Admin, error) {
+	if name == "" || name == RootAdmin {
+		if err := v.VerifyPassphrase(passphrase); err != nil {
+			return nil, err
+		}
+
+		return rootAdmin(), nil
+	}
+
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if v.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	return v.verifyAdminUnsafe(name, passphrase)
+}
+
+func (v *Vault) verifyAdminUnsafe(name string, passphrase string) (*Admin, error) {
+	slot, ok := v.keyslots[name]
+	if !ok {
+		log.Info().Str("admin", name).Msg("unknown admin")
+		return nil, errors.New("failed to verify passphrase")
+	}
+
+	rawKey, err := slot.unwrap(v.Options().Secure, passphrase)
+	if err != nil {
+		log.Info().Str("admin", name).Msg("incorrect passphrase specified")
+		return nil, errors.New("failed to verify passphrase")
+	}
+
+	checkKey := memguard.NewBufferFromBytes(rawKey)
+	defer checkKey.Destroy()
+
+	identityKey, err := v.identityKey.Open()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to open identity key")
+		return nil, errors.New("failed to verify passphrase")
+	}
+
+	defer identityKey.Destroy()
+
+	if subtle.ConstantTimeCompare(checkKey.Bytes(), identityKey.Bytes()) != 1 {
+		log.Error().Str("admin", name).Msg("admin keyslot doesn't match identity key")
+		return nil, errors.New("failed to verify passphrase")
+	}
+
+	admin := slot.Admin
+	return &admin, nil
+}
+
+// Admin returns the named admin, or nil if there is no such admin.
+func (v *Vault) Admin(name string) *Admin {
+	if name == "" || name == RootAdmin {
+		return rootAdmin()
+	}
+
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	slot, ok := v.keyslots[name]
+	if !ok {
+		return nil
+	}
+
+	admin := slot.Admin
+	return &admin
+}
+
+func (v *Vault) Admins() []Admin {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	admins := []Admin{*rootAdmin()}
+	for _, slot := range v.keyslots {
+		admins = append(admins, slot.Admin)
+	}
+
+	slices.SortFunc(admins[1:], func(a, b Admin) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return admins
+}
+
+func (v *Vault) AddAdmin(name string, role Role, passphrase string) (*Admin, error) {
+	if !adminNamePattern.MatchString(name) {
+		return nil, errors.New("invalid admin name: " + name)
+	} else if name == RootAdmin {
+		return nil, errors.New("admin already exists: " + name)
+	}
+
+	if _, err := ParseRole(string(role)); err != nil {
+		return nil, err
+	}
+
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	if _, ok := v.keyslots[name]; ok {
+		return nil, errors.New("admin already exists: " + name)
+	}
+
+	identityKey, err := v.identityKey.Open()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to open identity key")
+		return nil, errors.New("failed to add admin")
+	}
+
+	defer identityKey.Destroy()
+
+	slot, err := wrapKeyslot(v.Options().Secure, passphrase, identityKey)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to wrap identity key")
+		return nil, errors.New("failed to add admin")
+	}
+
+	slot.Admin = Admin{
+		Name:      name,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+
+	slots := maps.Clone(v.keyslots)
+	slots[name] = *slot
+
+	if err = v.writeKeyslotsUnsafe(slots); err != nil {
+		log.Error().Err(err).Msg("failed to write admin keyslots")
+		return nil, errors.New("failed to add admin")
+	}
+
+	v.keyslots = slots
+
+	admin := slot.Admin
+	return &admin, nil
+}
+
+func (v *Vault) RemoveAdmin(name string) error {
+	if name == "" || name == RootAdmin {
+		return errors.New("the root admin can't be removed")
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.IsLocked() {
+		return errors.New("vault is locked")
+	}
+
+	if _, ok := v.keyslots[name]; !ok {
+		return errors.New("admin not found: " + name)
+	}
+
+	slots := maps.Clone(v.keyslots)
+	delete(slots, name)
+
+	if err := v.writeKeyslotsUnsafe(slots); err != nil {
+		log.Error().Err(err).Msg("failed to write admin keyslots")
+		return errors.New("failed to remove admin")
+	}
+
+	v.keyslots = slots
+
+	return nil
+}
+
+func (v *Vault) writeKeyslotsUnsafe(slots map[string]keyslot) error {
+	metadataHmacSecret, err := v.metadataHmacSecret.Open()
+	if err != nil {
+		return err
+	}
+
+	defer metadataHmacSecret.Destroy()
+
+	return writeKeyslots(v.backend(), slots, metadataHmacSecret)
+}
+
+func rootAdmin() *Admin {
+	return &Admin{
+		Name: RootAdmin,
+		Role: RoleAdmin,
+	}
+}
+
+func wrapKeyslot(secure bool, passphrase string, identityKey *memguard.LockedBuffer) (*keyslot, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	wrappingKey, err := deriveWrappingKey(secure, passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer wrappingKey.Destroy()
+
+	gcm, err := newGcm(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return &keyslot{
+		Salt:       salt,
+		WrappedKey: gcm.Seal(nonce, nonce, identityKey.Bytes(), nil),
+	}, nil
+}
+
+func (k keyslot) unwrap(secure bool, passphrase string) ([]byte, error) {
+	wrappingKey, err := deriveWrappingKey(secure, passphrase, k.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer wrappingKey.Destroy()
+
+	gcm, err := newGcm(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(k.WrappedKey) < gcm.NonceSize() {
+		return nil, errors.New("invalid keyslot")
+	}
+
+	nonce := k.WrappedKey[:gcm.NonceSize()]
+
+	return gcm.Open(nil, nonce, k.WrappedKey[gcm.NonceSize():], nil)
+}
+
+func deriveWrappingKey(secure bool, passphrase string, salt []byte) (*memguard.LockedBuffer, error) {
+	passphraseBytes := []byte(passphrase)
+	defer memguard.WipeBytes(passphraseBytes)
+	defer memguard.WipeBytes(*(*[]byte)(unsafe.Pointer(&passphrase)))
+
+	if secure {
+		passphraseBytes = append(passphraseBytes, sentinel...)
+	}
+
+	rawKey, err := scrypt.Key(passphraseBytes, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return memguard.NewBufferFromBytes(rawKey), nil
+}
+
+func newGcm(key *memguard.LockedBuffer) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(c)
+}
+
+// readKeyslots reads all admin keyslots. The file is only authenticated if
+// the HMAC secret is provided.
+func readKeyslots(backend Backend, hmacSecret *memguard.LockedBuffer) (map[string]keyslot, error) {
+	slotBytes, err := backend.ReadFile(keyslotsPath)
+	if err != nil {
+		return nil, err
+	} else if slotBytes == nil {
+		return make(map[string]keyslot), nil
+	} else if len(slotBytes) < 32 {
+		return nil, errors.New("invalid keyslots: file too short")
+	}
+
+	content := slotBytes[:len(slotBytes)-32]
+
+	if hmacSecret != nil {
+		h := hmac.New(sha256.New, hmacSecret.Bytes())
+		h.Write(content)
+		if !bytes.Equal(h.Sum(nil), slotBytes[len(slotBytes)-32:]) {
+			return nil, errors.New("invalid keyslots: checksum mismatch")
+		}
+	}
+
+	var slots map[string]keyslot
+	if err = json.Unmarshal(content, &slots); err != nil {
+		return nil, fmt.Errorf("invalid keyslots: %v", err)
+	}
+
+	if slots == nil {
+		slots = make(map[string]keyslot)
+	}
+
+	return slots, nil
+}
+
+func writeKeyslots(backend Backend, slots map[string]keyslot, hmacSecret *memguard.LockedBuffer) error {
+	slotBytes, err := json.Marshal(slots)
+	if err != nil {
+		return err
+	}
+
+	h := hmac.New(sha256.New, hmacSecret.Bytes())
+	h.Write(slotBytes)
+
+	return backend.WriteFile(keyslotsPath, append(slotBytes, h.Sum(nil)...))
+}
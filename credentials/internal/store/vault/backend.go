@@ -28,6 +28,7 @@ type Backend interface {
 	ListFiles(string) ([]string, error)
 	ReadFile(string) ([]byte, error)
 	WriteFile(string, []byte) error
+	AppendFile(string, []byte) error
 	DeleteFile(string) (bool, error)
 }
 
@@ -108,6 +109,32 @@ func (b *localStorageBackend) WriteFile(path string, data []byte) error {
 	return nil
 }
 
+func (b *localStorageBackend) AppendFile(path string, data []byte) error {
+	appendPath := b.cleanPath(path)
+
+	parentDir := filepath.Dir(appendPath)
+	err := os.MkdirAll(parentDir, 0700)
+	if err != nil {
+		return fmt.Errorf("error creating path: %s (%v)", path, err)
+	}
+
+	file, err := os.OpenFile(appendPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening file: %s (%v)", path, err)
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("error appending to file: %s (%v)", path, err)
+	}
+
+	return nil
+}
+
 func (b *localStorageBackend) DeleteFile(path string) (bool, error) {
 	deletePath := b.cleanPath(path)
 
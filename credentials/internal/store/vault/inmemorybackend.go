@@ -68,6 +68,12 @@ func (i *inMemoryBackend) WriteFile(path string, bytes []byte) error {
 	return nil
 }
 
+func (i *inMemoryBackend) AppendFile(path string, bytes []byte) error {
+	i.files[path] = append(i.files[path], bytes...)
+
+	return nil
+}
+
 func (i *inMemoryBackend) DeleteFile(path string) (bool, error) {
 	_, ok := i.files[path]
 	if !ok {
@@ -0,0 +1,127 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// markerPath records the identity and mode of the vault, it's written when
+// the vault is initialized
+const markerPath = ".vault"
+
+var (
+	// ErrNotInitialized is returned when unlocking a vault without an
+	// identity, e.g. because the storage path is wrong or not mounted
+	ErrNotInitialized = errors.New("the vault isn't initialized")
+	// ErrInitialized is returned when initializing an existing vault
+	ErrInitialized = errors.New("the vault is initialized already")
+	// ErrIncompatible is returned if the vault can't be opened with the
+	// options of the store
+	ErrIncompatible = errors.New("the vault is incompatible with the store")
+)
+
+type Marker struct {
+	Id            uuid.UUID `json:"id"`
+	Secure        bool      `json:"secure"`
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func readMarker(backend Backend) (*Marker, error) {
+	data, err := backend.ReadFile(markerPath)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var marker Marker
+	if err = json.Unmarshal(data, &marker); err != nil {
+		return nil, fmt.Errorf("failed to read vault marker: %v", err)
+	}
+
+	return &marker, nil
+}
+
+func writeMarker(backend Backend, marker Marker) error {
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+
+	return backend.WriteFile(markerPath, data)
+}
+
+// checkMarker verifies the vault can be opened with the options, vaults
+// created before the marker was introduced can't be checked
+func checkMarker(marker *Marker, options *Options) error {
+	if marker == nil {
+		return nil
+	}
+
+	if marker.FormatVersion > FormatVersion {
+		return fmt.Errorf("%w: format version %d isn't supported, at most %d is", ErrIncompatible, marker.FormatVersion, FormatVersion)
+	}
+
+	if marker.Secure && !options.Secure {
+		return fmt.Errorf("%w: the vault was created in production mode, but the store runs in development mode", ErrIncompatible)
+	} else if !marker.Secure && options.Secure {
+		return fmt.Errorf("%w: the vault was created in development mode, but the store runs in production mode", ErrIncompatible)
+	}
+
+	return nil
+}
+
+func newMarker(options *Options) Marker {
+	return Marker{
+		Id:            uuid.New(),
+		Secure:        options.Secure,
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+// Marker returns the marker of the vault, nil if the vault isn't initialized
+// or was created before markers were introduced
+func (v *Vault) Marker() (*Marker, error) {
+	return readMarker(v.backend())
+}
+
+// CreateMarker writes the marker of a vault created before markers were
+// introduced, it returns the marker if one was written
+func (v *Vault) CreateMarker() (*Marker, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	existing, err := readMarker(v.backend())
+	if err != nil || existing != nil {
+		return nil, err
+	}
+
+	marker := newMarker(v.options)
+	if err = writeMarker(v.backend(), marker); err != nil {
+		return nil, err
+	}
+
+	return &marker, nil
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//goland:noinspection GoRedundantConversion
+func TestUnlock_NotInitialized(t *testing.T) {
+	vault, err := NewVault(&Options{Backend: &inMemoryBackend{}})
+	require.NoError(t, err)
+
+	err = vault.Unlock(string([]byte("correct_passphrase")))
+	assert.ErrorIs(t, err, ErrNotInitialized)
+	assert.True(t, vault.IsLocked())
+
+	identity, err := vault.backend().ReadFile(".identity")
+	assert.NoError(t, err)
+	assert.Nil(t, identity)
+}
+
+//goland:noinspection GoRedundantConversion
+func TestInitialize(t *testing.T) {
+	// the in-memory backend doesn't survive reopening the vault
+	backend := NewLocalStorageBackend(t.TempDir())
+	vault, err := NewVault(&Options{Backend: backend, Secure: true})
+	require.NoError(t, err)
+
+	marker, err := vault.Initialize(string([]byte("correct_passphrase")), nil)
+	require.NoError(t, err)
+	assert.False(t, vault.IsLocked())
+	assert.True(t, marker.Secure)
+	assert.Equal(t, FormatVersion, marker.FormatVersion)
+
+	stored, err := vault.Marker()
+	require.NoError(t, err)
+	assert.Equal(t, marker.Id, stored.Id)
+
+	_, err = vault.Initialize(string([]byte("other_passphrase")), nil)
+	assert.ErrorIs(t, err, ErrInitialized)
+
+	// the mode is part of the key derivation, opening the vault in the other
+	// mode can only fail
+	_, err = NewVault(&Options{Backend: backend, Secure: false})
+	assert.ErrorIs(t, err, ErrIncompatible)
+}
+
+//goland:noinspection GoRedundantConversion
+func TestCreateMarker(t *testing.T) {
+	backend := &inMemoryBackend{}
+	vault, err := NewVault(&Options{Backend: backend})
+	require.NoError(t, err)
+
+	_, err = vault.Initialize(string([]byte("correct_passphrase")), nil)
+	require.NoError(t, err)
+
+	// vaults created before markers were introduced don't have one
+	_, err = backend.DeleteFile(markerPath)
+	require.NoError(t, err)
+
+	marker, err := vault.CreateMarker()
+	require.NoError(t, err)
+	require.NotNil(t, marker)
+	assert.False(t, marker.Secure)
+
+	marker, err = vault.CreateMarker()
+	assert.NoError(t, err)
+	assert.Nil(t, marker)
+}
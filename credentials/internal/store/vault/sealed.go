@@ -0,0 +1,135 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/awnumar/memguard"
+)
+
+// ReadSealedFile reads a file written by WriteSealedFile, the content is nil
+// if the file doesn't exist. The HMAC can only be checked while the vault is
+// unlocked, the returned flag tells whether the content was authenticated.
+func (v *Vault) ReadSealedFile(path string) ([]byte, bool, error) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	data, err := v.backend().ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	} else if data == nil {
+		return nil, false, nil
+	} else if len(data) < sha256.Size {
+		return nil, false, fmt.Errorf("invalid sealed file %s: file too short", path)
+	}
+
+	content := data[:len(data)-sha256.Size]
+
+	if v.IsLocked() {
+		return content, false, nil
+	}
+
+	hmacSecret, err := v.metadataHmacSecret.Open()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to access metadata HMAC secret: %v", err)
+	}
+
+	defer hmacSecret.Destroy()
+
+	if !hmac.Equal(sealedFileHmac(hmacSecret, path, content), data[len(content):]) {
+		return nil, false, fmt.Errorf("invalid sealed file %s: checksum mismatch", path)
+	}
+
+	return content, true, nil
+}
+
+// WriteSealedFile writes the content followed by an HMAC keyed from the
+// vault identity. The path is authenticated as well, so sealed files can't
+// be swapped for one another.
+func (v *Vault) WriteSealedFile(path string, content []byte) error {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if v.IsLocked() {
+		return errors.New("vault is locked")
+	}
+
+	hmacSecret, err := v.metadataHmacSecret.Open()
+	if err != nil {
+		return fmt.Errorf("failed to access metadata HMAC secret: %v", err)
+	}
+
+	defer hmacSecret.Destroy()
+
+	data := make([]byte, 0, len(content)+sha256.Size)
+	data = append(data, content...)
+	data = append(data, sealedFileHmac(hmacSecret, path, content)...)
+
+	return v.backend().WriteFile(path, data)
+}
+
+// ReadSecretFile decrypts a file written by WriteSecretFile, nil if it
+// doesn't exist
+func (v *Vault) ReadSecretFile(path string) (*memguard.LockedBuffer, error) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if v.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	data, err := v.backend().ReadFile(path)
+	if err != nil {
+		return nil, err
+	} else if data == nil {
+		return nil, nil
+	}
+
+	return v.decryptFromRestUnsafe(data)
+}
+
+// WriteSecretFile encrypts the value like an item value, but outside the
+// item namespace, so it can't be listed or read through the item operations.
+// The value is destroyed.
+func (v *Vault) WriteSecretFile(path string, value *memguard.LockedBuffer) error {
+	defer value.Destroy()
+
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if v.IsLocked() {
+		return errors.New("vault is locked")
+	}
+
+	data, err := v.encryptForRestUnsafe(value)
+	if err != nil {
+		return err
+	}
+
+	return v.backend().WriteFile(path, data)
+}
+
+func sealedFileHmac(hmacSecret *memguard.LockedBuffer, path string, content []byte) []byte {
+	h := hmac.New(sha256.New, hmacSecret.Bytes())
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(content)
+
+	return h.Sum(nil)
+}
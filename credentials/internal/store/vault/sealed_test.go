@@ -0,0 +1,80 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vault
+
+import (
+	"github.com/awnumar/memguard"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//goland:noinspection GoRedundantConversion
+func TestSealedFile(t *testing.T) {
+	vault, err := NewVault(&Options{Backend: NewLocalStorageBackend(t.TempDir())})
+	require.NoError(t, err)
+
+	_, err = vault.Initialize(string([]byte("correct_passphrase")), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, vault.WriteSealedFile(".ca/state.json", []byte(`{"a":1}`)))
+
+	content, authenticated, err := vault.ReadSealedFile(".ca/state.json")
+	require.NoError(t, err)
+	assert.True(t, authenticated)
+	assert.Equal(t, `{"a":1}`, string(content))
+
+	// the path is authenticated as well
+	data, err := vault.backend().ReadFile(".ca/state.json")
+	require.NoError(t, err)
+	require.NoError(t, vault.backend().WriteFile(".ca/other.json", data))
+
+	_, _, err = vault.ReadSealedFile(".ca/other.json")
+	assert.Error(t, err)
+
+	require.NoError(t, vault.Lock())
+
+	content, authenticated, err = vault.ReadSealedFile(".ca/other.json")
+	require.NoError(t, err)
+	assert.False(t, authenticated)
+	assert.Equal(t, `{"a":1}`, string(content))
+
+	assert.Error(t, vault.WriteSealedFile(".ca/state.json", []byte(`{}`)))
+}
+
+//goland:noinspection GoRedundantConversion
+func TestSecretFile(t *testing.T) {
+	vault, err := NewVault(&Options{Backend: NewLocalStorageBackend(t.TempDir())})
+	require.NoError(t, err)
+
+	_, err = vault.Initialize(string([]byte("correct_passphrase")), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, vault.WriteSecretFile(".ca/key.age", memguard.NewBufferFromBytes([]byte("secret key"))))
+	assert.Empty(t, vault.Items())
+
+	value, err := vault.ReadSecretFile(".ca/key.age")
+	require.NoError(t, err)
+	defer value.Destroy()
+
+	assert.Equal(t, "secret key", value.String())
+
+	require.NoError(t, vault.Lock())
+
+	_, err = vault.ReadSecretFile(".ca/key.age")
+	assert.Error(t, err)
+}
@@ -17,6 +17,7 @@ package vault
 
 import (
 	"bytes"
+	"cmp"
 	"crypto/sha256"
 	"errors"
 	"filippo.io/age"
@@ -34,6 +35,10 @@ import (
 
 const sentinel = "sentinel"
 
+// FormatVersion is the version of the layout of the vault files, it's raised
+// whenever a change requires migrating existing vaults
+const FormatVersion = 1
+
 type Options struct {
 	Backend
 	Secure bool
@@ -44,6 +49,37 @@ type Item struct {
 	Description string    `json:"description"`
 	Checksum    string    `json:"checksum"`
 	ModifiedAt  time.Time `json:"modified_at"`
+	// reading the value requires the approval of a second admin
+	RequiresApproval bool `json:"requires_approval,omitempty"`
+	// the item is deleted once it was read MaxReads times, 0 is unlimited
+	MaxReads int `json:"max_reads,omitempty"`
+	Reads    int `json:"reads,omitempty"`
+	// the item is deleted once it expired, reading it fails from then on
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// selected by ReadVaultItems and searches, the keys are unique
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// IsLimited returns whether reading the item is restricted in any way
+func (i Item) IsLimited() bool {
+	return i.MaxReads > 0 || i.ExpiresAt != nil
+}
+
+// RemainingReads returns the number of reads left, -1 if unlimited
+func (i Item) RemainingReads() int {
+	if i.MaxReads <= 0 {
+		return -1
+	}
+
+	return max(i.MaxReads-i.Reads, 0)
+}
+
+func (i Item) isExhausted() bool {
+	return i.MaxReads > 0 && i.Reads >= i.MaxReads
+}
+
+func (i Item) isExpired(now time.Time) bool {
+	return i.ExpiresAt != nil && !now.Before(*i.ExpiresAt)
 }
 
 type Vault struct {
@@ -54,6 +90,7 @@ type Vault struct {
 	primaryRecipient   *age.X25519Recipient
 	recoveryRecipient  *age.X25519Recipient
 	items              map[uuid.UUID]Item
+	keyslots           map[string]keyslot
 }
 
 func (v *Vault) backend() Backend {
@@ -74,6 +111,13 @@ func NewVault(options *Options) (*Vault, error) {
 		return nil, fmt.Errorf("failed to initialize backend: %w", err)
 	}
 
+	marker, err := readMarker(options.Backend)
+	if err != nil {
+		return nil, err
+	} else if err = checkMarker(marker, options); err != nil {
+		return nil, err
+	}
+
 	recoveryRecipient, err := loadRecoveryRecipient(options.Backend)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load recovery recipient: %v", err)
@@ -87,6 +131,7 @@ func NewVault(options *Options) (*Vault, error) {
 		primaryRecipient:   nil,
 		recoveryRecipient:  recoveryRecipient,
 		items:              nil,
+		keyslots:           nil,
 	}, nil
 }
 
@@ -98,17 +143,74 @@ func (v *Vault) Unlock(passphrase string) error {
 		return nil
 	}
 
-	passphraseBytes := *(*[]byte)(unsafe.Pointer(&passphrase))
-	hasher := sha256.New()
-	hasher.Write(passphraseBytes)
-	if v.Options().Secure {
-		hasher.Write([]byte(sentinel))
+	if err := v.checkMarkerUnsafe(); err != nil {
+		return err
 	}
 
-	rawSum := hasher.Sum(nil)
-	memguard.WipeBytes(passphraseBytes)
+	return v.unlockUnsafe(v.deriveIdentityKey(passphrase), false)
+}
 
-	v.identityKey = memguard.NewEnclave(rawSum)
+// Initialize creates the identity of a new vault protected by the root
+// passphrase and leaves the vault unlocked. The recovery recipient is
+// optional.
+func (v *Vault) Initialize(passphrase string, recoveryRecipient *age.X25519Recipient) (*Marker, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	identityBytes, err := v.backend().ReadFile(".identity")
+	if err != nil {
+		return nil, err
+	} else if identityBytes != nil {
+		memguard.WipeBytes(identityBytes)
+		return nil, ErrInitialized
+	}
+
+	if existing, err := readMarker(v.backend()); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("%w, but its identity is missing", ErrInitialized)
+	}
+
+	if recoveryRecipient != nil {
+		if err = writeRecoveryRecipient(v.backend(), *recoveryRecipient); err != nil {
+			return nil, err
+		}
+
+		v.recoveryRecipient = recoveryRecipient
+	}
+
+	if err = v.unlockUnsafe(v.deriveIdentityKey(passphrase), true); err != nil {
+		return nil, err
+	}
+
+	marker := newMarker(v.options)
+	if err = writeMarker(v.backend(), marker); err != nil {
+		v.identityKey = nil
+		v.metadataHmacSecret = nil
+		v.primaryRecipient = nil
+		v.items = nil
+		v.keyslots = nil
+
+		// the identity exists, the marker is written when it's unlocked
+		return nil, fmt.Errorf("failed to write vault marker: %v", err)
+	}
+
+	return &marker, nil
+}
+
+func (v *Vault) checkMarkerUnsafe() error {
+	marker, err := readMarker(v.backend())
+	if err != nil {
+		return err
+	}
+
+	return checkMarker(marker, v.options)
+}
+
+// unlockUnsafe opens the vault using the raw identity key. If create is set
+// and no identity exists yet, a new one is generated.
+func (v *Vault) unlockUnsafe(rawKey []byte, create bool) error {
+	v.identityKey = memguard.NewEnclave(rawKey)
 
 	identityBytes, err := v.backend().ReadFile(".identity")
 	if err != nil {
@@ -132,13 +234,18 @@ func (v *Vault) Unlock(passphrase string) error {
 
 		v.metadataHmacSecret = deriveMetadataHmacSecret(*identity)
 		v.primaryRecipient = identity.Recipient()
+	} else if !create {
+		v.identityKey = nil
+
+		log.Debug().Msg("identity file doesn't exist, vault isn't initialized")
+		return ErrNotInitialized
 	} else {
 		identity, err := age.GenerateX25519Identity()
 		if err != nil {
 			v.identityKey = nil
 
 			log.Error().Err(err).Msg("failed to generate primary identity")
-			return errors.New("failed to verify passphrase")
+			return errors.New("failed to create identity")
 		}
 
 		identityKey, _ := v.identityKey.Open()
@@ -146,8 +253,10 @@ func (v *Vault) Unlock(passphrase string) error {
 
 		err = writeIdentity(v.backend(), identityKey, identity)
 		if err != nil {
+			v.identityKey = nil
+
 			log.Err(err).Msg("failed to write identity")
-			return errors.New("failed to verify passphrase")
+			return errors.New("failed to create identity")
 		}
 
 		v.metadataHmacSecret = deriveMetadataHmacSecret(*identity)
@@ -177,17 +286,18 @@ func (v *Vault) Unlock(passphrase string) error {
 		return errors.New("failed to verify passphrase")
 	}
 
+	v.keyslots, err = readKeyslots(v.backend(), metadataHmacSecret)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to read admin keyslots, only the root admin is available")
+		v.keyslots = make(map[string]keyslot)
+	}
+
 	return nil
 }
 
-func (v *Vault) VerifyPassphrase(passphrase string) error {
-	v.lock.RLock()
-	defer v.lock.RUnlock()
-
-	if v.IsLocked() {
-		return errors.New("vault is locked")
-	}
-
+// deriveIdentityKey turns the root passphrase into the key protecting the
+// identity. The passphrase is wiped afterward.
+func (v *Vault) deriveIdentityKey(passphrase string) []byte {
 	passphraseBytes := *(*[]byte)(unsafe.Pointer(&passphrase))
 	hasher := sha256.New()
 	hasher.Write(passphraseBytes)
@@ -198,7 +308,18 @@ func (v *Vault) VerifyPassphrase(passphrase string) error {
 	rawSum := hasher.Sum(nil)
 	memguard.WipeBytes(passphraseBytes)
 
-	checkKey := memguard.NewBufferFromBytes(rawSum)
+	return rawSum
+}
+
+func (v *Vault) VerifyPassphrase(passphrase string) error {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if v.IsLocked() {
+		return errors.New("vault is locked")
+	}
+
+	checkKey := memguard.NewBufferFromBytes(v.deriveIdentityKey(passphrase))
 
 	defer checkKey.Destroy()
 
@@ -218,6 +339,67 @@ func (v *Vault) VerifyPassphrase(passphrase string) error {
 	return nil
 }
 
+// RecoveryRecipient returns the recipient the items are additionally
+// encrypted for, nil if not set
+func (v *Vault) RecoveryRecipient() *age.X25519Recipient {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	return v.recoveryRecipient
+}
+
+// HasIdentity returns whether the vault was created already
+func (v *Vault) HasIdentity() (bool, error) {
+	identityBytes, err := v.backend().ReadFile(".identity")
+	if err != nil {
+		return false, err
+	}
+
+	memguard.WipeBytes(identityBytes)
+
+	return identityBytes != nil, nil
+}
+
+// Reload reads the files changed by someone else, e.g. by the replication
+// from another store
+func (v *Vault) Reload() error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	recoveryRecipient, err := loadRecoveryRecipient(v.backend())
+	if err != nil {
+		return fmt.Errorf("failed to load recovery recipient: %v", err)
+	}
+
+	v.recoveryRecipient = recoveryRecipient
+
+	if v.IsLocked() {
+		return nil
+	}
+
+	metadataHmacSecret, err := v.metadataHmacSecret.Open()
+	if err != nil {
+		return fmt.Errorf("failed to access metadata HMAC secret: %v", err)
+	}
+
+	defer metadataHmacSecret.Destroy()
+
+	items, err := readAllMetadataUnsafe(v.backend(), metadataHmacSecret)
+	if err != nil {
+		return fmt.Errorf("failed to read all item metadata: %v", err)
+	}
+
+	keyslots, err := readKeyslots(v.backend(), metadataHmacSecret)
+	if err != nil {
+		return fmt.Errorf("failed to read admin keyslots: %v", err)
+	}
+
+	v.items = items
+	v.keyslots = keyslots
+
+	return nil
+}
+
 func (v *Vault) Lock() error {
 	v.lock.Lock()
 	defer v.lock.Unlock()
@@ -230,6 +412,7 @@ func (v *Vault) Lock() error {
 	v.metadataHmacSecret = nil
 	v.primaryRecipient = nil
 	v.items = nil
+	v.keyslots = nil
 
 	return nil
 }
@@ -350,7 +533,7 @@ func (v *Vault) DeleteItem(id uuid.UUID) error {
 		return errors.New("vault is locked")
 	}
 
-	ok := v.deleteItemUnsafe(id)
+	ok, _ := v.deleteItemUnsafe(id)
 	if !ok {
 		log.Warn().Str("item", id.String()).Msg("no such item")
 	}
@@ -359,6 +542,17 @@ func (v *Vault) DeleteItem(id uuid.UUID) error {
 }
 
 func (v *Vault) GetItem(id uuid.UUID) (*memguard.LockedBuffer, error) {
+	value, limited, err := v.getUnlimitedItem(id)
+	if limited {
+		return v.getLimitedItem(id)
+	}
+
+	return value, err
+}
+
+// PeekItem reads the value without counting the read, for values the store
+// itself checks against, e.g. client credentials. Expired items aren't read.
+func (v *Vault) PeekItem(id uuid.UUID) (*memguard.LockedBuffer, error) {
 	v.lock.RLock()
 	defer v.lock.RUnlock()
 
@@ -367,7 +561,7 @@ func (v *Vault) GetItem(id uuid.UUID) (*memguard.LockedBuffer, error) {
 	}
 
 	item, ok := v.items[id]
-	if !ok {
+	if !ok || item.isExpired(time.Now()) {
 		return nil, errors.New("item not found")
 	}
 
@@ -378,6 +572,251 @@ func (v *Vault) GetItem(id uuid.UUID) (*memguard.LockedBuffer, error) {
 	return v.readItemValueUnsafe(item)
 }
 
+func (v *Vault) getUnlimitedItem(id uuid.UUID) (*memguard.LockedBuffer, bool, error) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if v.IsLocked() {
+		return nil, false, errors.New("vault is locked")
+	}
+
+	item, ok := v.items[id]
+	if !ok {
+		return nil, false, errors.New("item not found")
+	}
+
+	if item.Checksum == "" {
+		return nil, false, nil
+	}
+
+	if item.IsLimited() {
+		return nil, true, nil
+	}
+
+	value, err := v.readItemValueUnsafe(item)
+
+	return value, false, err
+}
+
+// getLimitedItem counts the read under the write lock, so concurrent reads
+// never exceed the limit, and deletes the item once it is exhausted.
+func (v *Vault) getLimitedItem(id uuid.UUID) (*memguard.LockedBuffer, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	item, ok := v.items[id]
+	if !ok {
+		return nil, errors.New("item not found")
+	}
+
+	if item.isExpired(time.Now()) {
+		_, _ = v.deleteItemUnsafe(id)
+		log.Info().Str("item", id.String()).Msg("vault item expired, deleted")
+
+		return nil, errors.New("item not found")
+	}
+
+	// the deletion of the item failed after its last read
+	if item.isExhausted() {
+		_, _ = v.deleteItemUnsafe(id)
+		log.Info().Str("item", id.String()).Msg("vault item exhausted, deleted")
+
+		return nil, errors.New("item not found")
+	}
+
+	value, err := v.readItemValueUnsafe(item)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.MaxReads <= 0 {
+		return value, nil
+	}
+
+	// the read is persisted first, so the item can't be read again even if
+	// deleting it fails
+	item.Reads++
+
+	if err = v.persistItemMetadataUnsafe(item); err != nil {
+		value.Destroy()
+		log.Error().Err(err).Str("item", id.String()).Msg("failed to write item metadata")
+
+		return nil, errors.New("failed to read item")
+	}
+
+	v.items[id] = item
+
+	if item.isExhausted() {
+		if _, err = v.deleteItemUnsafe(id); err != nil {
+			value.Destroy()
+			log.Error().Err(err).Str("item", id.String()).Msg("failed to delete exhausted vault item")
+
+			return nil, errors.New("failed to read item")
+		}
+
+		log.Info().Str("item", id.String()).Msg("vault item exhausted, deleted")
+	}
+
+	return value, nil
+}
+
+// SetItemReadLimit restricts how many times and until when the item may be
+// read, maxReads of 0 and a nil expiresAt remove the respective limit.
+func (v *Vault) SetItemReadLimit(id uuid.UUID, maxReads int, expiresAt *time.Time) (*Item, error) {
+	if maxReads < 0 {
+		return nil, errors.New("max reads must not be negative")
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	item, ok := v.items[id]
+	if !ok {
+		return nil, errors.New("item not found")
+	}
+
+	item.MaxReads = maxReads
+	item.Reads = 0
+	item.ExpiresAt = expiresAt
+
+	if err := v.persistItemMetadataUnsafe(item); err != nil {
+		log.Error().Err(err).Str("item", item.Id.String()).Msg("failed to write item metadata")
+		return nil, errors.New("failed to update item")
+	}
+
+	v.items[id] = item
+
+	return &item, nil
+}
+
+// PurgeExpiredItems deletes all items past their deadline and returns their
+// ids
+func (v *Vault) PurgeExpiredItems() []uuid.UUID {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.IsLocked() {
+		return nil
+	}
+
+	var purged []uuid.UUID
+
+	now := time.Now()
+	for id, item := range v.items {
+		if item.isExpired(now) {
+			_, _ = v.deleteItemUnsafe(id)
+			log.Info().Str("item", id.String()).Msg("vault item expired, deleted")
+
+			purged = append(purged, id)
+		}
+	}
+
+	return purged
+}
+
+func (v *Vault) persistItemMetadataUnsafe(item Item) error {
+	metadataHmacSecret, err := v.metadataHmacSecret.Open()
+	if err != nil {
+		return err
+	}
+
+	defer metadataHmacSecret.Destroy()
+
+	return writeItemMetadataUnsafe(v.backend(), item, metadataHmacSecret)
+}
+
+// ItemMetadata returns the metadata of the item without reading its value
+func (v *Vault) ItemMetadata(id uuid.UUID) (*Item, error) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if v.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	item, ok := v.items[id]
+	if !ok {
+		return nil, errors.New("item not found")
+	}
+
+	return &item, nil
+}
+
+func (v *Vault) SetItemRequiresApproval(id uuid.UUID, requiresApproval bool) (*Item, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	item, ok := v.items[id]
+	if !ok {
+		return nil, errors.New("item not found")
+	}
+
+	item.RequiresApproval = requiresApproval
+
+	metadataHmacSecret, err := v.metadataHmacSecret.Open()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to access metadata HMAC secret")
+		return nil, errors.New("failed to update item")
+	}
+
+	defer metadataHmacSecret.Destroy()
+
+	if err = writeItemMetadataUnsafe(v.backend(), item, metadataHmacSecret); err != nil {
+		log.Error().Err(err).Str("item", item.Id.String()).Msg("failed to write item metadata")
+		return nil, errors.New("failed to update item")
+	}
+
+	v.items[id] = item
+
+	return &item, nil
+}
+
+// SetItemLabels replaces the labels of the item, nil removes all of them
+func (v *Vault) SetItemLabels(id uuid.UUID, labels map[string]string) (*Item, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.IsLocked() {
+		return nil, errors.New("vault is locked")
+	}
+
+	item, ok := v.items[id]
+	if !ok {
+		return nil, errors.New("item not found")
+	}
+
+	item.Labels = maps.Clone(labels)
+
+	metadataHmacSecret, err := v.metadataHmacSecret.Open()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to access metadata HMAC secret")
+		return nil, errors.New("failed to update item")
+	}
+
+	defer metadataHmacSecret.Destroy()
+
+	if err = writeItemMetadataUnsafe(v.backend(), item, metadataHmacSecret); err != nil {
+		log.Error().Err(err).Str("item", item.Id.String()).Msg("failed to write item metadata")
+		return nil, errors.New("failed to update item")
+	}
+
+	v.items[id] = item
+
+	return &item, nil
+}
+
 func (v *Vault) SetItemValue(id uuid.UUID, value *memguard.LockedBuffer) error {
 	if len(value.Bytes()) == 0 {
 		return errors.New("value is empty")
@@ -472,30 +911,32 @@ func (v *Vault) writeItemValueUnsafe(item Item, value *memguard.LockedBuffer) er
 	return nil
 }
 
-func (v *Vault) deleteItemUnsafe(id uuid.UUID) bool {
+// deleteItemUnsafe returns whether the item existed and the first error of
+// deleting its files
+func (v *Vault) deleteItemUnsafe(id uuid.UUID) (bool, error) {
 	item, ok := v.items[id]
 	if !ok {
-		return false
+		return false, nil
 	}
 
 	delete(v.items, id)
 
 	removed := false
 
-	ok, err := v.backend().DeleteFile(metadataPath(item))
-	if err != nil {
+	ok, metadataErr := v.backend().DeleteFile(metadataPath(item))
+	if metadataErr != nil {
 		log.Debug().
-			Err(err).
+			Err(metadataErr).
 			Str("item", item.Id.String()).
 			Msg("failed to delete item metadata file")
 	} else if ok {
 		removed = true
 	}
 
-	ok, err = v.backend().DeleteFile(valuePath(item))
-	if err != nil {
+	ok, valueErr := v.backend().DeleteFile(valuePath(item))
+	if valueErr != nil {
 		log.Debug().
-			Err(err).
+			Err(valueErr).
 			Str("item", item.Id.String()).
 			Msg("failed to delete item value file")
 	} else if ok {
@@ -506,7 +947,7 @@ func (v *Vault) deleteItemUnsafe(id uuid.UUID) bool {
 		log.Info().Str("item", item.Id.String()).Msg("removed files for item")
 	}
 
-	return true
+	return true, cmp.Or(metadataErr, valueErr)
 }
 
 func (v *Vault) decryptFromRestUnsafe(data []byte) (*memguard.LockedBuffer, error) {
@@ -17,6 +17,7 @@ package vault
 
 import (
 	"bytes"
+	"errors"
 	"filippo.io/age"
 	"github.com/awnumar/memguard"
 	"github.com/google/uuid"
@@ -74,8 +75,8 @@ func TestUnlock_InMemory(t *testing.T) {
 
 //goland:noinspection GoRedundantConversion
 func testUnlock(t *testing.T, vault *Vault) {
-	// Test unlocking with the correct passphrase
-	err := vault.Unlock(string([]byte("correct_passphrase")))
+	// Test initializing the vault
+	_, err := vault.Initialize(string([]byte("correct_passphrase")), nil)
 	assert.NoError(t, err)
 	assert.False(t, vault.IsLocked())        // Vault should be unlocked
 	assert.NotNil(t, vault.primaryRecipient) // Primary recipient should be set
@@ -131,7 +132,7 @@ func testVerifyPassphrase(t *testing.T, vault *Vault) {
 	// Define a passphrase
 	// Unlock the vault with the correct passphrase
 	//goland:noinspection GoRedundantConversion
-	err := vault.Unlock(string([]byte("correct_passphrase")))
+	_, err := vault.Initialize(string([]byte("correct_passphrase")), nil)
 	assert.NoError(t, err)
 
 	// Test verifying the passphrase with the correct passphrase
@@ -165,8 +166,8 @@ func TestVerifyPassphrase_EmptyPassphrase(t *testing.T) {
 	//goland:noinspection GoRedundantConversion
 	passphrase := string([]byte("correct_passphrase"))
 
-	// Unlock the vault with the correct passphrase
-	err = vault.Unlock(passphrase)
+	// Initialize the vault with the correct passphrase
+	_, err = vault.Initialize(passphrase, nil)
 	assert.NoError(t, err)
 
 	// Test verifying with an empty passphrase
@@ -194,7 +195,7 @@ func TestSetRecoveryRecipient_InMemory(t *testing.T) {
 func testSetRecoveryRecipient(t *testing.T, vault *Vault) {
 	// Define a valid passphrase and unlock the vault
 	//goland:noinspection GoRedundantConversion
-	err := vault.Unlock(string([]byte("correct_passphrase")))
+	_, err := vault.Initialize(string([]byte("correct_passphrase")), nil)
 	assert.NoError(t, err)
 
 	// Create a new recovery recipient
@@ -241,7 +242,7 @@ func TestCreateItem_InMemory(t *testing.T) {
 func testCreateItem(t *testing.T, vault *Vault) {
 
 	//goland:noinspection GoRedundantConversion
-	err := vault.Unlock(string([]byte("correct_passphrase")))
+	_, err := vault.Initialize(string([]byte("correct_passphrase")), nil)
 	assert.NoError(t, err)
 
 	// Test creating an item
@@ -273,7 +274,7 @@ func TestDeleteItem_InMemory(t *testing.T) {
 
 func testDeleteItem(t *testing.T, vault *Vault) {
 	//goland:noinspection GoRedundantConversion
-	err := vault.Unlock(string([]byte("correct_passphrase")))
+	_, err := vault.Initialize(string([]byte("correct_passphrase")), nil)
 	assert.NoError(t, err)
 
 	// Create an item to delete
@@ -310,7 +311,7 @@ func TestGetItem_InMemory(t *testing.T) {
 
 func testGetItem(t *testing.T, vault *Vault) {
 	//goland:noinspection GoRedundantConversion
-	err := vault.Unlock(string([]byte("correct_passphrase")))
+	_, err := vault.Initialize(string([]byte("correct_passphrase")), nil)
 	assert.NoError(t, err)
 
 	// Create an item
@@ -349,7 +350,7 @@ func TestSetItemValue_InMemory(t *testing.T) {
 
 func testSetItemValue(t *testing.T, vault *Vault) {
 	//goland:noinspection GoRedundantConversion
-	err := vault.Unlock(string([]byte("correct_passphrase")))
+	_, err := vault.Initialize(string([]byte("correct_passphrase")), nil)
 	assert.NoError(t, err)
 
 	// Create an item
@@ -398,7 +399,7 @@ func TestWriteItemValue_InMemory(t *testing.T) {
 
 func testWriteItemValue(t *testing.T, vault *Vault) {
 	//goland:noinspection GoRedundantConversion
-	err := vault.Unlock(string([]byte("correct_passphrase")))
+	_, err := vault.Initialize(string([]byte("correct_passphrase")), nil)
 	assert.NoError(t, err)
 
 	// Create an item
@@ -424,3 +425,185 @@ func testWriteItemValue(t *testing.T, vault *Vault) {
 	// Check that the encrypted data is not equal to the plain text value
 	assert.NotEqual(t, "test value", string(encryptedData)) // Ensure the stored data is not plain text
 }
+
+//goland:noinspection GoRedundantConversion
+func TestAdmins_InMemory(t *testing.T) {
+	vault, err := NewVault(&Options{Backend: &inMemoryBackend{}})
+	assert.NoError(t, err)
+
+	_, err = vault.Initialize(string([]byte("correct_passphrase")), nil)
+	assert.NoError(t, err)
+
+	item, err := vault.CreateItem("Test Item")
+	assert.NoError(t, err)
+
+	err = vault.SetItemValue(item.Id, memguard.NewBufferFromBytes([]byte("secret")))
+	assert.NoError(t, err)
+
+	admin, err := vault.AddAdmin("intern", RoleAuditor, string([]byte("intern_passphrase")))
+	assert.NoError(t, err)
+	assert.Equal(t, RoleAuditor, admin.Role)
+
+	_, err = vault.AddAdmin("intern", RoleAdmin, string([]byte("other_passphrase")))
+	assert.Error(t, err) // Names must be unique
+
+	_, err = vault.AddAdmin(RootAdmin, RoleAdmin, string([]byte("other_passphrase")))
+	assert.Error(t, err) // The root admin always exists
+
+	assert.Len(t, vault.Admins(), 2)
+
+	admin, err = vault.VerifyAdmin("intern", string([]byte("intern_passphrase")))
+	assert.NoError(t, err)
+	assert.Equal(t, "intern", admin.Name)
+
+	_, err = vault.VerifyAdmin("intern", string([]byte("correct_passphrase")))
+	assert.Error(t, err)
+
+	err = vault.Lock()
+	assert.NoError(t, err)
+
+	// The keyslot unlocks the same vault
+	admin, err = vault.UnlockAs("intern", string([]byte("intern_passphrase")))
+	assert.NoError(t, err)
+	assert.Equal(t, RoleAuditor, admin.Role)
+
+	value, err := vault.GetItem(item.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", value.String())
+	value.Destroy()
+
+	err = vault.RemoveAdmin("intern")
+	assert.NoError(t, err)
+
+	err = vault.RemoveAdmin(RootAdmin)
+	assert.Error(t, err)
+
+	err = vault.Lock()
+	assert.NoError(t, err)
+
+	_, err = vault.UnlockAs("intern", string([]byte("intern_passphrase")))
+	assert.Error(t, err)
+	assert.True(t, vault.IsLocked())
+}
+
+//goland:noinspection GoRedundantConversion
+func TestAdmins_TamperedRole(t *testing.T) {
+	backend := &inMemoryBackend{}
+	vault, err := NewVault(&Options{Backend: backend})
+	assert.NoError(t, err)
+
+	_, err = vault.Initialize(string([]byte("correct_passphrase")), nil)
+	assert.NoError(t, err)
+
+	_, err = vault.AddAdmin("intern", RoleAuditor, string([]byte("intern_passphrase")))
+	assert.NoError(t, err)
+
+	err = vault.Lock()
+	assert.NoError(t, err)
+
+	slotBytes := backend.files[keyslotsPath]
+	backend.files[keyslotsPath] = bytes.Replace(slotBytes, []byte(RoleAuditor), []byte(RoleAdmin), 1)
+
+	_, err = vault.UnlockAs("intern", string([]byte("intern_passphrase")))
+	assert.Error(t, err)
+	assert.True(t, vault.IsLocked())
+}
+
+func TestGetItem_ReadLimit(t *testing.T) {
+	vault, err := NewVault(&Options{Backend: &inMemoryBackend{}})
+	assert.NoError(t, err)
+
+	_, err = vault.Initialize(string([]byte("correct_passphrase")), nil)
+	assert.NoError(t, err)
+
+	item, err := vault.CreateItem("Test Item")
+	assert.NoError(t, err)
+
+	err = vault.SetItemValue(item.Id, memguard.NewBufferFromBytes([]byte("secret")))
+	assert.NoError(t, err)
+
+	item, err = vault.SetItemReadLimit(item.Id, 2, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, item.RemainingReads())
+
+	value, err := vault.GetItem(item.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), value.Bytes())
+
+	item, err = vault.ItemMetadata(item.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, item.RemainingReads())
+
+	// The last read deletes the item
+	value, err = vault.GetItem(item.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("secret"), value.Bytes())
+
+	_, err = vault.GetItem(item.Id)
+	assert.Error(t, err)
+	assert.Empty(t, vault.Items())
+}
+
+type failingDeleteBackend struct {
+	*inMemoryBackend
+}
+
+func (b failingDeleteBackend) DeleteFile(string) (bool, error) {
+	return false, errors.New("read-only file system")
+}
+
+func TestGetItem_ReadLimitDeleteFails(t *testing.T) {
+	backend := &inMemoryBackend{}
+	vault, err := NewVault(&Options{Backend: failingDeleteBackend{backend}})
+	assert.NoError(t, err)
+
+	_, err = vault.Initialize(string([]byte("correct_passphrase")), nil)
+	assert.NoError(t, err)
+
+	item, err := vault.CreateItem("Test Item")
+	assert.NoError(t, err)
+
+	err = vault.SetItemValue(item.Id, memguard.NewBufferFromBytes([]byte("secret")))
+	assert.NoError(t, err)
+
+	_, err = vault.SetItemReadLimit(item.Id, 1, nil)
+	assert.NoError(t, err)
+
+	// The value isn't handed out if the exhausted item can't be deleted
+	value, err := vault.GetItem(item.Id)
+	assert.Error(t, err)
+	assert.Nil(t, value)
+
+	// The files are still there after a restart, with the read counted
+	assert.NoError(t, vault.Lock())
+	assert.NoError(t, vault.Unlock(string([]byte("correct_passphrase"))))
+
+	item, err = vault.ItemMetadata(item.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, item.RemainingReads())
+
+	_, err = vault.GetItem(item.Id)
+	assert.Error(t, err)
+}
+
+func TestGetItem_Expired(t *testing.T) {
+	vault, err := NewVault(&Options{Backend: &inMemoryBackend{}})
+	assert.NoError(t, err)
+
+	_, err = vault.Initialize(string([]byte("correct_passphrase")), nil)
+	assert.NoError(t, err)
+
+	item, err := vault.CreateItem("Test Item")
+	assert.NoError(t, err)
+
+	err = vault.SetItemValue(item.Id, memguard.NewBufferFromBytes([]byte("secret")))
+	assert.NoError(t, err)
+
+	expiresAt := time.Now().Add(-time.Second)
+	_, err = vault.SetItemReadLimit(item.Id, 0, &expiresAt)
+	assert.NoError(t, err)
+
+	_, err = vault.GetItem(item.Id)
+	assert.Error(t, err)
+	assert.Empty(t, vault.Items())
+}
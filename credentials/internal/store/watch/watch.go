@@ -0,0 +1,228 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package watch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/vemilyus/borg-collective/credentials/internal/store/vault"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HistorySize is the number of past events a watcher can resume from
+	HistorySize = 1024
+	// WatcherBuffer is the number of events a watcher may fall behind before
+	// it's dropped
+	WatcherBuffer = 256
+)
+
+// ErrOverflow ends a watch whose consumer didn't keep up, it can resume
+// from the last event it received
+var ErrOverflow = errors.New("watcher fell behind, resume from the last event")
+
+type EventType int
+
+const (
+	ItemCreated EventType = iota
+	ItemUpdated
+	ItemDeleted
+	VaultLocked
+	VaultUnlocked
+	ClientCreated
+	ClientRevoked
+)
+
+type Event struct {
+	Seq   uint64
+	Token string
+	Type  EventType
+	At    time.Time
+	// the item after the change, or before its deletion. Only the id is set
+	// for expired items.
+	Item *vault.Item
+	// set on client events, the client ID or certificate common name
+	ClientId string
+}
+
+// Hub distributes vault changes to watchers and keeps the most recent ones,
+// so reconnecting watchers don't miss anything. Events only live in memory,
+// resume tokens become invalid when the store restarts.
+type Hub struct {
+	lock     sync.Mutex
+	epoch    string
+	seq      uint64
+	history  []Event
+	watchers map[*Watcher]struct{}
+	closed   bool
+}
+
+type Watcher struct {
+	hub      *Hub
+	events   chan Event
+	overflow bool
+	closed   bool
+}
+
+func NewHub() *Hub {
+	epoch := make([]byte, 8)
+	_, _ = rand.Read(epoch)
+
+	return &Hub{
+		lock:     sync.Mutex{},
+		epoch:    hex.EncodeToString(epoch),
+		watchers: make(map[*Watcher]struct{}),
+	}
+}
+
+// Publish numbers the event and hands it to all watchers without blocking,
+// watchers which are too far behind are dropped
+func (h *Hub) Publish(event Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.seq++
+	event.Seq = h.seq
+	event.Token = h.token(h.seq)
+	event.At = time.Now()
+
+	if len(h.history) == HistorySize {
+		copy(h.history, h.history[1:])
+		h.history = h.history[:HistorySize-1]
+	}
+
+	h.history = append(h.history, event)
+
+	for w := range h.watchers {
+		select {
+		case w.events <- event:
+		default:
+			w.overflow = true
+			h.removeUnsafe(w)
+		}
+	}
+}
+
+// Watch registers a watcher receiving all events published from now on,
+// along with the events published after the one identified by the resume
+// token. If the events since then aren't known anymore, the reset token is
+// set to the token of the latest event and the watcher needs to rebuild its
+// state.
+func (h *Hub) Watch(resumeToken string) (w *Watcher, missed []Event, resetToken string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if resumeToken != "" {
+		var reset bool
+		if missed, reset = h.eventsAfterUnsafe(resumeToken); reset {
+			resetToken = h.token(h.seq)
+		}
+	}
+
+	w = &Watcher{hub: h, events: make(chan Event, WatcherBuffer)}
+	h.watchers[w] = struct{}{}
+
+	if h.closed {
+		h.removeUnsafe(w)
+	}
+
+	return w, missed, resetToken
+}
+
+func (h *Hub) eventsAfterUnsafe(resumeToken string) ([]Event, bool) {
+	seq, err := h.parseToken(resumeToken)
+	if err != nil || seq > h.seq {
+		return nil, true
+	}
+
+	if seq == h.seq {
+		return nil, false
+	}
+
+	// the history holds consecutive events, the oldest one has to directly
+	// follow the resumed one
+	if len(h.history) == 0 || h.history[0].Seq > seq+1 {
+		return nil, true
+	}
+
+	start := int(seq + 1 - h.history[0].Seq)
+
+	return append([]Event(nil), h.history[start:]...), false
+}
+
+func (h *Hub) token(seq uint64) string {
+	return h.epoch + "." + strconv.FormatUint(seq, 10)
+}
+
+func (h *Hub) parseToken(token string) (uint64, error) {
+	epoch, rawSeq, ok := strings.Cut(token, ".")
+	if !ok || epoch != h.epoch {
+		return 0, fmt.Errorf("unknown resume token: %s", token)
+	}
+
+	return strconv.ParseUint(rawSeq, 10, 64)
+}
+
+// Close ends all watches, e.g. when shutting down. Watches started later end
+// right away.
+func (h *Hub) Close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.closed = true
+
+	for w := range h.watchers {
+		h.removeUnsafe(w)
+	}
+}
+
+func (h *Hub) removeUnsafe(w *Watcher) {
+	delete(h.watchers, w)
+
+	if !w.closed {
+		w.closed = true
+		close(w.events)
+	}
+}
+
+// Events is closed when the watch ends, Err tells why
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Err returns ErrOverflow if the watcher was dropped for falling behind
+func (w *Watcher) Err() error {
+	w.hub.lock.Lock()
+	defer w.hub.lock.Unlock()
+
+	if w.overflow {
+		return ErrOverflow
+	}
+
+	return nil
+}
+
+func (w *Watcher) Close() {
+	w.hub.lock.Lock()
+	defer w.hub.lock.Unlock()
+
+	w.hub.removeUnsafe(w)
+}
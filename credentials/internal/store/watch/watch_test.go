@@ -0,0 +1,123 @@
+// Copyright (C) 2025 Alex Katlein
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package watch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch(t *testing.T) {
+	hub := NewHub()
+
+	watcher, missed, resetToken := hub.Watch("")
+	assert.Empty(t, missed)
+	assert.Empty(t, resetToken)
+
+	hub.Publish(Event{Type: VaultUnlocked})
+	hub.Publish(Event{Type: ClientCreated, ClientId: "client-a"})
+
+	first := <-watcher.Events()
+	assert.Equal(t, VaultUnlocked, first.Type)
+	assert.Equal(t, uint64(1), first.Seq)
+
+	second := <-watcher.Events()
+	assert.Equal(t, "client-a", second.ClientId)
+	assert.NotEqual(t, first.Token, second.Token)
+
+	watcher.Close()
+
+	_, ok := <-watcher.Events()
+	assert.False(t, ok)
+	assert.NoError(t, watcher.Err())
+}
+
+func TestWatch_Resume(t *testing.T) {
+	hub := NewHub()
+
+	for range 3 {
+		hub.Publish(Event{Type: ItemCreated})
+	}
+
+	_, missed, _ := hub.Watch("")
+	assert.Empty(t, missed)
+
+	_, all, _ := hub.Watch(hub.history[0].Token)
+	assert.Len(t, all, 2)
+	assert.Equal(t, uint64(2), all[0].Seq)
+
+	_, missed, resetToken := hub.Watch(hub.history[2].Token)
+	assert.Empty(t, missed)
+	assert.Empty(t, resetToken)
+}
+
+func TestWatch_ResumeUnknown(t *testing.T) {
+	hub := NewHub()
+	hub.Publish(Event{Type: VaultLocked})
+
+	// tokens of an earlier run of the store
+	_, missed, resetToken := hub.Watch("0123456789abcdef.1")
+	assert.Empty(t, missed)
+	assert.Equal(t, hub.history[0].Token, resetToken)
+
+	for range HistorySize + 1 {
+		hub.Publish(Event{Type: ItemUpdated})
+	}
+
+	// the events since then were dropped from the history
+	_, missed, resetToken = hub.Watch(hub.token(1))
+	assert.Empty(t, missed)
+	assert.NotEmpty(t, resetToken)
+
+	_, missed, resetToken = hub.Watch(hub.token(2))
+	assert.Len(t, missed, HistorySize)
+	assert.Empty(t, resetToken)
+}
+
+func TestWatch_Overflow(t *testing.T) {
+	hub := NewHub()
+	watcher, _, _ := hub.Watch("")
+
+	for range WatcherBuffer + 1 {
+		hub.Publish(Event{Type: ItemUpdated})
+	}
+
+	received := 0
+	for range watcher.Events() {
+		received++
+	}
+
+	assert.Equal(t, WatcherBuffer, received)
+	assert.ErrorIs(t, watcher.Err(), ErrOverflow)
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub()
+
+	watcher, _, _ := hub.Watch("")
+	hub.Close()
+
+	_, ok := <-watcher.Events()
+	assert.False(t, ok)
+
+	// watches started while shutting down end right away
+	late, _, _ := hub.Watch("")
+
+	_, ok = <-late.Events()
+	assert.False(t, ok)
+	assert.NoError(t, late.Err())
+}